@@ -0,0 +1,130 @@
+package pusher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	s "strings"
+	"time"
+)
+
+// Default time allowed for an authorization request
+const defaultAuthTimeout = 10 * time.Second
+
+// PresenceAuthFunc authorizes a presence channel subscription, returning the
+// auth signature together with the channel data it signs
+type PresenceAuthFunc func(socketID, channel string) (auth, channelData string, err error)
+
+// HTTPAuthorizer authorizes private and presence channels against an HTTP
+// endpoint, in the same way as pusher-js: it POSTs socket_id and
+// channel_name as a form, and expects a JSON response with auth and, for
+// presence channels, channel_data, or for encrypted channels, shared_secret.
+// Use it with
+//
+//	authorizer := pusher.NewHTTPAuthorizer("https://example.com/pusher/auth")
+//	config.AuthFunc = authorizer.Authorize
+//	config.PresenceAuthFunc = authorizer.AuthorizePresence
+//	config.EncryptedAuthFunc = authorizer.AuthorizeEncrypted
+type HTTPAuthorizer struct {
+	Endpoint string
+	// Headers are added to each request, e.g. for a session cookie or
+	// bearer token
+	Headers http.Header
+	// Params are sent alongside socket_id and channel_name
+	Params url.Values
+	Client *http.Client
+	// Timeout bounds each request. Zero means the 10s default.
+	Timeout time.Duration
+}
+
+func NewHTTPAuthorizer(endpoint string) *HTTPAuthorizer {
+	return &HTTPAuthorizer{
+		Endpoint: endpoint,
+		Headers:  http.Header{},
+		Params:   url.Values{},
+		Client:   http.DefaultClient,
+	}
+}
+
+// Authorize is an AuthFunc for private channels
+func (self *HTTPAuthorizer) Authorize(socketID, channel string) (string, error) {
+	auth, _, _, err := self.request(socketID, channel)
+	return auth, err
+}
+
+// AuthorizeEncrypted is an EncryptedAuthFunc for private-encrypted channels
+func (self *HTTPAuthorizer) AuthorizeEncrypted(socketID, channel string) (string, string, error) {
+	auth, _, sharedSecret, err := self.request(socketID, channel)
+	if err == nil && sharedSecret == "" {
+		err = fmt.Errorf("pusher: auth response for %v has no shared_secret", channel)
+	}
+	return auth, sharedSecret, err
+}
+
+// AuthorizePresence is a PresenceAuthFunc for presence channels
+func (self *HTTPAuthorizer) AuthorizePresence(socketID, channel string) (string, string, error) {
+	auth, channelData, _, err := self.request(socketID, channel)
+	if err == nil && channelData == "" {
+		err = fmt.Errorf("pusher: auth response for %v has no channel_data", channel)
+	}
+	return auth, channelData, err
+}
+
+func (self *HTTPAuthorizer) request(socketID, channel string) (string, string, string, error) {
+	form := url.Values{}
+	for key, values := range self.Params {
+		form[key] = values
+	}
+	form.Set("socket_id", socketID)
+	form.Set("channel_name", channel)
+
+	timeout := self.Timeout
+	if timeout <= 0 {
+		timeout = defaultAuthTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", self.Endpoint, s.NewReader(form.Encode()))
+	if err != nil {
+		return "", "", "", err
+	}
+	for key, values := range self.Headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := self.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", "", "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", "", "", fmt.Errorf("pusher: auth endpoint returned %v: %s", res.Status, s.TrimSpace(string(body)))
+	}
+
+	var data struct {
+		Auth         string `json:"auth"`
+		ChannelData  string `json:"channel_data"`
+		SharedSecret string `json:"shared_secret"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", "", "", fmt.Errorf("pusher: invalid auth response: %w", err)
+	}
+	if data.Auth == "" {
+		return "", "", "", fmt.Errorf("pusher: auth response for %v has no auth", channel)
+	}
+	return data.Auth, data.ChannelData, data.SharedSecret, nil
+}
@@ -0,0 +1,229 @@
+package pusher_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+func TestHTTPAuthorizer(t *testing.T) {
+	authorize := map[string]func(*pusher.HTTPAuthorizer) error{
+		"Authorize": func(authorizer *pusher.HTTPAuthorizer) error {
+			auth, err := authorizer.Authorize("1.1", "private-x")
+			if err == nil && auth != "key:signature" {
+				return errors.New("unexpected auth " + auth)
+			}
+			return err
+		},
+		"AuthorizePresence": func(authorizer *pusher.HTTPAuthorizer) error {
+			auth, channelData, err := authorizer.AuthorizePresence("1.1", "presence-x")
+			if err == nil && (auth != "key:signature" || channelData != `{"user_id":"1"}`) {
+				return errors.New("unexpected auth " + auth + " " + channelData)
+			}
+			return err
+		},
+		"AuthorizeEncrypted": func(authorizer *pusher.HTTPAuthorizer) error {
+			auth, sharedSecret, err := authorizer.AuthorizeEncrypted("1.1", "private-encrypted-x")
+			if err == nil && (auth != "key:signature" || sharedSecret != "c2VjcmV0") {
+				return errors.New("unexpected auth " + auth + " " + sharedSecret)
+			}
+			return err
+		},
+	}
+	const complete = `{"auth":"key:signature","channel_data":"{\"user_id\":\"1\"}","shared_secret":"c2VjcmV0"}`
+
+	for _, test := range []struct {
+		name   string
+		method string
+		status int
+		body   string
+		err    string
+	}{
+		{"private", "Authorize", http.StatusOK, complete, ""},
+		{"presence", "AuthorizePresence", http.StatusOK, complete, ""},
+		{"encrypted", "AuthorizeEncrypted", http.StatusOK, complete, ""},
+		{"forbidden", "Authorize", http.StatusForbidden, "denied", "403 Forbidden: denied"},
+		{"server error", "AuthorizePresence", http.StatusInternalServerError, "", "500"},
+		{"malformed JSON", "Authorize", http.StatusOK, "{", "invalid auth response"},
+		{"missing auth", "AuthorizeEncrypted", http.StatusOK, `{"shared_secret":"c2VjcmV0"}`, "has no auth"},
+		{"missing channel_data", "AuthorizePresence", http.StatusOK, `{"auth":"key:signature"}`, "has no channel_data"},
+		{"missing shared_secret", "AuthorizeEncrypted", http.StatusOK, `{"auth":"key:signature"}`, "has no shared_secret"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				if r.Method != "POST" || r.PostForm.Get("socket_id") != "1.1" || r.PostForm.Get("channel_name") == "" {
+					t.Errorf("unexpected request %v %v", r.Method, r.PostForm)
+				}
+				if r.PostForm.Get("team") != "a" || r.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("request without the authorizer's params and headers: %v %v", r.PostForm, r.Header)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer srv.Close()
+			authorizer := pusher.NewHTTPAuthorizer(srv.URL)
+			authorizer.Params.Set("team", "a")
+			authorizer.Headers.Set("Authorization", "Bearer token")
+
+			err := authorize[test.method](authorizer)
+			if test.err == "" && err != nil {
+				t.Fatalf("authorizing: %v", err)
+			} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+// TestInvalidPresenceChannelData authorizes a presence channel with
+// channel_data which is not JSON, which must fail the subscription
+func TestInvalidPresenceChannelData(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.PresenceAuthFunc = func(socketID, channel string) (string, string, error) {
+		return "key:signature", "{", nil
+	}
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var authErr *pusher.AuthError
+	if _, err := client.SubscribeWithResult(ctx, "presence-x"); !errors.As(err, &authErr) || !strings.Contains(err.Error(), "invalid channel_data") {
+		t.Fatalf("expected an AuthError, got %v", err)
+	}
+}
+
+// TestPresenceAuthFuncAudit authorizes a presence channel through
+// PresenceAuthFunc, whose channel_data names the user audited rather than a
+// stale UserData
+func TestPresenceAuthFuncAudit(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	config.PresenceAuthFunc = func(socketID, channel string) (string, string, error) {
+		return "key:signature", `{"user_id":"alice"}`, nil
+	}
+	audits := make(chan pusher.AuthAudit, 1)
+	config.OnAuth = func(audit pusher.AuthAudit) { audits <- audit }
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	client.UserData = pusher.Member{UserId: "stale"}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+
+	client.Subscribe("presence-x")
+	select {
+	case audit := <-audits:
+		if audit.UserID != "alice" {
+			t.Fatalf("audited user %q, expected alice", audit.UserID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnAuth was not called")
+	}
+}
+
+// TestAuthAudit authorizes one private channel and fails to authorize
+// another, each of which is audited
+func TestAuthAudit(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	denied := errors.New("denied")
+	config.AuthFunc = func(socketID, channel string) (string, error) {
+		if channel == "private-denied" {
+			return "", denied
+		}
+		return "key:signature", nil
+	}
+	audits := make(chan pusher.AuthAudit, 10)
+	config.OnAuth = func(audit pusher.AuthAudit) {
+		select {
+		case audits <- audit:
+		default:
+		}
+	}
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	for _, channel := range []string{"private-allowed", "private-denied"} {
+		client.Subscribe(channel)
+		select {
+		case audit := <-audits:
+			// The server rejects the signature, but the attempt was authorized
+			if audit.Channel != channel || audit.SocketID == "" || audit.UserID != "" || audit.Latency < 0 {
+				t.Fatalf("unexpected audit %+v", audit)
+			}
+			if allowed := channel == "private-allowed"; audit.Success != allowed || (audit.Err == nil) != allowed {
+				t.Fatalf("unexpected outcome %+v", audit)
+			}
+			if !audit.Success && !errors.Is(audit.Err, denied) {
+				t.Fatalf("audited error %v", audit.Err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnAuth was not called")
+		}
+	}
+}
+
+// hmacCrypto signs with a secret kept out of the client's config, as a
+// provider backed by an HSM would
+type hmacCrypto struct {
+	pusher.CryptoProvider
+	secret string
+}
+
+func (self hmacCrypto) Sign(stringToSign string) (string, error) {
+	mac := hmac.New(sha256.New, []byte(self.secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// TestCryptoProviderSignsPresence subscribes to a presence channel signed by
+// the crypto provider, without a Secret
+func TestCryptoProviderSignsPresence(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.Secret = ""
+	config.Crypto = hmacCrypto{secret: "secret"}
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	client.UserData = pusher.Member{UserId: "alice"}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := client.SubscribeWithResult(ctx, "presence-x"); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+}
+
+// TestGenerateAuth signs the examples in Pusher's documentation of channel
+// authorization
+func TestGenerateAuth(t *testing.T) {
+	if auth := pusher.GenerateAuth("278d425bdf160c739803", "7ad3773142a6692b25b8", "1234.1234", "private-foobar", nil); auth != "278d425bdf160c739803:58df8b0c36d6982b82c3ecf6b4662e34fe8c25bba48f5369f135bf843651c3a4" {
+		t.Errorf("private channel auth is %v", auth)
+	}
+	channelData := `{"user_id":10,"user_info":{"name":"Mr. Channels"}}`
+	if auth := pusher.GenerateAuth("278d425bdf160c739803", "7ad3773142a6692b25b8", "1234.1234", "presence-foobar", &channelData); auth != "278d425bdf160c739803:31935e7d86dba64c2a90aed31fdc61869f9b22ba9d8863bba239c03ca481bc80" {
+		t.Errorf("presence channel auth is %v", auth)
+	}
+}
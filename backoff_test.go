@@ -0,0 +1,111 @@
+package pusher
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// nullConn is a connection which discards what it is sent
+type nullConn struct{}
+
+func (nullConn) Send(message []byte) error                { return nil }
+func (nullConn) SetActivityTimeout(timeout time.Duration) {}
+func (nullConn) Close() error                             { return nil }
+
+func (nullConn) Shutdown(messages [][]byte) <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// delays reports the delays of reconnections, discarding other metrics
+type delays chan time.Duration
+
+func (delays) Connected()                                                   {}
+func (delays) Disconnected()                                                {}
+func (delays) MessageReceived(channel, event string, size int)              {}
+func (delays) MessageSent(channel, event string, size int)                  {}
+func (delays) SubscriptionSucceeded(channel string)                         {}
+func (delays) SubscriptionFailed(channel string, err error)                 {}
+func (delays) PingRTT(rtt time.Duration)                                    {}
+func (delays) HandlerLatency(channel, event string, duration time.Duration) {}
+
+func (self delays) Reconnecting(attempt int, delay time.Duration) {
+	self <- delay
+}
+
+// newBackoffClient returns a ManualPoll client, whose run loop the test
+// drives, with seeded jitter
+func newBackoffClient(seed int64, config ClientConfig) *Client {
+	config.ManualPoll = true
+	config.DisableAutoConnect = true
+	config.RandSource = rand.NewSource(seed)
+	return NewWithConfig(config)
+}
+
+// TestBackoffJitter checks that each delay doubles with consecutive failures,
+// with up to 50% jitter, which is the same for the same seed
+func TestBackoffJitter(t *testing.T) {
+	const max = 100 * time.Second
+	for seed := int64(1); seed <= 20; seed++ {
+		client := newBackoffClient(seed, ClientConfig{MaxReconnectDelay: max})
+		replay := newBackoffClient(seed, ClientConfig{MaxReconnectDelay: max})
+		for failures := 1; failures <= 6; failures++ {
+			base := reconnectDelay << (failures - 1)
+			delay := client.backoff(failures)
+			if delay < base || delay > base+base/2 {
+				t.Fatalf("seed %v: delay %v after %v failures, expected %v to %v", seed, delay, failures, base, base+base/2)
+			}
+			if again := replay.backoff(failures); again != delay {
+				t.Fatalf("seed %v: delay %v after %v failures, then %v with the same seed", seed, delay, failures, again)
+			}
+		}
+	}
+}
+
+// TestBackoffCap checks that delays stop growing at MaxReconnectDelay, or at
+// the default cap without one
+func TestBackoffCap(t *testing.T) {
+	for _, test := range []struct {
+		max, expected time.Duration
+	}{
+		{5 * time.Second, 5 * time.Second},
+		{0, defaultMaxReconnectDelay},
+	} {
+		client := newBackoffClient(1, ClientConfig{MaxReconnectDelay: test.max})
+		for failures := 10; failures <= 40; failures += 10 {
+			if delay := client.backoff(failures); delay != test.expected {
+				t.Fatalf("delay %v after %v failures with MaxReconnectDelay %v, expected %v", delay, failures, test.max, test.expected)
+			}
+		}
+	}
+}
+
+// TestBackoffReset completes a handshake after several failed attempts,
+// after which the next reconnection waits only the initial delay
+func TestBackoffReset(t *testing.T) {
+	reconnecting := make(delays, 1)
+	client := newBackoffClient(1, ClientConfig{
+		Metrics: reconnecting,
+		NewConn: func(c ClientConfig, transport string, callbacks ConnCallbacks) (Conn, error) {
+			return nullConn{}, nil
+		},
+	})
+	defer client.Disconnect()
+
+	client.loop.failures, client.loop.attempts = 4, 4
+	if err := client.connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	client.handleMessage(`{"event":"pusher:connection_established","data":"{\"socket_id\":\"1.1\",\"activity_timeout\":120}"}`)
+	client.handleClose(errors.New("connection lost"))
+
+	if delay := <-reconnecting; delay < reconnectDelay || delay > reconnectDelay+reconnectDelay/2 {
+		t.Fatalf("reconnecting in %v after a handshake, expected %v to %v", delay, reconnectDelay, reconnectDelay+reconnectDelay/2)
+	}
+	if client.loop.attempts != 1 {
+		t.Fatalf("reconnection counted as attempt %v, expected 1", client.loop.attempts)
+	}
+}
@@ -0,0 +1,54 @@
+// Package bench provides a synthetic event generator, driving a
+// pushertest.Server, for load testing and benchmarking the client. The
+// benchmarks of the dispatch and subscribe paths are run with
+// go test -bench . ./bench.
+package bench
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// GeneratorConfig describes a stream of synthetic events
+type GeneratorConfig struct {
+	Channel string
+	Event   string
+	// EventSize is the size in bytes of each event's data
+	EventSize int
+	// Rate is the number of events per second, or zero for as fast as possible
+	Rate int
+	// Count is the number of events to generate
+	Count int
+}
+
+// Payload returns JSON event data of roughly the given size
+func Payload(size int) string {
+	if size < 12 {
+		size = 12
+	}
+	return `{"data":"` + strings.Repeat("x", size-11) + `"}`
+}
+
+// Generate publishes events through a server according to the config,
+// returning once they have all been sent
+func Generate(server *pushertest.Server, config GeneratorConfig) error {
+	data := Payload(config.EventSize)
+
+	var ticker *time.Ticker
+	if config.Rate > 0 {
+		ticker = time.NewTicker(time.Second / time.Duration(config.Rate))
+		defer ticker.Stop()
+	}
+
+	for i := 0; i < config.Count; i++ {
+		if ticker != nil {
+			<-ticker.C
+		}
+		if err := server.Trigger(config.Channel, config.Event, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// Size of the event data used by the benchmarks
+const eventSize = 1024
+
+// BenchmarkDispatch measures the path from the socket to a bound handler
+func BenchmarkDispatch(b *testing.B) {
+	server := pushertest.NewServer("bench", "secret")
+	defer server.Close()
+	client := pusher.NewWithConfig(server.ClientConfig())
+	defer client.Disconnect()
+
+	channel := subscribe(b, client, "bench")
+	var received int64
+	done := make(chan struct{})
+	channel.Bind("bench", func(data interface{}) {
+		if atomic.AddInt64(&received, 1) == int64(b.N) {
+			close(done)
+		}
+	})
+
+	b.SetBytes(eventSize)
+	b.ResetTimer()
+	go Generate(server, GeneratorConfig{Channel: "bench", Event: "bench", EventSize: eventSize, Count: b.N})
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		b.Fatalf("timed out after dispatching %v of %v events", atomic.LoadInt64(&received), b.N)
+	}
+}
+
+// BenchmarkSubscribe measures the subscription round trip
+func BenchmarkSubscribe(b *testing.B) {
+	server := pushertest.NewServer("bench", "secret")
+	defer server.Close()
+	client := pusher.NewWithConfig(server.ClientConfig())
+	defer client.Disconnect()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		subscribe(b, client, fmt.Sprint("bench-", i))
+	}
+}
+
+func subscribe(b *testing.B, client *pusher.Client, name string) *pusher.Channel {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	channel := client.Subscribe(name)
+	if err := channel.WaitSubscribed(ctx); err != nil {
+		b.Fatalf("subscribing to %v: %v", name, err)
+	}
+	return channel
+}
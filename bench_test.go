@@ -0,0 +1,21 @@
+package pusher
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// BenchmarkDecode measures decoding a frame as received from the server
+func BenchmarkDecode(b *testing.B) {
+	data := `{"data":"` + strings.Repeat("x", 1013) + `"}`
+	msg, _ := json.Marshal(map[string]string{"event": "bench", "channel": "bench", "data": data})
+	b.SetBytes(int64(len(msg)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := decode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
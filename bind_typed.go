@@ -0,0 +1,21 @@
+package pusher
+
+import (
+	"fmt"
+)
+
+// BindT binds a callback which receives the event's data decoded into T, as
+// JSON unless ClientConfig.Codec is set.
+// Payloads which cannot be decoded are passed to Channel.ReportDecodeError,
+// and so to the channel's error handlers, instead of the callback. It returns
+// an ID with which the binding can be removed by Channel.UnbindID.
+func BindT[T any](channel *Channel, event string, callback func(T)) BindingID {
+	return channel.bind(event, nil, func(data interface{}) {
+		var value T
+		if err := channel.client.codec().Unmarshal([]byte(DataString(data)), &value); err != nil {
+			channel.ReportDecodeError(fmt.Errorf("pusher: decoding %v on %v: %w", event, channel.Name, err))
+			return
+		}
+		callback(value)
+	})
+}
@@ -0,0 +1,90 @@
+// Package celfilter compiles CEL expressions into pusher filters, e.g.
+//
+//	filter, err := celfilter.Compile(`data.amount > 1000 && data.region == "eu"`)
+//	channel.SetFilter(filter)
+//
+// Expressions see the variables channel, event and data. JSON event data is
+// decoded, so its fields can be addressed directly; any other data is
+// available as a string.
+//
+// Importing the package registers the "cel" filter, configured as
+// {"expression": "..."}.
+package celfilter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+var env *cel.Env
+
+func init() {
+	pusher.Filters.Register("cel", func(config json.RawMessage) (pusher.Filter, error) {
+		var c struct {
+			Expression string `json:"expression"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return Compile(c.Expression)
+	})
+
+	var err error
+	env, err = cel.NewEnv(
+		cel.Variable("channel", cel.StringType),
+		cel.Variable("event", cel.StringType),
+		cel.Variable("data", cel.DynType),
+		// JSON numbers decode as doubles, which should still compare with
+		// integer literals such as 1000
+		cel.CrossTypeNumericComparisons(true),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Compile compiles a boolean CEL expression into a filter. Events for which
+// evaluation fails, e.g. because data lacks a referenced field, are rejected.
+func Compile(expression string) (pusher.Filter, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("celfilter: %w", issues.Err())
+	}
+	if out := ast.OutputType(); out != cel.BoolType && out != cel.DynType {
+		return nil, fmt.Errorf("celfilter: expression must be boolean, not %v", out)
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("celfilter: %w", err)
+	}
+
+	return func(event pusher.Event) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			data = event.Data
+		}
+		out, _, err := program.Eval(map[string]interface{}{
+			"channel": event.Channel,
+			"event":   event.Name,
+			"data":    data,
+		})
+		if err != nil {
+			return false
+		}
+		accepted, _ := out.Value().(bool)
+		return accepted
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the expression is invalid
+func MustCompile(expression string) pusher.Filter {
+	filter, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
@@ -0,0 +1,59 @@
+package celfilter_test
+
+import (
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/celfilter"
+)
+
+func TestCompile(t *testing.T) {
+	filter := celfilter.MustCompile(`channel == "orders" && event == "created" && data.amount > 1000 && data.region == "eu"`)
+	for _, test := range []struct {
+		event    pusher.Event
+		accepted bool
+	}{
+		{pusher.Event{Channel: "orders", Name: "created", Data: `{"amount":1500,"region":"eu"}`}, true},
+		{pusher.Event{Channel: "orders", Name: "created", Data: `{"amount":500,"region":"eu"}`}, false},
+		{pusher.Event{Channel: "orders", Name: "created", Data: `{"amount":1500,"region":"us"}`}, false},
+		{pusher.Event{Channel: "refunds", Name: "created", Data: `{"amount":1500,"region":"eu"}`}, false},
+		{pusher.Event{Channel: "orders", Name: "created", Data: `{"region":"eu"}`}, false},
+		{pusher.Event{Channel: "orders", Name: "created", Data: "not json"}, false},
+	} {
+		if accepted := filter(test.event); accepted != test.accepted {
+			t.Errorf("filter(%+v) = %v, expected %v", test.event, accepted, test.accepted)
+		}
+	}
+
+	filter = celfilter.MustCompile(`data.startsWith("hello")`)
+	if !filter(pusher.Event{Data: "hello world"}) || filter(pusher.Event{Data: "goodbye"}) {
+		t.Error("expected data which is not JSON to be matched as a string")
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, expression := range []string{`data.amount >`, `event + "x"`, `unknown == 1`} {
+		if _, err := celfilter.Compile(expression); err == nil {
+			t.Errorf("expected %q to be rejected", expression)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustCompile to panic")
+		}
+	}()
+	celfilter.MustCompile(`data.amount >`)
+}
+
+func TestRegistered(t *testing.T) {
+	filter, err := pusher.Filters.New("cel", []byte(`{"expression": "event == 'created'"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter(pusher.Event{Name: "created"}) || filter(pusher.Event{Name: "deleted"}) {
+		t.Error("the registered filter does not evaluate its expression")
+	}
+	if _, err := pusher.Filters.New("cel", []byte(`{"expression": 1}`)); err == nil {
+		t.Error("expected invalid config to be rejected")
+	}
+}
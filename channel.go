@@ -1,14 +1,70 @@
 package pusher
 
 import (
+	"context"
+	"encoding/json"
 	s "strings"
+	"sync"
+	"time"
 )
 
 type Channel struct {
-	Subscribed bool
-	Name       string
-	*connection
+	Name     string
+	client   *Client
 	bindings *chanbindings
+	// Dispatch to bindings inline, rather than on their own goroutines
+	inline bool
+	// Unsubscribed, but retained in case of a later resubscription
+	idle bool
+	// Consecutive authorization failures, and when the next retry is due.
+	// retryAt is zero while no retry is scheduled.
+	authFailures int
+	retryAt      time.Time
+
+	// Subscription state, updated by the client run loop and guarded by
+	// mutex. failed is set once authorization has failed MaxAuthFailures
+	// times in a row, after which the channel is not retried until
+	// subscribed again.
+	mutex      sync.Mutex
+	subscribed bool
+	failed     error
+	conn       Conn
+	// The pending subscription attempt, completed by subscriptionDone, and
+	// the error of the last attempt if it failed, for later waiters
+	attempt *subscriptionAttempt
+	lastErr error
+
+	// Presence state, updated by the client run loop and guarded by mutex.
+	// membersSynced is closed once the member list has been received, or
+	// membersErr set because it could not be decoded, and replaced, with
+	// both cleared, when the channel is no longer subscribed.
+	members       map[string]Member
+	me            string
+	membersSynced chan struct{}
+	membersErr    error
+
+	// Reported by the server when subscription counting is enabled, guarded
+	// by mutex
+	subscriptionCount int
+
+	// Lifecycle callbacks, guarded by mutex
+	onSubscribed   []func()
+	onUnsubscribed []func()
+	onResubscribed []func()
+	// Set when the connection drops while subscribed, so that the following
+	// subscription is reported as a resubscription
+	resubscribing bool
+	// Overrides ClientConfig.NoResubscribe when set, guarded by mutex
+	resubscribe *bool
+
+	// Decode error policy state, guarded by mutex
+	decodeErrors int
+	muted        bool
+
+	errorHandlers []func(err error)
+
+	// Evaluated before dispatch to any binding, guarded by mutex
+	filter Filter
 }
 
 type EventHandler func(data interface{})
@@ -18,34 +74,507 @@ func (self *Channel) isPrivate() bool {
 }
 
 func (self *Channel) isPresence() bool {
-	return s.HasPrefix(self.Name, "presence-")
+	return isPresence(self.Name)
+}
+
+func isPresence(channel string) bool {
+	return s.HasPrefix(channel, "presence-")
 }
 
-func (self *Channel) Trigger(event string, data interface{}) {
-	payload, err := encode(event, data, &self.Name)
+// Trigger sends a client event on the channel. The event name must start with
+// client-, and the channel must be a subscribed private or presence channel.
+// It returns ErrReadOnly if the client is configured ReadOnly, ErrNotConnected
+// or ErrNotSubscribed if it cannot be sent yet, and ErrRateLimited if
+// ClientEventRate is exceeded.
+func (self *Channel) Trigger(event string, data interface{}) error {
+	if self.client.ReadOnly {
+		return ErrReadOnly
+	}
+	if !s.HasPrefix(event, "client-") {
+		return ErrClientEventName
+	}
+	if !self.isPrivate() && !self.isPresence() {
+		return ErrClientEventChannel
+	}
+	if isEncrypted(self.Name) {
+		return errEncryptedTrigger
+	}
+	if self.client.State() != StateConnected {
+		return ErrNotConnected
+	}
+	conn := self.connection()
+	if conn == nil {
+		return ErrNotSubscribed
+	}
+	if self.client.QueueClientEvents {
+		self.client.loop.limiter.wait()
+	} else if !self.client.loop.limiter.allow() {
+		return ErrRateLimited
+	}
+
+	payload, err := self.client.intercept(OutgoingMessage{Event: event, Channel: self.Name, Data: data})
 
-	if err != nil {
-		panic(err)
+	if err != nil || payload == nil {
+		return err
 	}
 
-	self.connection.send(payload)
+	if err := conn.Send(payload); err != nil {
+		return err
+	}
+	self.client.metrics().MessageSent(self.Name, event, len(payload))
+	return nil
+}
+
+// Bind binds a callback to an event on the channel. Any number of callbacks
+// may be bound to the same event, and each runs on its own goroutine,
+// receiving events in order. It returns an ID with which the binding can be
+// removed by UnbindID, as can those of the other Bind methods.
+func (self *Channel) Bind(event string, callback EventHandler) BindingID {
+	return self.bind(event, nil, callback)
+}
+
+// BindFunc binds a callback which receives event data as a string. Data which
+// the client has decoded, such as presence members, is passed as JSON.
+func (self *Channel) BindFunc(event string, callback func(data string)) BindingID {
+	return self.bind(event, nil, func(data interface{}) {
+		callback(DataString(data))
+	})
+}
+
+// BindEvent binds a callback which receives the event in full, including the
+// UserId of the sender for client events on presence channels. Data which the
+// client has decoded, such as presence members, is passed as JSON.
+func (self *Channel) BindEvent(event string, callback func(Event)) BindingID {
+	return self.addBinding(event, false, true, nil, func(data interface{}) {
+		callback(data.(Event))
+	}).id
+}
+
+// BindContext binds a callback which is passed a context, cancelled once the
+// callback has run for the client's HandlerTimeout, or has returned
+func (self *Channel) BindContext(event string, callback func(ctx context.Context, data interface{})) BindingID {
+	timeout := self.client.HandlerTimeout
+	return self.bind(event, nil, func(data interface{}) {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		defer cancel()
+		callback(ctx, data)
+	})
+}
+
+// DataString returns event data as BindFunc passes it: as is when it is a
+// string, and otherwise, e.g. for presence members, encoded as JSON
+func DataString(data interface{}) string {
+	if str, ok := data.(string); ok {
+		return str
+	}
+	encoded, _ := json.Marshal(data)
+	return string(encoded)
+}
+
+// BindFiltered binds a callback which only receives the events accepted by
+// filter. The filter runs on the client's run loop, so must be quick.
+func (self *Channel) BindFiltered(event string, filter Filter, callback EventHandler) BindingID {
+	return self.bind(event, filter, callback)
+}
+
+func (self *Channel) bind(event string, filter Filter, callback EventHandler) BindingID {
+	return self.addBinding(event, false, false, filter, callback).id
 }
 
-func (self *Channel) Bind(event string, callback EventHandler) {
+// addBinding binds callback to an event, or if pattern is set, to the events
+// matching it, returning the binding. Bindings which are full are delivered
+// the whole Event rather than its data.
+func (self *Channel) addBinding(event string, pattern, full bool, filter Filter, callback EventHandler) *binding {
+	self.client.bindingsMutex.Lock()
+	defer self.client.bindingsMutex.Unlock()
+	self.client.lastBindingID++
+	id := self.client.lastBindingID
+
+	recovering := callback
+	callback = func(data interface{}) {
+		name := event
+		if event, ok := data.(patternEvent); ok {
+			name = event.name
+		}
+		// Only timed when there is a collector, to keep dispatch cheap
+		// otherwise
+		if metrics := self.client.Metrics; metrics != nil {
+			start := time.Now()
+			defer func() { metrics.HandlerLatency(self.Name, name, time.Since(start)) }()
+		}
+		defer self.client.recoverHandler(self, self.Name, name)
+		done := self.client.timeHandler(self.Name, name)
+		defer done()
+		recovering(data)
+	}
+
 	bindings := *self.bindings
 	if bindings[self.Name] == nil {
-		bindings[self.Name] = make(map[string]chan (interface{}))
+		bindings[self.Name] = make(evBind)
+	}
+
+	if self.inline {
+		bound := &binding{id: id, callback: callback, filter: filter, pattern: pattern, full: full}
+		bindings[self.Name][event] = append(bindings[self.Name][event], bound)
+		return bound
 	}
 
-	channelEvents := make(chan interface{})
+	if pool := self.client.loop.pool; pool != nil {
+		bound := &binding{done: make(chan struct{}), id: id, callback: callback, filter: filter, pattern: pattern, full: full, pool: pool, channel: self.Name}
+		bindings[self.Name][event] = append(bindings[self.Name][event], bound)
+		return bound
+	}
 
-	bindings[self.Name][event] = channelEvents
+	channelEvents := make(chan interface{}, self.client.bindingBuffer())
+	done := make(chan struct{})
+
+	bound := &binding{events: channelEvents, done: done, id: id, callback: callback, filter: filter, pattern: pattern, full: full,
+		overflow: self.client.OverflowPolicy, dropped: self.overflowed(event)}
+	bindings[self.Name][event] = append(bindings[self.Name][event], bound)
 
 	go func() {
 		for {
-			data := <-channelEvents
-			callback(data)
+			select {
+			case data := <-channelEvents:
+				callback(data)
+			case <-done:
+				return
+			}
 		}
 	}()
 
+	return bound
+}
+
+// BindOnce binds a callback which is unbound after receiving the first
+// event, e.g. the response to a request
+func (self *Channel) BindOnce(event string, callback EventHandler) BindingID {
+	var once sync.Once
+	bound := make(chan BindingID, 1)
+	id := self.addBinding(event, false, false, nil, func(data interface{}) {
+		once.Do(func() {
+			self.UnbindID(<-bound)
+			callback(data)
+		})
+	}).id
+	bound <- id
+	return id
+}
+
+// UnbindID removes the binding with the ID returned when it was bound. Other
+// bindings of the same function are kept. It takes the place of an
+// Unbind(event, handler), as Go functions cannot be compared: matching their
+// code would remove every closure of the same function literal.
+func (self *Channel) UnbindID(id BindingID) {
+	self.client.bindingsMutex.Lock()
+	defer self.client.bindingsMutex.Unlock()
+
+	bindings := (*self.bindings)[self.Name]
+	for event := range bindings {
+		removeBindings(bindings, event, func(binding *binding) bool {
+			return binding.id == id
+		})
+	}
+}
+
+// removeBindings stops and removes the bindings to an event for which remove
+// returns true, with the owning client's bindingsMutex held
+func removeBindings(bindings evBind, event string, remove func(*binding) bool) {
+	var kept []*binding
+	for _, binding := range bindings[event] {
+		if remove(binding) {
+			binding.stop()
+		} else {
+			kept = append(kept, binding)
+		}
+	}
+	if len(kept) == 0 {
+		delete(bindings, event)
+	} else {
+		bindings[event] = kept
+	}
+}
+
+// UnbindAll removes every binding on the channel
+func (self *Channel) UnbindAll() {
+	self.client.bindingsMutex.Lock()
+	defer self.client.bindingsMutex.Unlock()
+	unbindAll(*self.bindings, self.Name)
+}
+
+// unbindAll removes the bindings of a channel, with the owning client's
+// bindingsMutex held
+func unbindAll(bindings chanbindings, channel string) {
+	for _, events := range bindings[channel] {
+		for _, binding := range events {
+			binding.stop()
+		}
+	}
+	delete(bindings, channel)
+}
+
+// SubscriptionCount returns the number of connections subscribed to the
+// channel, as last reported in a pusher:subscription_count event. It is zero
+// unless subscription counting is enabled for the application.
+func (self *Channel) SubscriptionCount() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.subscriptionCount
+}
+
+func (self *Channel) setSubscriptionCount(count int) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.subscriptionCount = count
+}
+
+// OnSubscribed registers a callback run when a subscription to the channel
+// succeeds. Callbacks run on the client's run loop, so must not block.
+func (self *Channel) OnSubscribed(callback func()) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.onSubscribed = append(self.onSubscribed, callback)
+}
+
+// OnUnsubscribed registers a callback run when the channel is unsubscribed
+// with Client.Unsubscribe
+func (self *Channel) OnUnsubscribed(callback func()) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.onUnsubscribed = append(self.onUnsubscribed, callback)
+}
+
+// OnResubscribed registers a callback run when the channel is subscribed
+// again after the connection was lost and re-established. Events may have been
+// missed in between, so this is the place to refresh any snapshots.
+func (self *Channel) OnResubscribed(callback func()) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.onResubscribed = append(self.onResubscribed, callback)
+}
+
+// SetResubscribe decides whether the channel is subscribed again after the
+// connection was lost and re-established, overriding the client's
+// NoResubscribe, e.g. to drop ephemeral channels. Channels which are not
+// resubscribed are unsubscribed when the connection is re-established.
+func (self *Channel) SetResubscribe(enabled bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.resubscribe = &enabled
+}
+
+func (self *Channel) resubscribes() bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.resubscribe != nil {
+		return *self.resubscribe
+	}
+	return !self.client.NoResubscribe
+}
+
+func (self *Channel) runCallbacks(callbacks *[]func()) {
+	self.mutex.Lock()
+	run := append([]func(){}, *callbacks...)
+	self.mutex.Unlock()
+
+	for _, callback := range run {
+		callback()
+	}
+}
+
+// IsSubscribed returns whether the channel is subscribed. It is safe to call
+// from any goroutine.
+func (self *Channel) IsSubscribed() bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.subscribed
+}
+
+// Err returns the last authorization error once the channel has failed
+// MaxAuthFailures times in a row, after which it is not retried until
+// subscribed again, or nil. It is safe to call from any goroutine.
+func (self *Channel) Err() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.failed
+}
+
+// setSubscribed marks the channel as subscribed on conn
+func (self *Channel) setSubscribed(conn Conn) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.subscribed = true
+	self.conn = conn
+}
+
+func (self *Channel) setUnsubscribed() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.subscribed = false
+
+	// The member list is received again on the next subscription
+	self.members = nil
+	self.me = ""
+	self.membersErr = nil
+	select {
+	case <-self.membersSynced:
+		self.membersSynced = make(chan struct{})
+	default:
+	}
+}
+
+func (self *Channel) setFailed(err error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.failed = err
+}
+
+// connection returns the connection on which the channel is subscribed, or
+// nil if it is not subscribed
+func (self *Channel) connection() Conn {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if !self.subscribed {
+		return nil
+	}
+	return self.conn
+}
+
+// subscriptionAttempt is closed by the run loop once a subscription attempt
+// succeeds or fails
+type subscriptionAttempt struct {
+	done chan struct{}
+	err  error
+}
+
+// WaitSubscribed waits until the channel is subscribed, returning the error
+// if its subscription fails, or ctx is done, first. It returns Err at once
+// for a failed channel, and the error of the last attempt if it failed
+// before WaitSubscribed was called and no other attempt has started since.
+func (self *Channel) WaitSubscribed(ctx context.Context) error {
+	self.mutex.Lock()
+	if self.subscribed || self.failed != nil || self.lastErr != nil {
+		err := self.failed
+		if err == nil {
+			err = self.lastErr
+		}
+		self.mutex.Unlock()
+		return err
+	}
+	if self.attempt == nil {
+		self.attempt = &subscriptionAttempt{done: make(chan struct{})}
+	}
+	attempt := self.attempt
+	self.mutex.Unlock()
+
+	select {
+	case <-attempt.done:
+		return attempt.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startAttempt forgets the outcome of the last subscription attempt, so that
+// WaitSubscribed waits for the next one
+func (self *Channel) startAttempt() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.lastErr = nil
+}
+
+// subscriptionDone completes WaitSubscribed calls with the outcome of a
+// subscription attempt, and records it for later calls
+func (self *Channel) subscriptionDone(err error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.lastErr = err
+	if self.attempt != nil {
+		self.attempt.err = err
+		close(self.attempt.done)
+		self.attempt = nil
+	}
+}
+
+// subscriptionSucceeded runs the subscribed or resubscribed callbacks
+func (self *Channel) subscriptionSucceeded() {
+	self.subscriptionDone(nil)
+	if self.resubscribing {
+		self.resubscribing = false
+		self.runCallbacks(&self.onResubscribed)
+	} else {
+		self.runCallbacks(&self.onSubscribed)
+	}
+}
+
+// ReportDecodeError records that a payload on this channel could not be
+// decoded or validated. Once the client's MaxDecodeErrors is reached, dispatch
+// of the channel's events is muted until Unmute is called. Handlers which
+// decode payloads into their own types may report failures here too.
+func (self *Channel) ReportDecodeError(err error) {
+	self.mutex.Lock()
+	self.decodeErrors++
+	mute := !self.muted && self.client.MaxDecodeErrors > 0 && self.decodeErrors >= self.client.MaxDecodeErrors
+	if mute {
+		self.muted = true
+	}
+	self.mutex.Unlock()
+
+	self.client.logger().Errorf("Decode error on %v: %v", self.Name, err)
+	self.emitError(err)
+
+	if mute {
+		self.client.logger().Infof("Muting %v after %v decode errors", self.Name, self.client.MaxDecodeErrors)
+		if self.client.OnChannelMuted != nil {
+			self.client.OnChannelMuted(self.Name, err)
+		}
+	}
+}
+
+// DecodeErrors returns the number of decode errors reported on this channel
+func (self *Channel) DecodeErrors() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.decodeErrors
+}
+
+// Muted returns whether dispatch of this channel's events has been muted
+func (self *Channel) Muted() bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.muted
+}
+
+// Unmute resumes dispatch of this channel's events and resets its decode
+// error count
+func (self *Channel) Unmute() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.muted = false
+	self.decodeErrors = 0
+}
+
+// BindError binds a handler which receives this channel's authorization
+// failures, decode errors, subscription errors and handler panics
+func (self *Channel) BindError(handler func(err error)) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.errorHandlers = append(self.errorHandlers, handler)
+}
+
+func (self *Channel) emitError(err error) {
+	self.mutex.Lock()
+	handlers := append([]func(error){}, self.errorHandlers...)
+	self.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(err)
+	}
+	self.client.emitError(err)
 }
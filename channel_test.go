@@ -0,0 +1,654 @@
+package pusher_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// TestWaitSubscribedAfterFailure calls WaitSubscribed only once the server
+// has rejected the subscription, which must still return the rejection
+func TestWaitSubscribedAfterFailure(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+
+	failed := make(chan error, 1)
+	channel := client.Subscribe("presence-x")
+	channel.BindError(func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+	})
+	select {
+	case <-failed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the subscription to fail")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var subErr *pusher.SubscriptionError
+	if err := channel.WaitSubscribed(ctx); !errors.As(err, &subErr) || subErr.Type != "InvalidChannelData" {
+		t.Fatalf("expected an InvalidChannelData error, got %v", err)
+	}
+}
+
+// TestBindEventUserId triggers a client event on a presence channel, whose
+// sender must reach both the channel's filter and BindEvent
+func TestBindEventUserId(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	sender := pusher.NewWithConfig(srv.ClientConfig())
+	defer sender.Disconnect()
+	sender.UserData = pusher.Member{UserId: "sender"}
+	receiver := pusher.NewWithConfig(srv.ClientConfig())
+	defer receiver.Disconnect()
+	receiver.UserData = pusher.Member{UserId: "receiver"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	from, err := sender.SubscribeWithResult(ctx, "presence-x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	to, err := receiver.SubscribeWithResult(ctx, "presence-x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	filtered := make(chan string, 1)
+	to.SetFilter(func(event pusher.Event) bool {
+		filtered <- event.UserId
+		return true
+	})
+	received := make(chan pusher.Event, 1)
+	to.BindEvent("client-message", func(event pusher.Event) { received <- event })
+
+	if err := from.Trigger("client-message", map[string]string{"text": "hi"}); err != nil {
+		t.Fatalf("triggering: %v", err)
+	}
+	select {
+	case event := <-received:
+		if event.UserId != "sender" || event.Name != "client-message" || event.Data != `{"text":"hi"}` {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the client event")
+	}
+	if userID := <-filtered; userID != "sender" {
+		t.Fatalf("filter saw user ID %q", userID)
+	}
+}
+
+// TestBindFunc binds callbacks taking string data, which must receive event
+// payloads as sent, and data decoded by the client as JSON
+func TestBindFunc(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	client.UserData = pusher.Member{UserId: "me"}
+
+	members := make(chan interface{}, 1)
+	presence := client.Subscribe("presence-x")
+	presence.BindFunc("pusher:subscription_succeeded", func(data string) { members <- data })
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	received := make(chan interface{}, 2)
+	channel.BindFunc("event", func(data string) { received <- data })
+
+	srv.Trigger("x", "event", "hello")
+	srv.Trigger("x", "event", map[string]int{"id": 1})
+	for _, expected := range []string{"hello", `{"id":1}`} {
+		if data := receive(t, received); data != expected {
+			t.Fatalf("received %q, expected %q", data, expected)
+		}
+	}
+	if data := receive(t, members).(string); !json.Valid([]byte(data)) || !strings.Contains(data, `"me"`) {
+		t.Fatalf("expected the members as JSON, got %q", data)
+	}
+}
+
+// TestUnbindID binds closures created by one function literal, of which
+// UnbindID must remove only the one named
+func TestUnbindID(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "items")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	received := make(chan int, 2)
+	var ids []pusher.BindingID
+	for item := 0; item < 2; item++ {
+		ids = append(ids, channel.Bind("event", func(interface{}) { received <- item }))
+	}
+	channel.UnbindID(ids[0])
+
+	srv.Trigger("items", "event", "data")
+	select {
+	case item := <-received:
+		if item != 1 {
+			t.Fatalf("unbound closure %v received the event", item)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("remaining binding did not receive the event")
+	}
+	select {
+	case item := <-received:
+		t.Fatalf("closure %v received the event twice", item)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestPatternAndExactBindings binds an event both by name and by patterns,
+// including one without a *, each of which must receive it once
+func TestPatternAndExactBindings(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "orders")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	received := make(chan interface{}, 8)
+	channel.Bind("order-created", func(interface{}) { received <- "exact" })
+	channel.BindEventPattern("order-*", func(event string, data interface{}) { received <- "pattern " + event })
+	channel.BindEventPattern("*-created", func(event string, data interface{}) { received <- "suffix " + event })
+	channel.BindEventPattern("order-created", func(event string, data interface{}) { received <- "literal " + event })
+	channel.BindEventPattern("invoice-*", func(event string, data interface{}) { received <- "other " + event })
+
+	srv.Trigger("orders", "order-created", "{}")
+	counts := map[string]int{}
+	for i := 0; i < 4; i++ {
+		counts[receive(t, received).(string)]++
+	}
+	select {
+	case binding := <-received:
+		t.Fatalf("unexpected delivery to %v", binding)
+	case <-time.After(100 * time.Millisecond):
+	}
+	for _, binding := range []string{"exact", "pattern order-created", "suffix order-created", "literal order-created"} {
+		if counts[binding] != 1 {
+			t.Fatalf("%v binding received the event %v times", binding, counts[binding])
+		}
+	}
+}
+
+// TestAwaitMembersDecodeError receives a presence member list which cannot be
+// decoded, whose error AwaitMembers must return rather than no members
+func TestAwaitMembersDecodeError(t *testing.T) {
+	conns := make(chan pusher.ConnCallbacks, 1)
+	client := pusher.NewWithConfig(pusher.ClientConfig{
+		Key:    "key",
+		Secret: "secret",
+		NewConn: func(c pusher.ClientConfig, transport string, callbacks pusher.ConnCallbacks) (pusher.Conn, error) {
+			conns <- callbacks
+			return fakeConn{}, nil
+		},
+	})
+	defer client.Disconnect()
+	callbacks := nextConn(t, conns)
+	callbacks.OnMessage <- established
+
+	channel := client.Subscribe("presence-x")
+	callbacks.OnMessage <- `{"event":"pusher_internal:subscription_succeeded","channel":"presence-x","data":"{\"presence\":5}"}`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if members, err := channel.AwaitMembers(ctx); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the decode error, got %v and %v", members, err)
+	}
+}
+
+// TestMaxIdleChannels unsubscribes from more channels than are retained,
+// evicting the oldest along with its bindings
+func TestMaxIdleChannels(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.MaxIdleChannels = 1
+	evicted := make(chan string, 2)
+	config.OnEvict = func(channel string) { evicted <- channel }
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	received := make(chan string, 2)
+	for _, name := range []string{"a", "b"} {
+		channel, err := client.SubscribeWithResult(ctx, name)
+		if err != nil {
+			t.Fatalf("subscribing: %v", err)
+		}
+		channel.Bind("event", func(interface{}) { received <- name })
+	}
+	client.Unsubscribe("a")
+	client.Unsubscribe("b")
+	select {
+	case channel := <-evicted:
+		if channel != "a" {
+			t.Fatalf("evicted %v rather than the oldest idle channel", channel)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an eviction")
+	}
+
+	// The retained channel keeps its binding, and the evicted one does not
+	for _, name := range []string{"a", "b"} {
+		if _, err := client.SubscribeWithResult(ctx, name); err != nil {
+			t.Fatalf("resubscribing: %v", err)
+		}
+		srv.Trigger(name, "event", "data")
+	}
+	select {
+	case name := <-received:
+		if name != "b" {
+			t.Fatalf("the evicted binding of %v received the event", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the retained binding did not receive the event")
+	}
+	select {
+	case name := <-received:
+		t.Fatalf("unexpected event on %v", name)
+	case channel := <-evicted:
+		t.Fatalf("unexpected eviction of %v", channel)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestLifecycleCallbacks subscribes, reconnects and unsubscribes, running the
+// matching callback at each step
+func TestLifecycleCallbacks(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	calls := make(chan string, 3)
+	channel := client.Subscribe("items")
+	channel.OnSubscribed(func() { calls <- "subscribed" })
+	channel.OnResubscribed(func() { calls <- "resubscribed" })
+	channel.OnUnsubscribed(func() { calls <- "unsubscribed" })
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+
+	expect := func(expected string) {
+		t.Helper()
+		select {
+		case call := <-calls:
+			if call != expected {
+				t.Fatalf("expected %v, got %v", expected, call)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %v", expected)
+		}
+	}
+	expect("subscribed")
+	srv.DisconnectAll(4200, "reconnect")
+	expect("resubscribed")
+	client.Unsubscribe("items")
+	expect("unsubscribed")
+}
+
+// TestDecodeErrorQuarantine mutes a channel once MaxDecodeErrors payloads
+// fail to decode, until it is unmuted
+func TestDecodeErrorQuarantine(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.MaxDecodeErrors = 2
+	muted := make(chan string, 1)
+	config.OnChannelMuted = func(channel string, err error) { muted <- channel }
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "items")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	decoded := make(chan int, 1)
+	pusher.BindT(channel, "item", func(item struct{ N int }) { decoded <- item.N })
+	received := make(chan interface{}, 1)
+	channel.Bind("other", func(data interface{}) { received <- data })
+
+	srv.Trigger("items", "item", map[string]int{"N": 1})
+	if n := <-decoded; n != 1 {
+		t.Fatalf("decoded %v", n)
+	}
+	srv.Trigger("items", "item", "not json")
+	srv.Trigger("items", "item", "not json")
+	select {
+	case name := <-muted:
+		if name != "items" || !channel.Muted() || channel.DecodeErrors() != 2 {
+			t.Fatalf("muted %v with %v decode errors", name, channel.DecodeErrors())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the channel to be muted")
+	}
+
+	srv.Trigger("items", "other", "muted")
+	select {
+	case data := <-received:
+		t.Fatalf("muted channel received %v", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+	channel.Unmute()
+	srv.Trigger("items", "other", "unmuted")
+	select {
+	case data := <-received:
+		if data != "unmuted" {
+			t.Fatalf("received %v", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("unmuted channel did not receive the event")
+	}
+}
+
+// TestReadOnly triggers a client event from a read-only client, which must
+// not reach other subscribers
+func TestReadOnly(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	clients := make([]*pusher.Client, 2)
+	for i, userID := range []string{"sender", "receiver"} {
+		// Publishing is only disabled for the sender
+		config.ReadOnly = i == 0
+		clients[i] = pusher.NewWithConfig(config)
+		defer clients[i].Disconnect()
+		clients[i].UserData = pusher.Member{UserId: userID}
+		if err := clients[i].Connect(); err != nil {
+			t.Fatalf("connecting: %v", err)
+		}
+	}
+	sender, receiver := clients[0], clients[1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	from, err := sender.SubscribeWithResult(ctx, "presence-x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	to, err := receiver.SubscribeWithResult(ctx, "presence-x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	received := make(chan string, 1)
+	to.Bind("client-message", func(data interface{}) { received <- "client-message" })
+
+	if err := from.Trigger("client-message", "hi"); !errors.Is(err, pusher.ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	select {
+	case <-received:
+		t.Fatal("the read-only client published an event")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestScrubber redacts personal data before events reach bindings
+func TestScrubber(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.Scrubber = pusher.RedactFields("email")
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "users")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	received := make(chan interface{}, 1)
+	channel.Bind("signup", func(data interface{}) { received <- data })
+	srv.Trigger("users", "signup", map[string]string{"email": "alice@example.com", "name": "alice"})
+	select {
+	case data := <-received:
+		if data != `{"email":"[redacted]","name":"alice"}` {
+			t.Fatalf("received %v", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
+
+// TestBindT decodes event data into a struct, reporting data which does not
+// decode to the channel's error handlers instead
+func TestBindT(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "orders")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	type order struct {
+		ID    int      `json:"id"`
+		Items []string `json:"items"`
+	}
+	decoded := make(chan order, 1)
+	pusher.BindT(channel, "created", func(o order) { decoded <- o })
+	failed := make(chan error, 1)
+	channel.BindError(func(err error) { failed <- err })
+
+	srv.Trigger("orders", "created", order{ID: 1, Items: []string{"tea"}})
+	select {
+	case o := <-decoded:
+		if o.ID != 1 || len(o.Items) != 1 || o.Items[0] != "tea" {
+			t.Fatalf("decoded %+v", o)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the decoded event")
+	}
+
+	srv.Trigger("orders", "created", `{"id":"not a number"}`)
+	select {
+	case err := <-failed:
+		if !strings.Contains(err.Error(), "decoding created on orders") {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case o := <-decoded:
+		t.Fatalf("decoded %+v from invalid data", o)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the decode error")
+	}
+}
+
+// connectAs connects a new client to the server as a presence user
+func connectAs(t *testing.T, srv *pushertest.Server, userID string) *pusher.Client {
+	t.Helper()
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	t.Cleanup(client.Disconnect)
+	client.UserData = pusher.Member{UserId: userID, UserInfo: map[string]string{"name": userID}}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	return client
+}
+
+// TestMembers follows the members of a presence channel as they join and
+// leave
+func TestMembers(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	alice, bob := connectAs(t, srv, "alice"), connectAs(t, srv, "bob")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	channel, err := alice.SubscribeWithResult(ctx, "presence-x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	if members, err := channel.AwaitMembers(ctx); err != nil || len(members) != 1 {
+		t.Fatalf("unexpected members %v, %v", members, err)
+	}
+	if me, ok := channel.Me(); !ok || me.UserId != "alice" || me.UserInfo["name"] != "alice" {
+		t.Fatalf("unexpected me %+v", me)
+	}
+
+	added := make(chan interface{}, 1)
+	channel.Bind("pusher:member_added", func(data interface{}) { added <- data })
+	if _, err := bob.SubscribeWithResult(ctx, "presence-x"); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	receive(t, added)
+	members := channel.Members()
+	if channel.MemberCount() != 2 || len(members) != 2 || members[0].UserId != "alice" || members[1].UserId != "bob" {
+		t.Fatalf("unexpected members %v", members)
+	}
+
+	removed := make(chan interface{}, 1)
+	channel.Bind("pusher:member_removed", func(data interface{}) { removed <- data })
+	bob.Unsubscribe("presence-x")
+	receive(t, removed)
+	if members := channel.Members(); channel.MemberCount() != 1 || members[0].UserId != "alice" {
+		t.Fatalf("unexpected members %v", members)
+	}
+}
+
+func TestMemberDecodeInto(t *testing.T) {
+	var member pusher.Member
+	if err := json.Unmarshal([]byte(`{"user_id":"alice","user_info":{"name":"Alice","age":30,"roles":["admin"]}}`), &member); err != nil {
+		t.Fatal(err)
+	}
+	var info struct {
+		Name  string   `json:"name"`
+		Age   int      `json:"age"`
+		Roles []string `json:"roles"`
+	}
+	if err := member.DecodeInto(&info); err != nil || info.Name != "Alice" || info.Age != 30 || len(info.Roles) != 1 {
+		t.Fatalf("decoded %+v, %v", info, err)
+	}
+	if len(member.UserInfo) != 1 || member.UserInfo["name"] != "Alice" {
+		t.Fatalf("unexpected string fields %v", member.UserInfo)
+	}
+
+	// Members built in code decode their string fields
+	member = pusher.Member{UserId: "bob", UserInfo: map[string]string{"name": "Bob"}}
+	if err := member.DecodeInto(&info); err != nil || info.Name != "Bob" {
+		t.Fatalf("decoded %+v, %v", info, err)
+	}
+}
+
+// TestSubscriptionCount receives a subscription count from the server, which
+// is kept by the channel and passed to its pusher:subscription_count binding
+func TestSubscriptionCount(t *testing.T) {
+	client, conns, _, _ := fakeClient(t, pusher.ClientConfig{}, func() error { return nil })
+	callbacks := nextConn(t, conns)
+	callbacks.OnMessage <- established
+	channel := client.Subscribe("items")
+	callbacks.OnMessage <- `{"event":"pusher_internal:subscription_succeeded","channel":"items","data":"{}"}`
+
+	counts := make(chan interface{}, 1)
+	channel.Bind("pusher:subscription_count", func(data interface{}) { counts <- data })
+	callbacks.OnMessage <- `{"event":"pusher_internal:subscription_count","channel":"items","data":"{\"subscription_count\":3}"}`
+	if data := receive(t, counts); data != `{"subscription_count":3}` || channel.SubscriptionCount() != 3 {
+		t.Fatalf("received %v with a count of %v", data, channel.SubscriptionCount())
+	}
+}
+
+// TestBindOnce triggers an event twice, of which BindOnce and BindGlobalOnce
+// bindings must receive only the first
+func TestBindOnce(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "items")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	once := make(chan interface{}, 2)
+	channel.BindOnce("event", func(data interface{}) { once <- data })
+	globalOnce := make(chan interface{}, 2)
+	client.BindGlobalOnce(func(channel, event string, data interface{}) { globalOnce <- data })
+	all := make(chan interface{}, 2)
+	channel.Bind("event", func(data interface{}) { all <- data })
+
+	srv.Trigger("items", "event", "first")
+	srv.Trigger("items", "event", "second")
+	for _, received := range []chan interface{}{once, globalOnce, all} {
+		if data := receive(t, received); data != "first" {
+			t.Fatalf("received %v first", data)
+		}
+	}
+	if data := receive(t, all); data != "second" {
+		t.Fatalf("received %v second", data)
+	}
+	select {
+	case data := <-once:
+		t.Fatalf("BindOnce received %v", data)
+	case data := <-globalOnce:
+		t.Fatalf("BindGlobalOnce received %v", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestBindAll receives every event on one channel, and none on others
+func TestBindAll(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "items")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	if _, err := client.SubscribeWithResult(ctx, "other"); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	received := make(chan interface{}, 3)
+	channel.BindAll(func(event, data string) { received <- event + " " + data })
+	srv.Trigger("other", "created", "ignored")
+	srv.Trigger("items", "created", "one")
+	srv.Trigger("items", "updated", map[string]int{"id": 1})
+	for _, expected := range []string{"created one", `updated {"id":1}`} {
+		if event := receive(t, received); event != expected {
+			t.Fatalf("received %q, expected %q", event, expected)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package pusher
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ChaosConfig injects faults into the frames a client receives, so that
+// applications can verify their resilience to a misbehaving realtime link. It
+// is only active when set on ClientConfig, and must never be used in
+// production.
+type ChaosConfig struct {
+	// Seed makes the sequence of injected faults reproducible
+	Seed int64
+	// DelayProbability is the chance of delaying a frame by up to MaxDelay
+	DelayProbability float64
+	MaxDelay         time.Duration
+	// DropProbability is the chance of dropping the connection on a frame
+	DropProbability float64
+	// DuplicateProbability is the chance of delivering a frame twice
+	DuplicateProbability float64
+}
+
+type chaos struct {
+	config ChaosConfig
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func newChaos(config *ChaosConfig) *chaos {
+	if config == nil {
+		return nil
+	}
+	return &chaos{
+		config: *config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+// Faults to inject for a single frame
+type chaosFaults struct {
+	drop      bool
+	delay     time.Duration
+	duplicate bool
+}
+
+func (self *chaos) next() (faults chaosFaults) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	// Always draw every number, so that the sequence only depends on the seed
+	drop, delay, delayBy, duplicate := self.rand.Float64(), self.rand.Float64(), self.rand.Int63(), self.rand.Float64()
+
+	faults.drop = drop < self.config.DropProbability
+	if delay < self.config.DelayProbability && self.config.MaxDelay > 0 {
+		faults.delay = time.Duration(delayBy % int64(self.config.MaxDelay))
+	}
+	faults.duplicate = duplicate < self.config.DuplicateProbability
+	return
+}
+
+// apply injects faults into a received frame, returning how many times it
+// should be delivered
+func (self *chaos) apply(conn *connection) int {
+	faults := self.next()
+
+	if faults.drop {
+		conn.logs.Debugf("Chaos: dropping connection")
+		conn.transport.Close()
+		return 0
+	}
+	if faults.delay > 0 {
+		conn.logs.Debugf("Chaos: delaying frame by %v", faults.delay)
+		time.Sleep(faults.delay)
+	}
+	if faults.duplicate {
+		conn.logs.Debugf("Chaos: duplicating frame")
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,34 @@
+package pusher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChaosSeeded(t *testing.T) {
+	config := &ChaosConfig{Seed: 42, DelayProbability: 0.5, MaxDelay: time.Second, DropProbability: 0.1, DuplicateProbability: 0.5}
+	first, second := newChaos(config), newChaos(config)
+	for i := 0; i < 100; i++ {
+		if a, b := first.next(), second.next(); a != b {
+			t.Fatalf("frame %v: %+v differs from %+v with the same seed", i, a, b)
+		}
+	}
+}
+
+func TestChaosProbabilities(t *testing.T) {
+	if newChaos(nil) != nil {
+		t.Fatal("expected no chaos without a config")
+	}
+
+	never := newChaos(&ChaosConfig{Seed: 1, MaxDelay: time.Second})
+	always := newChaos(&ChaosConfig{Seed: 1, DelayProbability: 1, MaxDelay: time.Second, DropProbability: 1, DuplicateProbability: 1})
+	for i := 0; i < 100; i++ {
+		if faults := never.next(); faults != (chaosFaults{}) {
+			t.Fatalf("unexpected faults %+v", faults)
+		}
+		faults := always.next()
+		if !faults.drop || !faults.duplicate || faults.delay < 0 || faults.delay >= time.Second {
+			t.Fatalf("unexpected faults %+v", faults)
+		}
+	}
+}
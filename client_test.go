@@ -0,0 +1,341 @@
+package pusher_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// receive waits for the next event on events
+func receive(t *testing.T, events <-chan interface{}) interface{} {
+	t.Helper()
+	select {
+	case data := <-events:
+		return data
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return nil
+	}
+}
+
+// subscribeFacades subscribes two facades of a new client to one channel,
+// counting the pusher:subscribe messages sent
+func subscribeFacades(t *testing.T, srv *pushertest.Server) (root, first, second *pusher.Client, subscribes *atomic.Int32) {
+	root = pusher.NewWithConfig(srv.ClientConfig())
+	t.Cleanup(root.Disconnect)
+	subscribes = new(atomic.Int32)
+	root.Intercept(func(message pusher.OutgoingMessage, next func(pusher.OutgoingMessage) error) error {
+		if message.Event == "pusher:subscribe" {
+			subscribes.Add(1)
+		}
+		return next(message)
+	})
+
+	first, second = root.NewFacade(), root.NewFacade()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, facade := range []*pusher.Client{first, second} {
+		if _, err := facade.SubscribeWithResult(ctx, "shared"); err != nil {
+			t.Fatalf("subscribing: %v", err)
+		}
+	}
+	return
+}
+
+func TestFacadesShareSubscription(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	_, first, second, subscribes := subscribeFacades(t, srv)
+
+	firstEvents := make(chan interface{}, 1)
+	secondEvents := make(chan interface{}, 1)
+	first.FindChannel("shared").Bind("event", func(data interface{}) { firstEvents <- data })
+	second.FindChannel("shared").Bind("event", func(data interface{}) { secondEvents <- data })
+
+	srv.Trigger("shared", "event", "one")
+	if data := receive(t, firstEvents); data != "one" {
+		t.Fatalf("first facade received %v", data)
+	}
+	if data := receive(t, secondEvents); data != "one" {
+		t.Fatalf("second facade received %v", data)
+	}
+	if count := subscribes.Load(); count != 1 {
+		t.Fatalf("expected one pusher:subscribe, got %v", count)
+	}
+
+	first.Unsubscribe("shared")
+	srv.Trigger("shared", "event", "two")
+	if data := receive(t, secondEvents); data != "two" {
+		t.Fatalf("second facade received %v", data)
+	}
+	select {
+	case data := <-firstEvents:
+		t.Fatalf("unsubscribed facade received %v", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if count := srv.Subscribers("shared"); count != 1 {
+		t.Fatalf("expected the connection to stay subscribed, got %v subscribers", count)
+	}
+}
+
+func TestFacadeDisconnectReleasesChannels(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	_, first, second, _ := subscribeFacades(t, srv)
+
+	events := make(chan interface{}, 1)
+	second.FindChannel("shared").Bind("event", func(data interface{}) { events <- data })
+
+	first.Disconnect()
+	srv.Trigger("shared", "event", "data")
+	if data := receive(t, events); data != "data" {
+		t.Fatalf("remaining facade received %v", data)
+	}
+	if first.FindChannel("shared") != nil {
+		t.Fatal("disconnected facade still has its channel")
+	}
+}
+
+// TestFacadeAfterRootDisconnect makes requests of a facade once the client
+// owning its connection has disconnected, none of which may block
+func TestFacadeAfterRootDisconnect(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	root, first, _, _ := subscribeFacades(t, srv)
+
+	root.Disconnect()
+	done := make(chan struct{})
+	go func() {
+		first.Subscribe("other")
+		first.Unsubscribe("shared")
+		first.Shutdown(time.Second)
+		first.Disconnect()
+		if err := root.Connect(); err != pusher.ErrDisconnected {
+			t.Errorf("expected ErrDisconnected connecting again, got %v", err)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("facade blocked after the client disconnected")
+	}
+}
+
+// TestConcurrentSubscribe subscribes to one channel from several goroutines
+// at once, which must share a channel and so receive each event once
+func TestConcurrentSubscribe(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+
+	channels := make(chan *pusher.Channel, 4)
+	for i := 0; i < cap(channels); i++ {
+		go func() { channels <- client.Subscribe("x") }()
+	}
+	channel := <-channels
+	for i := 1; i < cap(channels); i++ {
+		if other := <-channels; other != channel {
+			t.Fatal("concurrent subscriptions returned different channels")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := channel.WaitSubscribed(ctx); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	events := make(chan interface{}, 4)
+	channel.Bind("event", func(data interface{}) { events <- data })
+	srv.Trigger("x", "event", "data")
+	receive(t, events)
+	select {
+	case <-events:
+		t.Fatal("event delivered more than once")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestBindChannelPattern binds to an event on the channels matching a
+// pattern, which must not receive those on other channels
+func TestBindChannelPattern(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, name := range []string{"orders-1", "orders-2", "invoices-1"} {
+		if _, err := client.SubscribeWithResult(ctx, name); err != nil {
+			t.Fatalf("subscribing: %v", err)
+		}
+	}
+
+	channels := make(chan string, 4)
+	client.BindChannelPattern("orders-*", "updated", func(channel string, data interface{}) { channels <- channel })
+	for _, name := range []string{"invoices-1", "orders-1", "orders-2"} {
+		srv.Trigger(name, "updated", "{}")
+	}
+	for _, expected := range []string{"orders-1", "orders-2"} {
+		select {
+		case channel := <-channels:
+			if channel != expected {
+				t.Fatalf("received the event on %v, expected %v", channel, expected)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for the event on %v", expected)
+		}
+	}
+}
+
+// TestUnknownTransport configures a misspelt transport, with which the client
+// must fail at once rather than retry
+func TestUnknownTransport(t *testing.T) {
+	dials := new(atomic.Int32)
+	failed := make(chan error, 1)
+	client := pusher.NewWithConfig(pusher.ClientConfig{
+		Key:        "key",
+		Transports: []string{pusher.TransportWebSocket, "xhr-streaming"},
+		NewConn: func(c pusher.ClientConfig, transport string, callbacks pusher.ConnCallbacks) (pusher.Conn, error) {
+			dials.Add(1)
+			return nil, errors.New("unreachable")
+		},
+		OnConnectionFailed: func(err error) { failed <- err },
+	})
+	defer client.Disconnect()
+
+	select {
+	case err := <-failed:
+		if !errors.Is(err, pusher.ErrUnknownTransport) || !strings.Contains(err.Error(), "xhr-streaming") {
+			t.Fatalf("expected ErrUnknownTransport naming the transport, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the client did not fail")
+	}
+	if state := client.State(); state != pusher.StateFailed {
+		t.Fatalf("expected the failed state, got %v", state)
+	}
+	if n := dials.Load(); n != 0 {
+		t.Fatalf("expected no dials, got %v", n)
+	}
+}
+
+// TestChaosDuplicates delivers every frame twice
+func TestChaosDuplicates(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.Chaos = &pusher.ChaosConfig{Seed: 1, DuplicateProbability: 1}
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "items")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	events := make(chan interface{}, 3)
+	channel.Bind("event", func(data interface{}) { events <- data })
+	srv.Trigger("items", "event", "data")
+	for i := 0; i < 2; i++ {
+		receive(t, events)
+	}
+	select {
+	case <-events:
+		t.Fatal("received the event more than twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestUnbindGlobal binds closures created by one function literal, of which
+// UnbindGlobal must remove only the one named
+func TestUnbindGlobal(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := client.SubscribeWithResult(ctx, "items"); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	received := make(chan interface{}, 4)
+	var ids []pusher.BindingID
+	for item := 0; item < 2; item++ {
+		ids = append(ids, client.BindGlobal(func(channel, event string, data interface{}) {
+			if event == "event" {
+				received <- item
+			}
+		}))
+	}
+	client.UnbindGlobal(ids[0])
+
+	// Global bindings are called in turn on the run loop, so an unbound one
+	// would be received before the second event
+	srv.Trigger("items", "event", "data")
+	if item := receive(t, received); item != 1 {
+		t.Fatalf("unbound closure %v received the event", item)
+	}
+	srv.Trigger("items", "event", "data")
+	if item := receive(t, received); item != 1 {
+		t.Fatalf("unbound closure %v received the event", item)
+	}
+}
+
+// TestDispatchWorkers blocks a handler on one channel, which must not hold up
+// the handlers of another channel or global bindings
+func TestDispatchWorkers(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.DispatchWorkers = 2
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	slow, err := client.SubscribeWithResult(ctx, "slow")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	fast, err := client.SubscribeWithResult(ctx, "fast")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	blocked, release := make(chan interface{}, 1), make(chan struct{})
+	defer close(release)
+	slow.Bind("event", func(data interface{}) {
+		blocked <- data
+		<-release
+	})
+	received := make(chan interface{}, 10)
+	fast.Bind("event", func(data interface{}) { received <- data })
+	global := make(chan interface{}, 10)
+	client.BindGlobal(func(channel, event string, data interface{}) {
+		if channel == "fast" {
+			global <- data
+		}
+	})
+
+	srv.Trigger("slow", "event", "blocking")
+	receive(t, blocked)
+	for i := 0; i < 3; i++ {
+		srv.Trigger("fast", "event", i)
+	}
+	for i := 0; i < 3; i++ {
+		if data := receive(t, received); data != fmt.Sprint(i) {
+			t.Fatalf("received %v, expected %v", data, i)
+		}
+		receive(t, global)
+	}
+}
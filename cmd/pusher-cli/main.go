@@ -0,0 +1,210 @@
+// Command pusher-cli subscribes to channels from a terminal, printing the
+// events received, and triggers client events read from standard input:
+//
+//	pusher-cli -key KEY -cluster eu -auth https://example.com/pusher/auth private-chat
+//
+// Each line of input of the form "channel event data" triggers a client
+// event, e.g.
+//
+//	private-chat client-typing {"user":"alice"}
+//
+// To sign private and presence channels locally instead of with -auth, set
+// the application secret in $PUSHER_SECRET. It is not accepted as a flag,
+// which would show it in process listings and shell history.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// options are the command line settings
+type options struct {
+	key      string
+	cluster  string
+	host     string
+	port     string
+	insecure bool
+	secret   string
+	auth     string
+	user     string
+	raw      bool
+	debug    bool
+	channels []string
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[0], os.Args[1:], os.Getenv, os.Stderr)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	config := opts.clientConfig()
+	config.OnSubscriptionError = func(channel string, err error) {
+		fmt.Fprintf(os.Stderr, "subscription to %v failed: %v\n", channel, err)
+	}
+	// UserData is set before connecting, while the run loop leaves it alone
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	client.UserData = pusher.Member{UserId: opts.user}
+	client.BindConnectionStateChange(func(previous, current pusher.ConnectionState) {
+		fmt.Fprintf(os.Stderr, "connection %v\n", current)
+	})
+	client.BindGlobalEvent(func(event pusher.Event) {
+		fmt.Print(formatEvent(event, time.Now(), opts.raw))
+	})
+
+	channels := map[string]*pusher.Channel{}
+	for _, name := range opts.channels {
+		channels[name] = client.Subscribe(name)
+	}
+	if err := client.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "connecting failed, retrying: %v\n", err)
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			name, event, data, err := parseLine(scanner.Text())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			} else if name == "" {
+				continue
+			}
+			channel, ok := channels[name]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "not subscribed to %v\n", name)
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err = channel.WaitSubscribed(ctx)
+			cancel()
+			if err == nil {
+				err = channel.Trigger(event, data)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "trigger failed: %v\n", err)
+			}
+		}
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+	client.Shutdown(2 * time.Second)
+}
+
+// parseOptions parses the command line, reporting usage and errors to output
+func parseOptions(name string, args []string, getenv func(string) string, output io.Writer) (options, error) {
+	var opts options
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&opts.key, "key", getenv("PUSHER_KEY"), "application key, defaulting to $PUSHER_KEY")
+	flags.StringVar(&opts.cluster, "cluster", getenv("PUSHER_CLUSTER"), "cluster, e.g. eu, defaulting to $PUSHER_CLUSTER")
+	flags.StringVar(&opts.host, "host", "", "WebSocket host, overriding -cluster")
+	flags.StringVar(&opts.port, "port", "443", "WebSocket port")
+	flags.BoolVar(&opts.insecure, "insecure", false, "connect with ws rather than wss")
+	flags.StringVar(&opts.auth, "auth", "", "auth endpoint for private and presence channels")
+	flags.StringVar(&opts.user, "user", "", "user ID for presence channels signed with $PUSHER_SECRET")
+	flags.BoolVar(&opts.raw, "raw", false, "print event data as received, rather than indented")
+	flags.BoolVar(&opts.debug, "debug", false, "log protocol diagnostics")
+	flags.Usage = func() {
+		fmt.Fprintf(output, "Usage: %v [flags] channel...\n", name)
+		flags.PrintDefaults()
+		fmt.Fprintln(output, "Set $PUSHER_SECRET to sign private and presence channels locally.")
+	}
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	opts.secret = getenv("PUSHER_SECRET")
+	opts.channels = flags.Args()
+	if opts.key == "" || len(opts.channels) == 0 {
+		flags.Usage()
+		return opts, errors.New("a key and at least one channel are required")
+	}
+	return opts, nil
+}
+
+// clientConfig returns the config for connecting with the options
+func (self options) clientConfig() pusher.ClientConfig {
+	config := pusher.DefaultConfig(self.key)
+	config.Port = self.port
+	config.Secret = self.secret
+	config.Debug = self.debug
+	if self.insecure {
+		config.Scheme = "ws"
+	}
+	if self.host != "" {
+		config.Host = self.host
+	} else if self.cluster != "" {
+		config.Host = "ws-" + self.cluster + ".pusher.com"
+	}
+	if self.auth != "" {
+		authorizer := pusher.NewHTTPAuthorizer(self.auth)
+		config.AuthFunc = authorizer.Authorize
+		config.PresenceAuthFunc = authorizer.AuthorizePresence
+		config.EncryptedAuthFunc = authorizer.AuthorizeEncrypted
+	}
+	return config
+}
+
+// parseLine parses a line of input of the form "channel event [data]",
+// returning an empty channel for a blank line. Data which is valid JSON is
+// sent as is, and other data as a string.
+func parseLine(line string) (channel, event string, data interface{}, err error) {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+	if len(fields) < 2 {
+		if fields[0] != "" {
+			err = errors.New("expected: channel event [data]")
+		}
+		return
+	}
+	channel, event, data = fields[0], fields[1], map[string]string{}
+	if len(fields) == 3 {
+		if json.Valid([]byte(fields[2])) {
+			data = json.RawMessage(fields[2])
+		} else {
+			data = fields[2]
+		}
+	}
+	return
+}
+
+// formatEvent formats an event received at a time for printing
+func formatEvent(event pusher.Event, at time.Time, raw bool) string {
+	data := event.Data
+	if !raw {
+		var indented bytes.Buffer
+		if json.Indent(&indented, []byte(data), "", "  ") == nil {
+			data = indented.String()
+		}
+	}
+
+	channel := event.Channel
+	if channel == "" {
+		channel = "-"
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "%v %v %v", at.Format("15:04:05.000"), channel, event.Name)
+	if event.UserId != "" {
+		fmt.Fprintf(&out, " (user %v)", event.UserId)
+	}
+	fmt.Fprintf(&out, "\n%v\n", data)
+	return out.String()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+// TestSecretFromEnv checks that the secret is read from the environment, and
+// that there is no flag which would show it in process listings
+func TestSecretFromEnv(t *testing.T) {
+	opts, err := parseOptions("pusher-cli", []string{"-key", "k", "private-x"}, env(map[string]string{"PUSHER_SECRET": "s"}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.secret != "s" || opts.clientConfig().Secret != "s" {
+		t.Fatalf("expected the secret from $PUSHER_SECRET, got %q", opts.secret)
+	}
+	if _, err := parseOptions("pusher-cli", []string{"-key", "k", "-secret", "s", "private-x"}, env(nil), io.Discard); err == nil {
+		t.Fatal("expected -secret to be rejected")
+	}
+}
+
+func TestParseOptionsRequired(t *testing.T) {
+	for _, args := range [][]string{{"channel"}, {"-key", "k"}} {
+		if _, err := parseOptions("pusher-cli", args, env(nil), io.Discard); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+	opts, err := parseOptions("pusher-cli", []string{"a", "b"}, env(map[string]string{"PUSHER_KEY": "k"}), io.Discard)
+	if err != nil || opts.key != "k" || !reflect.DeepEqual(opts.channels, []string{"a", "b"}) {
+		t.Fatalf("unexpected options %+v, %v", opts, err)
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	for _, test := range []struct {
+		opts                 options
+		scheme, host, port   string
+		fallbackHost, secret string
+	}{
+		{options{key: "k", port: "443"}, "wss", "ws.pusherapp.com", "443", "sockjs.pusher.com", ""},
+		{options{key: "k", port: "443", cluster: "eu"}, "wss", "ws-eu.pusher.com", "443", "sockjs.pusher.com", ""},
+		{options{key: "k", port: "8080", cluster: "eu", host: "localhost", insecure: true, secret: "s"}, "ws", "localhost", "8080", "sockjs.pusher.com", "s"},
+	} {
+		config := test.opts.clientConfig()
+		if config.Key != "k" || config.Scheme != test.scheme || config.Host != test.host || config.Port != test.port ||
+			config.FallbackHost != test.fallbackHost || config.Secret != test.secret {
+			t.Errorf("unexpected config %+v for %+v", config, test.opts)
+		}
+	}
+	if config := (options{key: "k", auth: "https://example.com/auth"}).clientConfig(); config.AuthFunc == nil || config.PresenceAuthFunc == nil || config.EncryptedAuthFunc == nil {
+		t.Fatal("expected -auth to set the auth functions")
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	for _, test := range []struct {
+		line           string
+		channel, event string
+		data           interface{}
+		err            bool
+	}{
+		{"", "", "", nil, false},
+		{"  ", "", "", nil, false},
+		{"private-x", "", "", nil, true},
+		{"private-x client-ping", "private-x", "client-ping", map[string]string{}, false},
+		{`private-x client-typing {"user":"alice"}`, "private-x", "client-typing", json.RawMessage(`{"user":"alice"}`), false},
+		{"private-x client-say hello world", "private-x", "client-say", "hello world", false},
+	} {
+		channel, event, data, err := parseLine(test.line)
+		if channel != test.channel || event != test.event || !reflect.DeepEqual(data, test.data) || (err != nil) != test.err {
+			t.Errorf("parseLine(%q) = %q, %q, %#v, %v", test.line, channel, event, data, err)
+		}
+	}
+}
+
+func TestFormatEvent(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 6000000, time.UTC)
+	event := pusher.Event{Name: "client-message", Channel: "presence-x", UserId: "alice", Data: `{"a":1}`}
+	if got, expected := formatEvent(event, at, false), "15:04:05.006 presence-x client-message (user alice)\n{\n  \"a\": 1\n}\n"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if got, expected := formatEvent(event, at, true), "15:04:05.006 presence-x client-message (user alice)\n{\"a\":1}\n"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	event = pusher.Event{Name: "pusher:connection_established", Data: "not json"}
+	if got, expected := formatEvent(event, at, false), "15:04:05.006 - pusher:connection_established\nnot json\n"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,32 @@
+package pusher
+
+import (
+	"encoding/json"
+)
+
+// Codec decodes event data for typed bindings, see BindT. The default codec
+// decodes JSON; others can decode payloads which carry e.g. MessagePack or
+// protobuf messages as strings.
+type Codec interface {
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func init() {
+	Codecs.Register("json", func(config json.RawMessage) (Codec, error) {
+		return jsonCodec{}, nil
+	})
+}
+
+// codec returns the configured codec, or the JSON one
+func (c ClientConfig) codec() Codec {
+	if c.Codec != nil {
+		return c.Codec
+	}
+	return jsonCodec{}
+}
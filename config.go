@@ -0,0 +1,233 @@
+//go:build !pusher_minimal
+
+package pusher
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileConfig is the format of the files read by LoadConfig. Durations are
+// strings such as "30s".
+type fileConfig struct {
+	Key            string   `json:"key" yaml:"key"`
+	Secret         string   `json:"secret" yaml:"secret"`
+	Cluster        string   `json:"cluster" yaml:"cluster"`
+	Scheme         string   `json:"scheme" yaml:"scheme"`
+	Host           string   `json:"host" yaml:"host"`
+	Port           string   `json:"port" yaml:"port"`
+	AlternateHosts []string `json:"alternate_hosts" yaml:"alternate_hosts"`
+	PathPrefix     string   `json:"path_prefix" yaml:"path_prefix"`
+	Proxy          string   `json:"proxy" yaml:"proxy"`
+	Debug          bool     `json:"debug" yaml:"debug"`
+
+	AuthEndpoint string            `json:"auth_endpoint" yaml:"auth_endpoint"`
+	AuthHeaders  map[string]string `json:"auth_headers" yaml:"auth_headers"`
+	AuthTimeout  string            `json:"auth_timeout" yaml:"auth_timeout"`
+
+	Transports        []string `json:"transports" yaml:"transports"`
+	EnableFallback    bool     `json:"enable_fallback" yaml:"enable_fallback"`
+	EnableCompression bool     `json:"enable_compression" yaml:"enable_compression"`
+
+	ActivityTimeout      string `json:"activity_timeout" yaml:"activity_timeout"`
+	MaxReconnectDelay    string `json:"max_reconnect_delay" yaml:"max_reconnect_delay"`
+	MaxReconnectAttempts int    `json:"max_reconnect_attempts" yaml:"max_reconnect_attempts"`
+
+	// Plugins, by the names they were registered with
+	Metrics     *pluginConfig  `json:"metrics" yaml:"metrics"`
+	Transformer *pluginConfig  `json:"transformer" yaml:"transformer"`
+	Codec       *pluginConfig  `json:"codec" yaml:"codec"`
+	Middleware  []pluginConfig `json:"middleware" yaml:"middleware"`
+
+	TLS *struct {
+		CAFile             string `json:"ca_file" yaml:"ca_file"`
+		CertFile           string `json:"cert_file" yaml:"cert_file"`
+		KeyFile            string `json:"key_file" yaml:"key_file"`
+		ServerName         string `json:"server_name" yaml:"server_name"`
+		InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	} `json:"tls" yaml:"tls"`
+}
+
+// pluginConfig names a plugin in a config file, with its configuration
+type pluginConfig struct {
+	Name   string                 `json:"name" yaml:"name"`
+	Config map[string]interface{} `json:"config" yaml:"config"`
+}
+
+// LoadConfig reads a client config from a JSON file, or a YAML file when its
+// extension is .yaml or .yml, so that connection settings can be managed
+// outside of code:
+//
+//	key: app-key
+//	cluster: eu
+//	auth_endpoint: https://example.com/pusher/auth
+//	activity_timeout: 60s
+//	tls:
+//	  ca_file: /etc/ssl/internal-ca.pem
+//	metrics:
+//	  name: statsd
+//	  config:
+//	    address: localhost:8125
+//
+// The metrics collector, transformer (Scrubber), codec and middleware list
+// are built from the MetricsCollectors, Transformers, Codecs and Middlewares
+// registries. The "redact" transformer, "json" codec and "drop" middleware
+// are built in; the packages registering other plugins must be imported.
+// Filters and sinks are not configured here, as they attach to channels and
+// clients: build them with Filters.New and sink.Publishers.New. Unknown
+// settings are rejected, to catch typos.
+func LoadConfig(path string) (ClientConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ClientConfig{}, err
+	}
+
+	var file fileConfig
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = decodeYAML(data, &file)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return ClientConfig{}, fmt.Errorf("pusher: invalid config %v: %w", path, err)
+	}
+
+	config, err := file.clientConfig()
+	if err != nil {
+		return ClientConfig{}, fmt.Errorf("pusher: invalid config %v: %w", path, err)
+	}
+	return config, nil
+}
+
+func (self fileConfig) clientConfig() (ClientConfig, error) {
+	config := ClientConfig{
+		Scheme:               self.Scheme,
+		Host:                 self.Host,
+		Port:                 self.Port,
+		AlternateHosts:       self.AlternateHosts,
+		PathPrefix:           self.PathPrefix,
+		Key:                  self.Key,
+		Secret:               self.Secret,
+		Proxy:                self.Proxy,
+		Debug:                self.Debug,
+		Transports:           self.Transports,
+		EnableFallback:       self.EnableFallback,
+		EnableCompression:    self.EnableCompression,
+		MaxReconnectAttempts: self.MaxReconnectAttempts,
+		FallbackHost:         fallbackHost(self.Host, self.Cluster),
+	}
+	if config.Key == "" {
+		return config, errors.New("missing key")
+	}
+	if config.Scheme == "" {
+		config.Scheme = defaultScheme
+	}
+	if config.Host == "" {
+		config.Host = clusterHost(self.Cluster)
+	}
+	if config.Port == "" {
+		config.Port = defaultPort
+	}
+
+	if err := config.checkTransports(); err != nil {
+		return config, err
+	}
+
+	var err error
+	if config.ActivityTimeout, err = parseDuration("activity_timeout", self.ActivityTimeout); err != nil {
+		return config, err
+	}
+	if config.MaxReconnectDelay, err = parseDuration("max_reconnect_delay", self.MaxReconnectDelay); err != nil {
+		return config, err
+	}
+
+	if config.Metrics, err = newPlugin(MetricsCollectors, self.Metrics); err != nil {
+		return config, err
+	}
+	if config.Scrubber, err = newPlugin(Transformers, self.Transformer); err != nil {
+		return config, err
+	}
+	if config.Codec, err = newPlugin(Codecs, self.Codec); err != nil {
+		return config, err
+	}
+	for i := range self.Middleware {
+		middleware, err := newPlugin(Middlewares, &self.Middleware[i])
+		if err != nil {
+			return config, err
+		}
+		config.Middleware = append(config.Middleware, middleware)
+	}
+
+	if self.AuthEndpoint != "" {
+		authorizer := NewHTTPAuthorizer(self.AuthEndpoint)
+		for name, value := range self.AuthHeaders {
+			authorizer.Headers.Set(name, value)
+		}
+		if authorizer.Timeout, err = parseDuration("auth_timeout", self.AuthTimeout); err != nil {
+			return config, err
+		}
+		config.AuthFunc = authorizer.Authorize
+		config.PresenceAuthFunc = authorizer.AuthorizePresence
+		config.EncryptedAuthFunc = authorizer.AuthorizeEncrypted
+	}
+
+	if self.TLS != nil {
+		config.TLSConfig = &tls.Config{
+			ServerName:         self.TLS.ServerName,
+			InsecureSkipVerify: self.TLS.InsecureSkipVerify,
+		}
+		if self.TLS.CAFile != "" {
+			pem, err := os.ReadFile(self.TLS.CAFile)
+			if err != nil {
+				return config, err
+			}
+			config.TLSConfig.RootCAs = x509.NewCertPool()
+			if !config.TLSConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return config, fmt.Errorf("no certificates in %v", self.TLS.CAFile)
+			}
+		}
+		if self.TLS.CertFile != "" || self.TLS.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(self.TLS.CertFile, self.TLS.KeyFile)
+			if err != nil {
+				return config, err
+			}
+			config.TLSConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return config, nil
+}
+
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", name, err)
+	}
+	return duration, nil
+}
+
+// newPlugin builds the plugin named in a config file, if any
+func newPlugin[T any](registry *Registry[T], plugin *pluginConfig) (T, error) {
+	var zero T
+	if plugin == nil {
+		return zero, nil
+	}
+	config, err := json.Marshal(plugin.Config)
+	if err != nil {
+		return zero, err
+	}
+	return registry.New(plugin.Name, config)
+}
@@ -0,0 +1,118 @@
+//go:build !pusher_minimal
+
+package pusher_test
+
+import (
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// writeConfig writes a config file named name, returning its path
+func writeConfig(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	config, err := pusher.LoadConfig(writeConfig(t, "pusher.json", `{
+		"key": "key",
+		"cluster": "eu",
+		"auth_endpoint": "https://example.com/auth",
+		"auth_timeout": "5s",
+		"activity_timeout": "60s",
+		"max_reconnect_delay": "10s",
+		"max_reconnect_attempts": 5,
+		"transports": ["ws", "xhr_streaming"],
+		"transformer": {"name": "redact", "config": {"fields": ["email"]}},
+		"middleware": [{"name": "drop", "config": {"events": ["client-typing"]}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Key != "key" || config.Host != "ws-eu.pusher.com" || config.Scheme != "wss" || config.Port != "443" ||
+		config.ActivityTimeout != 60*time.Second || config.MaxReconnectDelay != 10*time.Second || config.MaxReconnectAttempts != 5 ||
+		len(config.Transports) != 2 || config.AuthFunc == nil || config.PresenceAuthFunc == nil || len(config.Middleware) != 1 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if scrubbed := config.Scrubber("c", "e", `{"email":"a"}`); scrubbed != `{"email":"[redacted]"}` {
+		t.Fatalf("the redact transformer gave %v", scrubbed)
+	}
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	caFile := writeConfig(t, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+
+	for _, name := range []string{"pusher.yaml", "pusher.yml"} {
+		config, err := pusher.LoadConfig(writeConfig(t, name, `
+key: key
+host: localhost
+port: "6001"
+scheme: ws
+alternate_hosts: [backup-1, backup-2]
+debug: true
+tls:
+  ca_file: `+caFile+`
+  server_name: example.com
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Host != "localhost" || config.Port != "6001" || config.Scheme != "ws" || len(config.AlternateHosts) != 2 || !config.Debug {
+			t.Fatalf("unexpected config %+v", config)
+		}
+		if config.TLSConfig == nil || config.TLSConfig.ServerName != "example.com" || config.TLSConfig.RootCAs == nil {
+			t.Fatalf("unexpected TLS config %+v", config.TLSConfig)
+		}
+	}
+}
+
+// TestLoadConfigFallbackHost serves the HTTP fallback from a custom host
+// itself, and otherwise from Pusher's SockJS host for the cluster
+func TestLoadConfigFallbackHost(t *testing.T) {
+	for contents, fallbackHost := range map[string]string{
+		`{"key": "key", "enable_fallback": true}`:                                              "sockjs.pusher.com",
+		`{"key": "key", "enable_fallback": true, "cluster": "eu"}`:                             "sockjs-eu.pusher.com",
+		`{"key": "key", "enable_fallback": true, "host": "pusher.example.com"}`:                "pusher.example.com",
+		`{"key": "key", "enable_fallback": true, "cluster": "eu", "host": "example.com:6001"}`: "example.com:6001",
+	} {
+		config, err := pusher.LoadConfig(writeConfig(t, "pusher.json", contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.FallbackHost != fallbackHost {
+			t.Errorf("%v has fallback host %q, expected %q", contents, config.FallbackHost, fallbackHost)
+		}
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for name, contents := range map[string]string{
+		"missing-key.json": `{"cluster": "eu"}`,
+		"unknown.json":     `{"key": "key", "clustre": "eu"}`,
+		"unknown.yaml":     "key: key\nclustre: eu\n",
+		"duration.json":    `{"key": "key", "activity_timeout": "soon"}`,
+		"transport.json":   `{"key": "key", "transports": ["carrier-pigeon"]}`,
+		"plugin.json":      `{"key": "key", "codec": {"name": "unregistered"}}`,
+		"ca.json":          `{"key": "key", "tls": {"ca_file": "/nonexistent/ca.pem"}}`,
+		"syntax.json":      `{"key":`,
+	} {
+		if _, err := pusher.LoadConfig(writeConfig(t, name, contents)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected an error naming %v, got %v", name, err)
+		}
+	}
+	if _, err := pusher.LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error reading a missing file")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !pusher_minimal
+
+package pusher
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYAML decodes a YAML config file, rejecting unknown settings
+func decodeYAML(data []byte, file *fileConfig) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(file)
+}
@@ -1,13 +1,18 @@
 package pusher
 
 import (
-	// "fmt"
-	"github.com/gorilla/websocket"
-	"log"
+	"encoding/json"
+	"errors"
+	"net"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Debug enables diagnostics for every client, see ClientConfig.Debug to
+// enable them for a single client
 var Debug = false
 
 const (
@@ -18,28 +23,55 @@ const (
 	// Same as defined in websocket
 	writeWait = time.Second
 
-	// Send pongs after this time. The server inactivity timeout is 120s.
+	// Send pings after this time without activity, unless the server asks
+	// for less. The server inactivity timeout is 120s.
 	defaultInactivityTimeout = 100 * time.Second
 
 	// Wait this long for pong replies before closing the connection
 	pongTimeout = 5000 * time.Millisecond
 )
 
-type connCallbacks struct {
-	onMessage    chan<- string
-	onClose      chan<- bool
-	onDisconnect chan bool
+// Conn is a connection to Pusher as driven by the client's run loop. It
+// writes the messages it is sent, delivers those it receives through its
+// ConnCallbacks, and keeps itself alive. Set ClientConfig.NewConn to
+// substitute an implementation, e.g. in tests or for another transport.
+type Conn interface {
+	// Send queues a message to be written. It is called from the run loop,
+	// so must not block for long, and returns ErrConnectionClosed once the
+	// connection has closed.
+	Send(message []byte) error
+	// SetActivityTimeout applies the activity timeout requested by the
+	// server in pusher:connection_established
+	SetActivityTimeout(timeout time.Duration)
+	// Shutdown writes the queued messages followed by messages, and then
+	// closes the connection, closing the returned channel once done
+	Shutdown(messages [][]byte) <-chan struct{}
+	// Close closes the connection at once. Nothing is delivered to the
+	// callbacks afterwards.
+	Close() error
 }
 
+// ConnCallbacks are how a Conn delivers what it receives to the run loop
+type ConnCallbacks struct {
+	// OnMessage is sent every message received
+	OnMessage chan<- string
+	// OnClose is sent the error which ended the connection, unless it was
+	// ended by Close
+	OnClose chan<- error
+}
+
+// NewConnFunc opens a connection using the named transport
+type NewConnFunc func(c ClientConfig, transport string, callbacks ConnCallbacks) (Conn, error)
+
 // Connection responsibilities:
 //
-// * Providing a channel based interface on top of the WebSocket library
-// * Connecting to the Pusher WebSocket interface
+// * Providing a channel based interface on top of a transport
+// * Connecting to the Pusher WebSocket interface, or its HTTP fallback
 // * Triggering pings on periods of inactivity, and disconnecting if server does not reply
 // * Exposing disconnect reason
 //
 type connection struct {
-	config *connCallbacks
+	callbacks ConnCallbacks
 
 	inactivityTimeout time.Duration
 
@@ -47,79 +79,293 @@ type connection struct {
 	_onMessage   chan string
 	_onPingPong  chan bool
 	_onClose     chan error
-	ws           *websocket.Conn
-	socketID     string
-	connected    bool
+	_shutdown    chan shutdown
+	_setTimeout  chan time.Duration
+	_closed      chan struct{}
+	closeOnce    sync.Once
+	transport    transport
+	chaos        *chaos
+	recorder     *recorder
+	scrubber     Scrubber
+	logs         Logger
+	collector    MetricsCollector
+	counters     *byteCounters
+	// _done is closed once runLoop has returned, after which nothing
+	// receives from the channels above
+	_done chan struct{}
+
+	// Connections of ManualPoll clients have no run loop: they are written
+	// to, and their heartbeat driven, from the client's run loop. Only
+	// readLoop runs on a goroutine of its own, as reading blocks.
+	manual       bool
+	writeMutex   sync.Mutex
+	lastActivity atomic.Int64
+	awaitingPong bool
+	pingSent     time.Time
 }
 
-func dial(c ClientConfig, conf *connCallbacks) (conn *connection, err error) {
-	baseURL := c.Scheme + "://" + c.Host + ":" + c.Port + "/app/" + c.Key
+// BuildURL returns the URL which a client created with the given config will
+// dial, which is useful when debugging proxy or gateway routing
+func BuildURL(c ClientConfig) (string, error) {
+	if c.Key == "" {
+		return "", errors.New("pusher: missing application key")
+	}
+
+	prefix := strings.TrimRight(c.PathPrefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+
+	// IPv6 hosts may be given with or without brackets, and hosts may carry
+	// their own port, as AlternateHosts do
+	host, port := strings.Trim(c.Host, "[]"), c.Port
+	if name, hostPort, err := net.SplitHostPort(c.Host); err == nil {
+		host, port = name, hostPort
+	}
+	if host == "" {
+		return "", errors.New("pusher: missing host")
+	}
+	if port == "" {
+		return "", errors.New("pusher: missing port")
+	}
+	baseURL := c.Scheme + "://" + net.JoinHostPort(host, port) + prefix + "/app/" + url.PathEscape(c.Key)
 
 	params := url.Values{}
 	params.Set("protocol", pusherProtocol)
 	params.Set("client", clientName)
 	params.Set("version", clientVersion)
+	for name, values := range c.QueryParams {
+		params[name] = values
+	}
+
+	u, err := url.Parse(baseURL + "?" + params.Encode())
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+func dial(c ClientConfig, transportName string, callbacks ConnCallbacks, chaos *chaos, recorder *recorder) (conn *connection, err error) {
+	conn = newConnection(c, callbacks, chaos, recorder)
+
+	// TODO: Is this blocking as it connects?
+
+	dialContext, err := c.dialContext()
+	if err != nil {
+		return nil, err
+	}
+	c.DialContext = conn.counters.dialer(dialContext)
+
+	if conn.transport, err = dialTransport(c, transportName, conn.onActivity); err != nil {
+		return nil, err
+	}
+	recorder.record(FrameOpen, transportName)
 
-	url := baseURL + "?" + params.Encode()
+	go conn.readLoop()
+	if !conn.manual {
+		go conn.runLoop()
+	}
 
-	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	return
+}
 
-	conn = &connection{
-		inactivityTimeout: defaultInactivityTimeout,
-		config:            conf,
+// newConnection returns a connection yet to be given its transport
+func newConnection(c ClientConfig, callbacks ConnCallbacks, chaos *chaos, recorder *recorder) *connection {
+	conn := &connection{
+		chaos:             chaos,
+		recorder:          recorder,
+		scrubber:          c.Scrubber,
+		logs:              c.logger(),
+		collector:         c.metrics(),
+		counters:          &byteCounters{},
+		inactivityTimeout: c.activityTimeout(),
+		callbacks:         callbacks,
 		_sendMessage:      make(chan []byte, 10),
 		_onMessage:        make(chan string),
 		_onPingPong:       make(chan bool),
-		_onClose:          make(chan error),
-		ws:                ws,
+		_onClose:          make(chan error, 1),
+		_shutdown:         make(chan shutdown, 1),
+		_setTimeout:       make(chan time.Duration, 1),
+		_closed:           make(chan struct{}),
+		_done:             make(chan struct{}),
+		manual:            c.ManualPoll,
 	}
+	conn.lastActivity.Store(time.Now().UnixNano())
+	return conn
+}
 
-	// TODO: Is this blocking as it connects?
+func (self *connection) onActivity() {
+	if self.manual {
+		self.lastActivity.Store(time.Now().UnixNano())
+		return
+	}
+	select {
+	case self._onPingPong <- true:
+	case <-self._done:
+	}
+}
 
-	if err == nil {
-		ws.SetPingHandler(func(msg string) error {
-			// TODO: Check that this is safe
-			ws.WriteControl(websocket.PongMessage, []byte(msg), time.Now().Add(writeWait))
-			conn._onPingPong <- true
-			return nil
-		})
+func (self *connection) Send(message []byte) error {
+	if self.manual {
+		if self.closed() {
+			return ErrConnectionClosed
+		}
+		self.write(message)
+		return nil
+	}
+	select {
+	case self._sendMessage <- message:
+		return nil
+	case <-self._done:
+		return ErrConnectionClosed
+	}
+}
 
-		ws.SetPongHandler(func(msg string) error {
-			conn._onPingPong <- true
-			return nil
-		})
+// SetActivityTimeout applies the activity timeout requested by the server in
+// pusher:connection_established, if shorter than the configured one
+func (self *connection) SetActivityTimeout(timeout time.Duration) {
+	if self.manual {
+		if timeout > 0 && timeout < self.inactivityTimeout {
+			self.inactivityTimeout = timeout
+		}
+		return
+	}
+	select {
+	case self._setTimeout <- timeout:
+	case <-self._done:
+	}
+}
 
-		go conn.readLoop()
-		go conn.runLoop()
+func (self *connection) Shutdown(messages [][]byte) <-chan struct{} {
+	done := make(chan struct{})
+	if self.manual {
+		for _, msg := range messages {
+			self.write(msg)
+		}
+		self.Close()
+		close(done)
+		return done
 	}
+	self._shutdown <- shutdown{messages: messages, done: done}
+	return done
+}
 
+// heartbeat does the work of the run loop's ping timer for a manual
+// connection, sending a ping after a period of inactivity and closing the
+// connection if no reply follows. It returns the delay until it is next due.
+func (self *connection) heartbeat() time.Duration {
+	now := time.Now()
+	last := time.Unix(0, self.lastActivity.Load())
+	if self.awaitingPong {
+		waited := now.Sub(self.pingSent)
+		switch {
+		case last.After(self.pingSent):
+			self.collector.PingRTT(last.Sub(self.pingSent))
+			self.awaitingPong = false
+		case waited >= pongTimeout:
+			self.logs.Errorf("Closing after non-receipt of pong")
+			self.transport.Close()
+			return pongTimeout
+		default:
+			return pongTimeout - waited
+		}
+	}
+
+	if idle := now.Sub(last); idle < self.inactivityTimeout {
+		return self.inactivityTimeout - idle
+	}
+	self.logs.Debugf("No activity in %v, sending ping", self.inactivityTimeout)
+	ping, _ := encode("pusher:ping", map[string]string{}, nil)
+	self.write(ping)
+	self.pingSent = now
+	self.awaitingPong = true
+	return pongTimeout
+}
+
+func (self *connection) Close() (err error) {
+	self.closeOnce.Do(func() {
+		close(self._closed)
+		err = self.transport.Close()
+	})
 	return
 }
 
-func (self *connection) send(message []byte) {
-	self._sendMessage <- message
+// closed reports whether Close has been called. It is checked before each
+// delivery to the callbacks, as a select between the delivery and _closed
+// picks at random when both are ready.
+func (self *connection) closed() bool {
+	select {
+	case <-self._closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdown asks a connection to send its queued messages followed by
+// messages, and then to close. done is closed once all have been written.
+type shutdown struct {
+	messages [][]byte
+	done     chan struct{}
+}
+
+// write sends a message on the transport
+func (self *connection) write(msg []byte) {
+	self.writeMutex.Lock()
+	defer self.writeMutex.Unlock()
+	self.logs.Debugf("Sending: %v", scrubMessage(self.scrubber, msg))
+	err := self.transport.WriteMessage(msg)
+	self.recorder.record(FrameOut, string(msg))
+	self.counters.messageOut.Add(int64(len(msg)))
+
+	if err != nil {
+		self.logs.Errorf("Error sending: %v", err)
+	}
 }
 
 func (self *connection) readLoop() {
-	ws := self.ws
-	for {
+	// Manual connections deliver straight to the client's run loop
+	var messages chan<- string = self._onMessage
+	if self.manual {
+		messages = self.callbacks.OnMessage
+	}
 
-		if _, msg, err := ws.ReadMessage(); err == nil {
-			self._onMessage <- string(msg)
-		} else {
-			// TODO: Read the close code
+	for {
 
-			if err.Error() == "EOF" {
-				if Debug {
-					log.Print("Disconnected")
+		if msg, err := self.transport.ReadMessage(); err == nil {
+			self.lastActivity.Store(time.Now().UnixNano())
+			self.counters.messageIn.Add(int64(len(msg)))
+			self.recorder.record(FrameIn, string(msg))
+			deliveries := 1
+			if self.chaos != nil {
+				deliveries = self.chaos.apply(self)
+			}
+			for i := 0; i < deliveries; i++ {
+				if self.closed() {
+					return
 				}
-			} else {
-				if Debug {
-					log.Print("Closed: ", err)
+				select {
+				case messages <- string(msg):
+				case <-self._closed:
+					return
 				}
-				self._onClose <- err
 			}
-
+		} else {
+			self.logs.Infof("Closed: %v", err)
+			// Once closed, the error is the result of Close
+			if self.closed() {
+				return
+			}
+			self.recorder.recordClose(err)
+			if self.manual {
+				select {
+				case self.callbacks.OnClose <- err:
+				case <-self._closed:
+				}
+				return
+			}
+			self._onClose <- err
 			return
 		}
 
@@ -127,68 +373,107 @@ func (self *connection) readLoop() {
 }
 
 func (self *connection) runLoop() {
+	defer close(self._done)
 	pingTimer := time.NewTimer(self.inactivityTimeout)
 	awaitingPong := false
+	var pingSent time.Time
 
 	afterActivity := func() {
 		pingTimer.Reset(self.inactivityTimeout)
 		awaitingPong = false
 	}
 
-	ws := self.ws
-
 	for {
 		select {
 		case <-pingTimer.C:
 			if awaitingPong == false {
-				if Debug {
-					log.Printf("No activity in %v, sending ping", self.inactivityTimeout)
-				}
-				ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+				self.logs.Debugf("No activity in %v, sending ping", self.inactivityTimeout)
+				ping, _ := encode("pusher:ping", map[string]string{}, nil)
+				self.write(ping)
+				pingSent = time.Now()
 
 				// Wait a further pong timeout
 				pingTimer.Reset(pongTimeout)
 				awaitingPong = true
 			} else {
-				if Debug {
-					log.Print("Closing after non-receipt of pong")
-				}
-				ws.Close()
+				self.logs.Errorf("Closing after non-receipt of pong")
+				self.transport.Close()
 			}
 
-		case <-self._onClose:
-			if self.config.onClose != nil {
-				self.config.onClose <- true
+		case err := <-self._onClose:
+			if self.callbacks.OnClose != nil && !self.closed() {
+				select {
+				case self.callbacks.OnClose <- err:
+				case <-self._closed:
+				}
 			}
 			return
 
-		case <-self.config.onDisconnect:
-			ws.WriteControl(websocket.CloseMessage, nil, time.Now().Add(writeWait))
-			if Debug {
-				log.Print("Disconnecting...")
-			}
+		case <-self._closed:
+			self.logs.Debugf("Disconnecting...")
 			return
 
 		case msg := <-self._onMessage:
+			if awaitingPong && eventName(msg) == "pusher:pong" {
+				self.collector.PingRTT(time.Since(pingSent))
+			}
 			afterActivity()
 
-			if self.config.onMessage != nil {
-				self.config.onMessage <- msg
+			if self.closed() {
+				return
+			}
+			if self.callbacks.OnMessage != nil {
+				select {
+				case self.callbacks.OnMessage <- msg:
+				case <-self._closed:
+					return
+				}
 			}
 		case <-self._onPingPong:
 			afterActivity()
 
-		case msg := <-self._sendMessage:
-			if Debug {
-				log.Print("Sending: ", string(msg))
+		case timeout := <-self._setTimeout:
+			if timeout > 0 && timeout < self.inactivityTimeout {
+				self.inactivityTimeout = timeout
+				afterActivity()
 			}
-			err := ws.WriteMessage(websocket.TextMessage, msg)
 
-			if err != nil {
-				if Debug {
-					log.Print("Error sending: ", err)
+		case msg := <-self._sendMessage:
+			self.write(msg)
+
+		case s := <-self._shutdown:
+			for queued := true; queued; {
+				select {
+				case msg := <-self._sendMessage:
+					self.write(msg)
+				default:
+					queued = false
 				}
 			}
+			for _, msg := range s.messages {
+				self.write(msg)
+			}
+			self.Close()
+			close(s.done)
+			return
 		}
 	}
 }
+
+// eventName returns the name of the event a message carries, or "" if it
+// cannot be decoded
+func eventName(message string) string {
+	var event struct {
+		Name string `json:"event"`
+	}
+	json.Unmarshal([]byte(message), &event)
+	return event.Name
+}
+
+// activityTimeout returns the configured activity timeout, or the default
+func (c ClientConfig) activityTimeout() time.Duration {
+	if c.ActivityTimeout > 0 {
+		return c.ActivityTimeout
+	}
+	return defaultInactivityTimeout
+}
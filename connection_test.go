@@ -0,0 +1,124 @@
+package pusher
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// closedTransport is a transport which the server has closed
+type closedTransport struct{}
+
+func (closedTransport) ReadMessage() ([]byte, error)  { return nil, errors.New("closed by server") }
+func (closedTransport) WriteMessage(msg []byte) error { return nil }
+func (closedTransport) Close() error                  { return nil }
+
+// TestSendAfterServerClose sends more messages than a connection buffers once
+// the server has closed it, which must fail rather than block
+func TestSendAfterServerClose(t *testing.T) {
+	onClose := make(chan error, 1)
+	conn := newConnection(ClientConfig{}, ConnCallbacks{OnClose: onClose}, nil, nil)
+	conn.transport = closedTransport{}
+	go conn.readLoop()
+	go conn.runLoop()
+	select {
+	case <-onClose:
+	case <-time.After(time.Second):
+		t.Fatal("the close was not delivered")
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 20 && err == nil; i++ {
+			err = conn.Send([]byte("{}"))
+		}
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != ErrConnectionClosed {
+			t.Fatalf("expected ErrConnectionClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send blocked on a closed connection")
+	}
+}
+
+// TestActivityAfterServerClose reports activity once the server has closed
+// the connection, as a transport may for a frame already read, which must
+// not block
+func TestActivityAfterServerClose(t *testing.T) {
+	onClose := make(chan error, 1)
+	conn := newConnection(ClientConfig{}, ConnCallbacks{OnClose: onClose}, nil, nil)
+	conn.transport = closedTransport{}
+	go conn.readLoop()
+	go conn.runLoop()
+	<-onClose
+
+	done := make(chan struct{})
+	go func() {
+		conn.onActivity()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("onActivity blocked on a closed connection")
+	}
+}
+
+func TestEventName(t *testing.T) {
+	for message, name := range map[string]string{
+		`{"event":"pusher:pong","data":"{}"}`:                                 "pusher:pong",
+		`{"event":"client-chat","data":"{\"text\":\"\\\"pusher:pong\\\"\"}"}`: "client-chat",
+		`"pusher:pong"`: "",
+		`{`:             "",
+	} {
+		if got := eventName(message); got != name {
+			t.Errorf("eventName(%v) = %q, expected %q", message, got, name)
+		}
+	}
+}
+
+func TestBuildURL(t *testing.T) {
+	const query = "?client=pusher-websocket-go&protocol=7&version=0.0.1"
+	for _, test := range []struct {
+		host, port string
+		url        string
+		err        bool
+	}{
+		{"ws.pusherapp.com", "443", "wss://ws.pusherapp.com:443/app/key" + query, false},
+		{"::1", "443", "wss://[::1]:443/app/key" + query, false},
+		{"[::1]", "443", "wss://[::1]:443/app/key" + query, false},
+		{"[::1]:8080", "443", "wss://[::1]:8080/app/key" + query, false},
+		{"example.com:8080", "443", "wss://example.com:8080/app/key" + query, false},
+		{"example.com:8080", "", "wss://example.com:8080/app/key" + query, false},
+		{"example.com", "", "", true},
+		{"", "443", "", true},
+	} {
+		url, err := BuildURL(ClientConfig{Scheme: "wss", Host: test.host, Port: test.port, Key: "key"})
+		if url != test.url || (err != nil) != test.err {
+			t.Errorf("BuildURL with host %q and port %q = %q, %v", test.host, test.port, url, err)
+		}
+	}
+	if _, err := BuildURL(ClientConfig{Scheme: "wss", Host: "example.com", Port: "443"}); err == nil {
+		t.Error("expected a missing key to be rejected")
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	for prefix, path := range map[string]string{
+		"":                "/app/key",
+		"/":               "/app/key",
+		"pusher":          "/pusher/app/key",
+		"/pusher":         "/pusher/app/key",
+		"/pusher/":        "/pusher/app/key",
+		"gateway/pusher/": "/gateway/pusher/app/key",
+	} {
+		url, err := BuildURL(ClientConfig{Scheme: "wss", Host: "example.com", Port: "443", Key: "key", PathPrefix: prefix})
+		if expected := "wss://example.com:443" + path + "?client=pusher-websocket-go&protocol=7&version=0.0.1"; url != expected || err != nil {
+			t.Errorf("BuildURL with prefix %q = %q, %v, expected %q", prefix, url, err, expected)
+		}
+	}
+}
@@ -2,10 +2,12 @@ package pusher
 
 import (
 	"crypto/hmac"
-	// "crypto/md5"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
 )
 
 func hmacSignature(toSign, secret string) string {
@@ -22,3 +24,53 @@ func createAuthString(key, secret, stringToSign string) string {
 	authSignature := hmacSignature(stringToSign, secret)
 	return strings.Join([]string{key, authSignature}, ":")
 }
+
+// GenerateAuth returns the auth signature for a subscription to a private
+// channel, or with channelData, to a presence channel, for use by auth
+// endpoints. channelData must be sent to the client exactly as signed.
+func GenerateAuth(key, secret, socketID, channel string, channelData *string) string {
+	stringToSign := socketID + ":" + channel
+	if channelData != nil {
+		stringToSign += ":" + *channelData
+	}
+	return createAuthString(key, secret, stringToSign)
+}
+
+// CryptoProvider performs the client's cryptographic operations. The default
+// provider signs with ClientConfig.Secret in process; regulated deployments
+// can substitute one backed by a FIPS validated module, or which signs
+// through an HSM or KMS so that the secret never enters the process.
+type CryptoProvider interface {
+	// Sign returns the hex encoded HMAC-SHA256 of stringToSign, keyed with
+	// the app secret
+	Sign(stringToSign string) (string, error)
+	// Open authenticates and decrypts a NaCl secretbox
+	Open(box []byte, nonce *[24]byte, key *[32]byte) ([]byte, error)
+}
+
+type secretCrypto struct {
+	secret string
+}
+
+func (self secretCrypto) Sign(stringToSign string) (string, error) {
+	if self.secret == "" {
+		return "", errors.New("pusher: no secret to sign with")
+	}
+	return hmacSignature(stringToSign, self.secret), nil
+}
+
+func (self secretCrypto) Open(box []byte, nonce *[24]byte, key *[32]byte) ([]byte, error) {
+	message, ok := secretbox.Open(nil, box, nonce, key)
+	if !ok {
+		return nil, errors.New("pusher: failed to decrypt secretbox")
+	}
+	return message, nil
+}
+
+// crypto returns the configured crypto provider, or the default one
+func (c ClientConfig) crypto() CryptoProvider {
+	if c.Crypto != nil {
+		return c.Crypto
+	}
+	return secretCrypto{secret: c.Secret}
+}
@@ -0,0 +1,28 @@
+package pusher
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+func TestSecretCrypto(t *testing.T) {
+	if _, err := (ClientConfig{}).crypto().Sign("1.2:private-x"); err == nil {
+		t.Fatal("expected an error signing without a secret")
+	}
+	if signature, err := (ClientConfig{Secret: "secret"}).crypto().Sign("1.2:private-x"); err != nil || signature != hmacSignature("1.2:private-x", "secret") {
+		t.Fatalf("unexpected signature %v, %v", signature, err)
+	}
+
+	var nonce [24]byte
+	var key [32]byte
+	key[0] = 1
+	box := secretbox.Seal(nil, []byte("plaintext"), &nonce, &key)
+	if message, err := (secretCrypto{}).Open(box, &nonce, &key); err != nil || string(message) != "plaintext" {
+		t.Fatalf("unexpected message %q, %v", message, err)
+	}
+	key[0] = 2
+	if _, err := (secretCrypto{}).Open(box, &nonce, &key); err == nil {
+		t.Fatal("expected an error opening with the wrong key")
+	}
+}
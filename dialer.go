@@ -0,0 +1,51 @@
+package pusher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// DialContextFunc establishes a network connection, like
+// net.Dialer.DialContext
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialContext returns the function used to establish the underlying TCP
+// connections of every transport
+func (c ClientConfig) dialContext() (DialContextFunc, error) {
+	if c.DialContext != nil {
+		return c.DialContext, nil
+	}
+
+	dialer := &net.Dialer{}
+
+	if c.LocalAddr != "" {
+		ip := net.ParseIP(c.LocalAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("pusher: invalid local address %q", c.LocalAddr)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	return dialer.DialContext, nil
+}
+
+// proxy returns the function choosing the proxy of each transport request
+func (c ClientConfig) proxy() (func(*http.Request) (*url.URL, error), error) {
+	if c.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(c.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("pusher: invalid proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("pusher: unsupported proxy scheme %q", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
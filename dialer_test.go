@@ -0,0 +1,372 @@
+package pusher_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// connectProxy is an HTTP proxy tunnelling CONNECT requests, which sends the
+// credentials of each to auths
+func connectProxy(t *testing.T) (proxy *httptest.Server, auths chan string) {
+	auths = make(chan string, 10)
+	proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		auth, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Header.Get("Proxy-Authorization"), "Basic "))
+		auths <- string(auth)
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, buffered, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go pipe(conn, upstream, buffered)
+	}))
+	t.Cleanup(proxy.Close)
+	return
+}
+
+// pipe copies between a client connection and upstream until either closes
+func pipe(conn, upstream net.Conn, buffered io.Reader) {
+	defer conn.Close()
+	defer upstream.Close()
+	go io.Copy(conn, upstream)
+	io.Copy(upstream, buffered)
+}
+
+func TestHTTPProxy(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	proxy, auths := connectProxy(t)
+	config := srv.ClientConfig()
+	config.Proxy = "http://user:password@" + strings.TrimPrefix(proxy.URL, "http://")
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	if auth := <-auths; auth != "user:password" {
+		t.Fatalf("proxy received credentials %q", auth)
+	}
+}
+
+func TestInvalidProxy(t *testing.T) {
+	for _, proxy := range []string{"ftp://proxy:21", "http://[::1"} {
+		config := pusher.DefaultConfig("key")
+		config.Proxy = proxy
+		config.DisableAutoConnect = true
+		client := pusher.NewWithConfig(config)
+		if err := client.Connect(); err == nil || !strings.Contains(err.Error(), "proxy") {
+			t.Errorf("expected an error connecting through %v, got %v", proxy, err)
+		}
+		client.Disconnect()
+	}
+}
+
+// socksProxy is a SOCKS5 proxy requiring username and password
+// authentication, which sends the credentials and target of each connection
+// to requests
+func socksProxy(t *testing.T) (addr string, requests chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	requests = make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS(conn, requests)
+		}
+	}()
+	return listener.Addr().String(), requests
+}
+
+func serveSOCKS(conn net.Conn, requests chan string) {
+	// Reads past a failure return zeros, and the connection is closed
+	read := func(n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			conn.Close()
+		}
+		return buf
+	}
+
+	// Greeting, choosing username and password authentication
+	read(int(read(2)[1]))
+	conn.Write([]byte{5, 2})
+	user := read(int(read(2)[1]))
+	password := read(int(read(1)[0]))
+	conn.Write([]byte{1, 0})
+
+	// Connect request, with the target as a domain name or IPv4 address
+	request := read(4)
+	var host string
+	switch request[3] {
+	case 1:
+		host = net.IP(read(4)).String()
+	case 3:
+		host = string(read(int(read(1)[0])))
+	}
+	port := read(2)
+	target := net.JoinHostPort(host, fmt.Sprint(int(port[0])<<8|int(port[1])))
+	requests <- string(user) + ":" + string(password) + "@" + target
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	pipe(conn, upstream, conn)
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	for _, scheme := range []string{"socks5", "socks5h"} {
+		addr, requests := socksProxy(t)
+		config := srv.ClientConfig()
+		config.Proxy = scheme + "://user:password@" + addr
+		config.DisableAutoConnect = true
+		client := pusher.NewWithConfig(config)
+
+		if err := client.Connect(); err != nil {
+			t.Fatalf("connecting through %v: %v", scheme, err)
+		}
+		if request := <-requests; request != "user:password@"+config.Host+":"+config.Port {
+			t.Fatalf("%v proxy received %q", scheme, request)
+		}
+		client.Disconnect()
+	}
+}
+
+// tlsServer serves the fake server over TLS with a self-signed certificate,
+// for example.com and 127.0.0.1, returning its config and the certificate's
+// pool
+func tlsServer(t *testing.T, srv *pushertest.Server) (pusher.ClientConfig, *x509.CertPool) {
+	config := srv.ClientConfig()
+	backend := &url.URL{Scheme: "http", Host: net.JoinHostPort(config.Host, config.Port)}
+	frontend := httptest.NewTLSServer(httputil.NewSingleHostReverseProxy(backend))
+	t.Cleanup(frontend.Close)
+
+	config.Scheme = "wss"
+	config.Host, config.Port, _ = net.SplitHostPort(strings.TrimPrefix(frontend.URL, "https://"))
+	config.DisableAutoConnect = true
+	return config, frontend.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+}
+
+func TestTLSConfig(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config, roots := tlsServer(t, srv)
+
+	for _, test := range []struct {
+		tls       *tls.Config
+		connected bool
+	}{
+		{nil, false},
+		{&tls.Config{RootCAs: roots}, true},
+		{&tls.Config{RootCAs: roots, ServerName: "example.com"}, true},
+		{&tls.Config{RootCAs: roots, ServerName: "other.example"}, false},
+	} {
+		config.TLSConfig = test.tls
+		client := pusher.NewWithConfig(config)
+		if err := client.Connect(); (err == nil) != test.connected {
+			t.Errorf("connecting with %+v: %v", test.tls, err)
+		}
+		client.Disconnect()
+	}
+}
+
+// TestDialContext routes connections to an unresolvable host through the
+// injected dialer
+func TestDialContext(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	target := net.JoinHostPort(config.Host, config.Port)
+	dialed := make(chan string, 10)
+	config.Host = "pusher.invalid"
+	config.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed <- addr
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, target)
+	}
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	if addr := <-dialed; addr != "pusher.invalid:"+config.Port {
+		t.Fatalf("dialed %v", addr)
+	}
+}
+
+// recordingServer passes requests through to the fake server, sending each to
+// requests first
+func recordingServer(t *testing.T, srv *pushertest.Server) (pusher.ClientConfig, chan *http.Request) {
+	config := srv.ClientConfig()
+	backend := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: net.JoinHostPort(config.Host, config.Port)})
+	requests := make(chan *http.Request, 10)
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Clone(context.Background())
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(frontend.Close)
+
+	config.Host, config.Port, _ = net.SplitHostPort(strings.TrimPrefix(frontend.URL, "http://"))
+	config.DisableAutoConnect = true
+	return config, requests
+}
+
+func TestHeaders(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config, requests := recordingServer(t, srv)
+	config.Headers = http.Header{"X-Tenant": {"acme"}, "Cookie": {"session=1"}}
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	if r := <-requests; r.Header.Get("X-Tenant") != "acme" || r.Header.Get("Cookie") != "session=1" {
+		t.Fatalf("upgrade request had headers %v", r.Header)
+	}
+}
+
+func TestQueryParams(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	for _, test := range []struct {
+		params   url.Values
+		expected url.Values
+	}{
+		{nil, url.Values{"protocol": {"7"}, "client": {"pusher-websocket-go"}}},
+		{url.Values{"client": {"my-app"}, "tenant": {"acme"}}, url.Values{"protocol": {"7"}, "client": {"my-app"}, "tenant": {"acme"}}},
+	} {
+		config, requests := recordingServer(t, srv)
+		config.QueryParams = test.params
+		client := pusher.NewWithConfig(config)
+		if err := client.Connect(); err != nil {
+			t.Fatalf("connecting: %v", err)
+		}
+		query := (<-requests).URL.Query()
+		for name := range test.expected {
+			if query.Get(name) != test.expected.Get(name) {
+				t.Errorf("connected with %v=%q, expected %q", name, query.Get(name), test.expected.Get(name))
+			}
+		}
+		if query.Get("version") == "" {
+			t.Error("connected without a version")
+		}
+		client.Disconnect()
+	}
+}
+
+// compressingServer negotiates compression when the client offers it, and
+// sends a large, repetitive event once connected
+func compressingServer(t *testing.T) pusher.ClientConfig {
+	upgrader := websocket.Upgrader{EnableCompression: true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		ws.WriteMessage(websocket.TextMessage, []byte(established))
+		data, _ := json.Marshal(strings.Repeat("repetitive ", 1000))
+		ws.WriteMessage(websocket.TextMessage, []byte(`{"event":"update","channel":"items","data":`+string(data)+`}`))
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	config := pusher.DefaultConfig("key")
+	config.Scheme = "ws"
+	config.Host, config.Port, _ = net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	return config
+}
+
+func TestCompression(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		config := compressingServer(t)
+		config.EnableCompression = compressed
+		received := make(chan interface{}, 1)
+		client := pusher.NewWithConfig(config)
+		client.BindGlobal(func(channel, event string, data interface{}) {
+			if event == "update" {
+				received <- data
+			}
+		})
+		receive(t, received)
+
+		stats := client.ConnectionStats()
+		if stats.MessageBytesReceived < 11000 {
+			t.Fatalf("received %v bytes of messages", stats.MessageBytesReceived)
+		}
+		if saved := stats.WireBytesReceived < stats.MessageBytesReceived; saved != compressed {
+			t.Errorf("with compression %v, received %v bytes of messages in %v bytes", compressed, stats.MessageBytesReceived, stats.WireBytesReceived)
+		}
+		client.Disconnect()
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config, requests := recordingServer(t, srv)
+	config.LocalAddr = "127.0.0.1"
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	if host, _, _ := net.SplitHostPort((<-requests).RemoteAddr); host != "127.0.0.1" {
+		t.Fatalf("server saw source address %v", host)
+	}
+
+	config.LocalAddr = "not an address"
+	invalid := pusher.NewWithConfig(config)
+	defer invalid.Disconnect()
+	if err := invalid.Connect(); err == nil || !strings.Contains(err.Error(), "invalid local address") {
+		t.Fatalf("expected the invalid local address to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package pusher
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what happens to an event dispatched to a binding
+// whose buffer is full, see ClientConfig.BindingBuffer
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the binding to take the event, which stalls
+	// the run loop, and with it pings and every other channel
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered event to make room
+	OverflowDropOldest
+	// OverflowDropNewest discards the event being dispatched
+	OverflowDropNewest
+)
+
+// bindingBuffer returns the capacity of the event buffer of each binding
+func (c ClientConfig) bindingBuffer() int {
+	if c.BindingBuffer < 0 {
+		return 0
+	}
+	return c.BindingBuffer
+}
+
+// workerPool runs dispatched callbacks on a fixed number of goroutines, see
+// ClientConfig.DispatchWorkers. Tasks are queued by channel, and each queue
+// is run by at most one worker at a time, so that the events of a channel are
+// handled in arrival order while different channels proceed in parallel.
+// Workers take turns between queues, one task at a time, so that a busy
+// channel cannot starve the others.
+type workerPool struct {
+	mutex sync.Mutex
+	wake  *sync.Cond
+	// Pending tasks by channel. A channel is present while it is ready or
+	// being run by a worker.
+	queues map[string][]func()
+	// Channels with pending tasks which no worker is running
+	ready   []string
+	stopped bool
+}
+
+func newWorkerPool(workers int) *workerPool {
+	if workers <= 0 {
+		return nil
+	}
+	pool := &workerPool{queues: map[string][]func(){}}
+	pool.wake = sync.NewCond(&pool.mutex)
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+func (self *workerPool) work() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for {
+		for len(self.ready) == 0 && !self.stopped {
+			self.wake.Wait()
+		}
+		if self.stopped {
+			return
+		}
+
+		channel := self.ready[0]
+		self.ready = self.ready[1:]
+		queue := self.queues[channel]
+		task := queue[0]
+		self.queues[channel] = queue[1:]
+
+		self.mutex.Unlock()
+		task()
+		self.mutex.Lock()
+
+		if self.stopped {
+			return
+		}
+		if len(self.queues[channel]) > 0 {
+			self.ready = append(self.ready, channel)
+			self.wake.Signal()
+		} else {
+			delete(self.queues, channel)
+		}
+	}
+}
+
+// submit queues a task behind those of the same channel. It never blocks, so
+// the queues grow while the workers fall behind.
+func (self *workerPool) submit(channel string, task func()) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.stopped {
+		return
+	}
+	queue, scheduled := self.queues[channel]
+	self.queues[channel] = append(queue, task)
+	if !scheduled {
+		self.ready = append(self.ready, channel)
+		self.wake.Signal()
+	}
+}
+
+// stop ends the workers once the client is disconnected, dropping any
+// queued tasks
+func (self *workerPool) stop() {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.stopped = true
+	self.queues = nil
+	self.ready = nil
+	self.wake.Broadcast()
+}
+
+// dispatch runs a global binding's callback for an event on a channel on the
+// worker pool, if there is one, or else inline
+func (self *Client) dispatch(channel, event string, callback func()) {
+	task := func() {
+		defer self.recoverHandler(nil, channel, event)
+		done := self.timeHandler(channel, event)
+		defer done()
+		callback()
+	}
+	if self.loop.pool == nil || self.ManualPoll {
+		task()
+		return
+	}
+	self.loop.pool.submit(channel, task)
+}
+
+// recoverHandler recovers a panic raised by a handler for an event, so that
+// it cannot take down the run loop or a binding's goroutine, and reports it.
+// It must be deferred. ch is the channel of the binding, or nil for global
+// bindings.
+func (self *Client) recoverHandler(ch *Channel, channel, event string) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	err := &PanicError{Channel: channel, Event: event, Value: value, Stack: debug.Stack()}
+	self.logger().Errorf("%v\n%s", err, err.Stack)
+	if ch != nil {
+		ch.emitError(err)
+	} else {
+		self.emitError(err)
+	}
+	if self.OnPanic != nil {
+		self.OnPanic(err)
+	}
+}
+
+// deliver passes data to the binding's goroutine or the worker pool, or calls
+// it inline
+func (self *binding) deliver(data interface{}) {
+	if self.pool != nil {
+		self.pool.submit(self.channel, func() {
+			select {
+			case <-self.done:
+				// Unbound while queued
+			default:
+				self.callback(data)
+			}
+		})
+		return
+	}
+	if self.events == nil {
+		self.callback(data)
+		return
+	}
+
+	switch self.overflow {
+	case OverflowDropNewest:
+		select {
+		case self.events <- data:
+		case <-self.done:
+		default:
+			self.dropped(data)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case self.events <- data:
+				return
+			case <-self.done:
+				return
+			default:
+			}
+			select {
+			case oldest := <-self.events:
+				self.dropped(oldest)
+			default:
+			}
+		}
+	default:
+		select {
+		case self.events <- data:
+		case <-self.done:
+		}
+	}
+}
+
+// overflowed returns the function called with the events a channel's binding
+// to event drops
+func (self *Channel) overflowed(event string) func(data interface{}) {
+	onOverflow := self.client.OnOverflow
+	logs := self.client.logger()
+	return func(data interface{}) {
+		name := event
+		if pattern, ok := data.(patternEvent); ok {
+			name, data = pattern.name, pattern.data
+		}
+		logs.Debugf("Dropped event %v on %v: binding buffer full", name, self.Name)
+		if onOverflow != nil {
+			onOverflow(self.Name, name, data)
+		}
+	}
+}
+
+// timeHandler reports a handler for an event which runs for longer than
+// HandlerTimeout, returning a function to call when it returns
+func (self *Client) timeHandler(channel, event string) (done func()) {
+	if self.HandlerTimeout <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(self.HandlerTimeout, func() {
+		self.logger().Errorf("Handler for %v on %v has run for over %v", event, channel, self.HandlerTimeout)
+		if self.OnSlowHandler != nil {
+			self.OnSlowHandler(channel, event)
+		}
+	})
+	return func() { timer.Stop() }
+}
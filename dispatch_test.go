@@ -0,0 +1,120 @@
+package pusher
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWorkerPoolOrder submits events on several channels at once, which must
+// each be handled in the order submitted
+func TestWorkerPoolOrder(t *testing.T) {
+	const channels, events = 8, 200
+	pool := newWorkerPool(4)
+	defer pool.stop()
+
+	var mutex sync.Mutex
+	received := map[string][]int{}
+	var done sync.WaitGroup
+	done.Add(channels * events)
+
+	var submitters sync.WaitGroup
+	for c := 0; c < channels; c++ {
+		channel := fmt.Sprint("channel-", c)
+		submitters.Add(1)
+		go func() {
+			defer submitters.Done()
+			for i := 0; i < events; i++ {
+				i := i
+				pool.submit(channel, func() {
+					mutex.Lock()
+					received[channel] = append(received[channel], i)
+					mutex.Unlock()
+					if i%10 == 0 {
+						runtime.Gosched()
+					}
+					done.Done()
+				})
+			}
+		}()
+	}
+	submitters.Wait()
+	wait(t, &done)
+
+	for channel, order := range received {
+		if len(order) != events {
+			t.Fatalf("%v received %v events, expected %v", channel, len(order), events)
+		}
+		for i, event := range order {
+			if event != i {
+				t.Fatalf("%v received event %v at position %v", channel, event, i)
+			}
+		}
+	}
+}
+
+// TestWorkerPoolParallel blocks a task on one channel until a task on another
+// has run, which only finishes if channels proceed in parallel
+func TestWorkerPoolParallel(t *testing.T) {
+	pool := newWorkerPool(2)
+	defer pool.stop()
+
+	var done sync.WaitGroup
+	done.Add(2)
+	other := make(chan struct{})
+	pool.submit("first", func() {
+		<-other
+		done.Done()
+	})
+	pool.submit("second", func() {
+		close(other)
+		done.Done()
+	})
+	wait(t, &done)
+}
+
+// TestWorkerPoolSerial checks that a channel's next task does not start while
+// the previous one is still running, even with idle workers
+func TestWorkerPoolSerial(t *testing.T) {
+	pool := newWorkerPool(4)
+	defer pool.stop()
+
+	var mutex sync.Mutex
+	running := 0
+	var done sync.WaitGroup
+	done.Add(20)
+	for i := 0; i < 20; i++ {
+		pool.submit("channel", func() {
+			mutex.Lock()
+			running++
+			concurrent := running
+			mutex.Unlock()
+			if concurrent > 1 {
+				t.Errorf("%v tasks of one channel running at once", concurrent)
+			}
+			time.Sleep(time.Millisecond)
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+			done.Done()
+		})
+	}
+	wait(t, &done)
+}
+
+// wait waits for done, failing the test if it takes too long
+func wait(t *testing.T, done *sync.WaitGroup) {
+	t.Helper()
+	finished := make(chan struct{})
+	go func() {
+		done.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the tasks")
+	}
+}
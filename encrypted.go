@@ -0,0 +1,126 @@
+package pusher
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	s "strings"
+	"time"
+)
+
+// EncryptedAuthFunc authorizes a private-encrypted channel subscription,
+// returning the auth signature and the base64 encoded shared secret which
+// the channel's events are encrypted with
+type EncryptedAuthFunc func(socketID, channel string) (auth, sharedSecret string, err error)
+
+// errEncryptedTrigger is returned when triggering on an encrypted channel,
+// which the protocol does not allow
+var errEncryptedTrigger = errors.New("pusher: client events are not supported on encrypted channels")
+
+func isEncrypted(channel string) bool {
+	return s.HasPrefix(channel, "private-encrypted-")
+}
+
+// setSharedSecret stores the key for an encrypted channel, as returned by
+// its authorization
+func (self *Client) setSharedSecret(channel, sharedSecret string) error {
+	secret, err := base64.StdEncoding.DecodeString(sharedSecret)
+	if err != nil {
+		return fmt.Errorf("pusher: invalid shared secret for %v: %w", channel, err)
+	}
+	if len(secret) != 32 {
+		return fmt.Errorf("pusher: shared secret for %v is %v bytes, not 32", channel, len(secret))
+	}
+	if self.loop.sharedSecrets == nil {
+		self.loop.sharedSecrets = map[string]*[32]byte{}
+	}
+	key := new([32]byte)
+	copy(key[:], secret)
+	self.loop.sharedSecrets[channel] = key
+	return nil
+}
+
+// secretRefreshInterval is the minimum time between refreshes of the shared
+// secret of an encrypted channel, so that a stream of undecryptable events
+// does not authorize the channel again for each one
+const secretRefreshInterval = 10 * time.Second
+
+// secretRefresh is the result of authorizing an encrypted channel again
+type secretRefresh struct {
+	channel      string
+	sharedSecret string
+	err          error
+}
+
+// decrypt replaces the data of an event on an encrypted channel with its
+// plaintext. If decryption fails the shared secret may have been rotated, so
+// a fresh one is requested in the background and the event is reported as
+// undecryptable.
+func (self *Client) decrypt(event *Event) error {
+	var payload struct {
+		Ciphertext string `json:"ciphertext"`
+		Nonce      string `json:"nonce"`
+	}
+	if err := json.Unmarshal([]byte(event.Data), &payload); err != nil {
+		return fmt.Errorf("pusher: encrypted event on %v is malformed: %w", event.Channel, err)
+	}
+	box, err := base64.StdEncoding.DecodeString(payload.Ciphertext)
+	if err != nil {
+		return fmt.Errorf("pusher: invalid ciphertext on %v: %w", event.Channel, err)
+	}
+	nonceBytes, err := base64.StdEncoding.DecodeString(payload.Nonce)
+	if err != nil || len(nonceBytes) != 24 {
+		return fmt.Errorf("pusher: invalid nonce on %v", event.Channel)
+	}
+	nonce := new([24]byte)
+	copy(nonce[:], nonceBytes)
+
+	key := self.loop.sharedSecrets[event.Channel]
+	if key != nil {
+		if plaintext, err := self.crypto().Open(box, nonce, key); err == nil {
+			event.Data = string(plaintext)
+			return nil
+		}
+	}
+	self.refreshSecret(event.Channel)
+	return fmt.Errorf("pusher: cannot decrypt event on %v", event.Channel)
+}
+
+// refreshSecret authorizes an encrypted channel again on its own goroutine,
+// at most once per secretRefreshInterval, handing the new shared secret to
+// the run loop
+func (self *Client) refreshSecret(channel string) {
+	if self.EncryptedAuthFunc == nil || self.conn == nil {
+		return
+	}
+	if last, ok := self.loop.secretRefreshes[channel]; ok && time.Since(last) < secretRefreshInterval {
+		return
+	}
+	if self.loop.secretRefreshes == nil {
+		self.loop.secretRefreshes = map[string]time.Time{}
+	}
+	self.loop.secretRefreshes[channel] = time.Now()
+
+	self.logger().Infof("Failed to decrypt event on %v, refreshing shared secret", channel)
+	loop, authorize, socketID := self.loop, self.EncryptedAuthFunc, self.loop.socketID
+	go func() {
+		_, sharedSecret, err := authorize(socketID, channel)
+		send(loop, loop.onSecret, secretRefresh{channel, sharedSecret, err})
+	}()
+}
+
+// handleSecret stores a refreshed shared secret, unless the channel has been
+// released meanwhile
+func (self *Client) handleSecret(refresh secretRefresh) {
+	err := refresh.err
+	if err == nil {
+		if len(self.channelsNamed(refresh.channel)) == 0 {
+			return
+		}
+		err = self.setSharedSecret(refresh.channel, refresh.sharedSecret)
+	}
+	if err != nil {
+		self.logger().Errorf("Refreshing shared secret for %v failed: %v", refresh.channel, err)
+	}
+}
@@ -0,0 +1,141 @@
+package pusher_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// encryptedChannel subscribes to an encrypted channel, authorized with the
+// key which secret holds when the channel is authorized
+func encryptedChannel(t *testing.T, secret *atomic.Pointer[[32]byte], authorizations *atomic.Int32) (*pushertest.Server, *pusher.Channel) {
+	srv := pushertest.NewServer("key", "secret")
+	t.Cleanup(srv.Close)
+	config := srv.ClientConfig()
+	config.EncryptedAuthFunc = func(socketID, channel string) (string, string, error) {
+		authorizations.Add(1)
+		auth := pusher.GenerateAuth("key", "secret", socketID, channel, nil)
+		return auth, base64.StdEncoding.EncodeToString(secret.Load()[:]), nil
+	}
+	client := pusher.NewWithConfig(config)
+	t.Cleanup(client.Disconnect)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "private-encrypted-x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	return srv, channel
+}
+
+// newKey returns a random shared secret
+func newKey(t *testing.T) *[32]byte {
+	key := new([32]byte)
+	if _, err := rand.Read(key[:]); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// seal encrypts plaintext as the data of an encrypted event
+func seal(t *testing.T, key *[32]byte, nonceSize int, plaintext string) string {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	var boxNonce [24]byte
+	copy(boxNonce[:], nonce)
+	box := secretbox.Seal(nil, []byte(plaintext), &boxNonce, key)
+	data, _ := json.Marshal(map[string]string{
+		"ciphertext": base64.StdEncoding.EncodeToString(box),
+		"nonce":      base64.StdEncoding.EncodeToString(nonce),
+	})
+	return string(data)
+}
+
+func TestDecrypt(t *testing.T) {
+	secret, authorizations := new(atomic.Pointer[[32]byte]), new(atomic.Int32)
+	secret.Store(newKey(t))
+	srv, channel := encryptedChannel(t, secret, authorizations)
+
+	events := make(chan interface{}, 1)
+	channel.Bind("event", func(data interface{}) { events <- data })
+	srv.Trigger(channel.Name, "event", seal(t, secret.Load(), 24, `{"text":"hi"}`))
+	if data := receive(t, events); data != `{"text":"hi"}` {
+		t.Fatalf("received %v", data)
+	}
+}
+
+// TestDecryptNonceLength sends an event with a short nonce, which must be
+// reported without authorizing the channel again
+func TestDecryptNonceLength(t *testing.T) {
+	secret, authorizations := new(atomic.Pointer[[32]byte]), new(atomic.Int32)
+	secret.Store(newKey(t))
+	srv, channel := encryptedChannel(t, secret, authorizations)
+
+	errs := make(chan error, 1)
+	channel.BindError(func(err error) { errs <- err })
+	channel.Bind("event", func(data interface{}) { t.Errorf("received %v", data) })
+	srv.Trigger(channel.Name, "event", seal(t, secret.Load(), 23, "data"))
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "invalid nonce") {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no decode error reported")
+	}
+	if count := authorizations.Load(); count != 1 {
+		t.Fatalf("channel authorized %v times", count)
+	}
+}
+
+// TestSecretRotation rotates the shared secret while subscribed. Events
+// sealed with the new secret are reported as decode errors until a single
+// refresh fetches it, after which they are delivered.
+func TestSecretRotation(t *testing.T) {
+	secret, authorizations := new(atomic.Pointer[[32]byte]), new(atomic.Int32)
+	secret.Store(newKey(t))
+	srv, channel := encryptedChannel(t, secret, authorizations)
+
+	var decodeErrors atomic.Int32
+	channel.BindError(func(err error) { decodeErrors.Add(1) })
+	events := make(chan interface{}, 16)
+	channel.Bind("event", func(data interface{}) { events <- data })
+
+	secret.Store(newKey(t))
+	for i := 0; i < 5; i++ {
+		srv.Trigger(channel.Name, "event", seal(t, secret.Load(), 24, "rotated"))
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		select {
+		case data := <-events:
+			if data != "rotated" {
+				t.Fatalf("received %v", data)
+			}
+			if decodeErrors.Load() == 0 {
+				t.Fatal("events sealed with the new secret were not reported before the refresh")
+			}
+			if count := authorizations.Load(); count != 2 {
+				t.Fatalf("channel authorized %v times, expected one refresh", count)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no event delivered after rotating the secret")
+		}
+		srv.Trigger(channel.Name, "event", seal(t, secret.Load(), 24, "rotated"))
+	}
+}
@@ -0,0 +1,87 @@
+package pusher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ConfigFromEnv reads a client config from the environment, for deployments
+// configured purely through it:
+//
+//   - PUSHER_KEY, the application key, is required
+//   - PUSHER_CLUSTER selects the host ws-{cluster}.pusher.com, and the
+//     fallback host sockjs-{cluster}.pusher.com, unless PUSHER_HOST is set
+//   - PUSHER_HOST, PUSHER_PORT and PUSHER_SCHEME override the endpoint
+//   - PUSHER_SECRET signs private and presence channels in process
+//   - PUSHER_AUTH_ENDPOINT authorizes them with an HTTPAuthorizer instead
+//   - PUSHER_PROXY sets Proxy
+//   - PUSHER_DEBUG enables diagnostics when true
+func ConfigFromEnv() (ClientConfig, error) {
+	config := ClientConfig{
+		Scheme:       getenv("PUSHER_SCHEME", defaultScheme),
+		Host:         getenv("PUSHER_HOST", clusterHost(os.Getenv("PUSHER_CLUSTER"))),
+		Port:         getenv("PUSHER_PORT", defaultPort),
+		Key:          os.Getenv("PUSHER_KEY"),
+		Secret:       os.Getenv("PUSHER_SECRET"),
+		Proxy:        os.Getenv("PUSHER_PROXY"),
+		FallbackHost: fallbackHost(os.Getenv("PUSHER_HOST"), os.Getenv("PUSHER_CLUSTER")),
+	}
+	if config.Key == "" {
+		return config, errors.New("pusher: PUSHER_KEY is not set")
+	}
+
+	if endpoint := os.Getenv("PUSHER_AUTH_ENDPOINT"); endpoint != "" {
+		authorizer := NewHTTPAuthorizer(endpoint)
+		config.AuthFunc = authorizer.Authorize
+		config.PresenceAuthFunc = authorizer.AuthorizePresence
+		config.EncryptedAuthFunc = authorizer.AuthorizeEncrypted
+	}
+
+	if debug := os.Getenv("PUSHER_DEBUG"); debug != "" {
+		enabled, err := strconv.ParseBool(debug)
+		if err != nil {
+			return config, fmt.Errorf("pusher: invalid PUSHER_DEBUG: %w", err)
+		}
+		config.Debug = enabled
+	}
+
+	return config, nil
+}
+
+// NewFromEnv creates a client configured by ConfigFromEnv
+func NewFromEnv() (*Client, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewWithConfig(config), nil
+}
+
+// clusterHost returns the WebSocket host of a cluster, or the default host
+func clusterHost(cluster string) string {
+	if cluster == "" {
+		return defaultHost
+	}
+	return "ws-" + cluster + ".pusher.com"
+}
+
+// fallbackHost returns the host serving the HTTP fallback: a custom host
+// serves its own, while Pusher serves it from a SockJS host per cluster
+func fallbackHost(host, cluster string) string {
+	if host != "" {
+		return host
+	}
+	if cluster == "" {
+		return defaultFallbackHost
+	}
+	return "sockjs-" + cluster + ".pusher.com"
+}
+
+func getenv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,87 @@
+package pusher_test
+
+import (
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// setenv sets the PUSHER_ variables, clearing those not given
+func setenv(t *testing.T, vars map[string]string) {
+	for _, name := range []string{"KEY", "CLUSTER", "HOST", "PORT", "SCHEME", "SECRET", "AUTH_ENDPOINT", "PROXY", "DEBUG"} {
+		t.Setenv("PUSHER_"+name, vars[name])
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	setenv(t, map[string]string{"KEY": "key", "CLUSTER": "eu", "SECRET": "secret", "DEBUG": "true"})
+	config, err := pusher.ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Key != "key" || config.Host != "ws-eu.pusher.com" || config.Scheme != "wss" || config.Port != "443" ||
+		config.Secret != "secret" || !config.Debug || config.AuthFunc != nil {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	setenv(t, map[string]string{"KEY": "key", "CLUSTER": "eu", "HOST": "localhost", "PORT": "6001", "SCHEME": "ws",
+		"AUTH_ENDPOINT": "https://example.com/auth", "PROXY": "socks5://proxy:1080"})
+	config, err = pusher.ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "localhost" || config.Port != "6001" || config.Scheme != "ws" || config.Proxy != "socks5://proxy:1080" ||
+		config.AuthFunc == nil || config.PresenceAuthFunc == nil || config.EncryptedAuthFunc == nil || config.Debug {
+		t.Fatalf("unexpected config %+v", config)
+	}
+}
+
+// TestFallbackHostFromEnv serves the HTTP fallback from a custom host
+// itself, and otherwise from Pusher's SockJS host for the cluster
+func TestFallbackHostFromEnv(t *testing.T) {
+	for _, test := range []struct {
+		cluster, host, fallbackHost string
+	}{
+		{"", "", "sockjs.pusher.com"},
+		{"eu", "", "sockjs-eu.pusher.com"},
+		{"", "pusher.example.com", "pusher.example.com"},
+		{"eu", "pusher.example.com:6001", "pusher.example.com:6001"},
+	} {
+		setenv(t, map[string]string{"KEY": "key", "CLUSTER": test.cluster, "HOST": test.host})
+		config, err := pusher.ConfigFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.FallbackHost != test.fallbackHost {
+			t.Errorf("cluster %q and host %q have fallback host %q, expected %q", test.cluster, test.host, config.FallbackHost, test.fallbackHost)
+		}
+	}
+}
+
+func TestConfigFromEnvInvalid(t *testing.T) {
+	setenv(t, nil)
+	if _, err := pusher.ConfigFromEnv(); err == nil {
+		t.Error("expected an error without PUSHER_KEY")
+	}
+	setenv(t, map[string]string{"KEY": "key", "DEBUG": "loud"})
+	if _, err := pusher.ConfigFromEnv(); err == nil {
+		t.Error("expected an error with an invalid PUSHER_DEBUG")
+	}
+	if client, err := pusher.NewFromEnv(); err == nil || client != nil {
+		t.Errorf("expected NewFromEnv to fail, got %v, %v", client, err)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	setenv(t, map[string]string{"KEY": config.Key, "HOST": config.Host, "PORT": config.Port, "SCHEME": config.Scheme})
+	client, err := pusher.NewFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	waitState(t, client, pusher.StateConnected)
+}
@@ -0,0 +1,165 @@
+package pusher
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned when publishing from a client configured ReadOnly
+var ErrReadOnly = errors.New("pusher: client is read-only")
+
+// ErrClientEventName is returned when triggering an event whose name does
+// not start with client-
+var ErrClientEventName = errors.New("pusher: client event names must start with client-")
+
+// ErrClientEventChannel is returned when triggering on a public channel, as
+// client events are only accepted on private and presence channels
+var ErrClientEventChannel = errors.New("pusher: client events require a private or presence channel")
+
+// ErrNotConnected is returned when triggering while the client is not
+// connected
+var ErrNotConnected = errors.New("pusher: client is not connected")
+
+// ErrNotSubscribed is returned when triggering on a channel before its
+// subscription has succeeded
+var ErrNotSubscribed = errors.New("pusher: channel is not subscribed")
+
+// ErrRateLimited is returned when triggering a client event would exceed
+// ClientConfig.ClientEventRate
+var ErrRateLimited = errors.New("pusher: client event rate limit exceeded")
+
+// ErrSubscriptionRetried ends the trace span of a subscription attempt which
+// is superseded by another before the server acknowledged it
+var ErrSubscriptionRetried = errors.New("pusher: subscription retried")
+
+// ErrAlreadyConnected is returned by Connect when the client is already
+// connecting or connected
+var ErrAlreadyConnected = errors.New("pusher: client is already connecting")
+
+// ErrDisconnected is returned by Connect when the client has been
+// disconnected, or has given up connecting
+var ErrDisconnected = errors.New("pusher: client is disconnected")
+
+// ErrShutdownTimeout is returned by Shutdown when the connection was closed
+// before all pending messages could be sent
+var ErrShutdownTimeout = errors.New("pusher: shutdown timed out before pending messages were sent")
+
+// ErrShutdownClosed is returned by Shutdown, wrapped with the close error,
+// when the connection closed before all pending messages could be sent
+var ErrShutdownClosed = errors.New("pusher: connection closed before pending messages were sent")
+
+// ErrConnectionClosed is returned by Conn.Send once the connection has closed
+var ErrConnectionClosed = errors.New("pusher: connection is closed")
+
+// ErrUnknownTransport is reported, with the name, when ClientConfig.Transports
+// names a transport which does not exist
+var ErrUnknownTransport = errors.New("pusher: unknown transport")
+
+// ErrNotManualPoll is returned by RunOnce and Poll on a client without
+// ManualPoll, whose run loop runs on a goroutine of its own
+var ErrNotManualPoll = errors.New("pusher: RunOnce and Poll require ManualPoll")
+
+// ErrWebhookSignature is returned when a webhook request was not signed with
+// the app's key and secret
+var ErrWebhookSignature = errors.New("pusher: invalid webhook signature")
+
+// AuthError reports that a subscription could not be authorized, e.g.
+// because the auth endpoint failed
+type AuthError struct {
+	Channel string
+	Err     error
+}
+
+func (self *AuthError) Error() string {
+	return fmt.Sprintf("pusher: authorization for %v failed: %v", self.Channel, self.Err)
+}
+
+func (self *AuthError) Unwrap() error {
+	return self.Err
+}
+
+// SubscriptionError reports a subscription rejected by the server in a
+// pusher:subscription_error event
+type SubscriptionError struct {
+	Channel string
+	// Type classifies the error, e.g. AuthError
+	Type    string
+	Message string
+	// Status is the HTTP status of the failed authorization request, if any
+	Status int
+}
+
+func (self *SubscriptionError) Error() string {
+	if self.Status == 0 {
+		return fmt.Sprintf("pusher: subscription to %v failed: %v", self.Channel, self.Message)
+	}
+	return fmt.Sprintf("pusher: subscription to %v failed: %v (status %v)", self.Channel, self.Message, self.Status)
+}
+
+// DecodeError reports a message from the server which is not a valid event,
+// see ClientConfig.StrictProtocol
+type DecodeError struct {
+	// Message is the raw message
+	Message string
+	Err     error
+}
+
+func (self *DecodeError) Error() string {
+	return fmt.Sprintf("pusher: invalid message from server: %v", self.Err)
+}
+
+func (self *DecodeError) Unwrap() error {
+	return self.Err
+}
+
+// PanicError reports a panic raised by a handler, which was recovered to keep
+// the client running
+type PanicError struct {
+	Channel string
+	Event   string
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the stack trace of the handler's goroutine when it panicked
+	Stack []byte
+}
+
+func (self *PanicError) Error() string {
+	return fmt.Sprintf("pusher: handler for %v on %v panicked: %v", self.Event, self.Channel, self.Value)
+}
+
+// ConnectionError is an error reported by the Pusher server, either in a
+// pusher:error event or as the code of the frame closing the connection.
+// The code decides how the client reconnects, see Permanent and Immediate.
+type ConnectionError struct {
+	Code    int
+	Message string
+}
+
+func (self *ConnectionError) Error() string {
+	if self.Code == 0 {
+		return fmt.Sprintf("pusher: error: %v", self.Message)
+	}
+	return fmt.Sprintf("pusher: error %v: %v", self.Code, self.Message)
+}
+
+// Permanent reports whether the server asked the client not to reconnect,
+// e.g. because the application key is wrong or TLS is required (4000-4099)
+func (self *ConnectionError) Permanent() bool {
+	return self.Code >= 4000 && self.Code < 4100
+}
+
+// Immediate reports whether the server asked the client to reconnect
+// straight away (4200-4299). Other codes, e.g. over capacity (4100-4199),
+// reconnect after the usual backoff.
+func (self *ConnectionError) Immediate() bool {
+	return self.Code >= 4200 && self.Code < 4300
+}
+
+// closeError describes the closing of a connection with the given code,
+// returning a ConnectionError for Pusher's own codes
+func closeError(code int, reason string) error {
+	if code >= 4000 && code < 4300 {
+		return &ConnectionError{Code: code, Message: reason}
+	}
+	return fmt.Errorf("pusher: connection closed with code %v %v", code, reason)
+}
@@ -0,0 +1,316 @@
+package pusher_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// TestPanicRecovery checks that panics in channel and global handlers are
+// reported, and that the client keeps delivering events afterwards
+func TestPanicRecovery(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	panics := make(chan interface{}, 10)
+	config := srv.ClientConfig()
+	config.Logger = &recordingLogger{}
+	config.OnPanic = func(err *pusher.PanicError) { panics <- err }
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	channelErrors, clientErrors := make(chan interface{}, 10), make(chan interface{}, 10)
+	channel.BindError(func(err error) { channelErrors <- err })
+	client.BindError(func(err error) { clientErrors <- err })
+	received := make(chan interface{}, 10)
+	channel.Bind("event", func(data interface{}) {
+		if data == "boom" {
+			panic("channel handler")
+		}
+		received <- data
+	})
+
+	srv.Trigger("x", "event", "boom")
+	recovered := receive(t, panics)
+	if panicErr := recovered.(*pusher.PanicError); panicErr.Channel != "x" || panicErr.Event != "event" || panicErr.Value != "channel handler" || len(panicErr.Stack) == 0 {
+		t.Fatalf("unexpected panic error %+v", panicErr)
+	}
+	if reported := receive(t, channelErrors); reported != recovered {
+		t.Fatalf("channel error handler received %v", reported)
+	}
+	if reported := receive(t, clientErrors); reported != recovered {
+		t.Fatalf("client error handler received %v", reported)
+	}
+	srv.Trigger("x", "event", "after")
+	if data := receive(t, received); data != "after" {
+		t.Fatalf("received %v after the panic", data)
+	}
+
+	id := client.BindGlobal(func(channel, event string, data interface{}) { panic("global handler") })
+	srv.Trigger("x", "event", "global")
+	if panicErr := receive(t, panics).(*pusher.PanicError); panicErr.Value != "global handler" {
+		t.Fatalf("unexpected panic error %+v", panicErr)
+	}
+	var panicErr *pusher.PanicError
+	if reported := receive(t, clientErrors).(error); !errors.As(reported, &panicErr) {
+		t.Fatalf("client error handler received %v", reported)
+	}
+	if data := receive(t, received); data != "global" {
+		t.Fatalf("received %v alongside the panicking global handler", data)
+	}
+	client.UnbindGlobal(id)
+	if client.State() != pusher.StateConnected {
+		t.Fatalf("client is %v after recovering panics", client.State())
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	slow := make(chan interface{}, 10)
+	logs := &recordingLogger{}
+	config := srv.ClientConfig()
+	config.Logger = logs
+	config.HandlerTimeout = 50 * time.Millisecond
+	config.OnSlowHandler = func(channel, event string) { slow <- channel + " " + event }
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	release := make(chan struct{})
+	channel.Bind("slow", func(data interface{}) { <-release })
+	channel.Bind("fast", func(data interface{}) {})
+	cancelled := make(chan interface{}, 1)
+	channel.BindContext("context", func(ctx context.Context, data interface{}) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+	})
+
+	srv.Trigger("x", "fast", nil)
+	srv.Trigger("x", "slow", nil)
+	if reported := receive(t, slow); reported != "x slow" {
+		t.Fatalf("OnSlowHandler called for %v", reported)
+	}
+	close(release)
+	if logs.find("error Handler for slow on x has run for over 50ms") == "" {
+		t.Fatal("the slow handler was not logged")
+	}
+
+	srv.Trigger("x", "context", nil)
+	if err := receive(t, cancelled); err != context.DeadlineExceeded {
+		t.Fatalf("handler context ended with %v", err)
+	}
+	if reported := receive(t, slow); reported != "x context" {
+		t.Fatalf("OnSlowHandler called for %v", reported)
+	}
+	select {
+	case reported := <-slow:
+		t.Fatalf("OnSlowHandler called for %v", reported)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestBindError reports connection failures, authorization failures and
+// server errors to the client's error handlers and Errors channel
+func TestBindError(t *testing.T) {
+	errs := make(chan error, 10)
+	config := pusher.ClientConfig{DisableAutoConnect: true, MaxReconnectDelay: time.Millisecond, MaxAuthFailures: 1, Errors: errs}
+	config.Logger = &recordingLogger{}
+	var dials atomic.Int32
+	client, conns, _, _ := fakeClient(t, config, func() error {
+		if dials.Add(1) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	handled := make(chan interface{}, 10)
+	client.BindError(func(err error) { handled <- err })
+	channelErrors := make(chan interface{}, 10)
+	client.Subscribe("private-x").BindError(func(err error) { channelErrors <- err })
+	if err := client.Connect(); err == nil {
+		t.Fatal("expected the first connection attempt to fail")
+	}
+
+	expect := func(check func(err error) bool) {
+		t.Helper()
+		err := receive(t, handled).(error)
+		if !check(err) {
+			t.Fatalf("unexpected error %v", err)
+		}
+		select {
+		case sent := <-errs:
+			if sent != err {
+				t.Fatalf("Errors received %v, BindError received %v", sent, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%v was not sent to Errors", err)
+		}
+	}
+	expect(func(err error) bool { return err.Error() == "connection refused" })
+
+	nextConn(t, conns).OnMessage <- established
+	var authErr *pusher.AuthError
+	expect(func(err error) bool { return errors.As(err, &authErr) && authErr.Channel == "private-x" })
+	if err := receive(t, channelErrors); err != error(authErr) {
+		t.Fatalf("channel error handler received %v", err)
+	}
+}
+
+// TestStructuredErrors checks that failures can be told apart with errors.As
+// and errors.Is
+func TestStructuredErrors(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	denied := errors.New("denied")
+	config := srv.ClientConfig()
+	config.Logger = &recordingLogger{}
+	config.DisableAutoConnect = true
+	config.MaxAuthFailures = 1
+	config.AuthFunc = func(socketID, channel string) (string, error) {
+		if channel == "private-denied" {
+			return "", denied
+		}
+		return pusher.GenerateAuth(config.Key, "wrong", socketID, channel, nil), nil
+	}
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	unconnected := client.Subscribe("private-x")
+	if err := unconnected.Trigger("client-event", nil); !errors.Is(err, pusher.ErrNotConnected) {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := client.SubscribeWithResult(ctx, "private-denied")
+	var authErr *pusher.AuthError
+	if !errors.As(err, &authErr) || authErr.Channel != "private-denied" || !errors.Is(err, denied) {
+		t.Fatalf("expected an AuthError wrapping the AuthFunc's error, got %v", err)
+	}
+
+	_, err = client.SubscribeWithResult(ctx, "private-x")
+	var subErr *pusher.SubscriptionError
+	if !errors.As(err, &subErr) || subErr.Channel != "private-x" || subErr.Type != "AuthError" || subErr.Status != 401 {
+		t.Fatalf("expected a SubscriptionError from the server, got %v", err)
+	}
+	if err := unconnected.Trigger("client-event", nil); !errors.Is(err, pusher.ErrNotSubscribed) {
+		t.Fatalf("expected ErrNotSubscribed, got %v", err)
+	}
+
+	for _, test := range []struct {
+		err                  *pusher.ConnectionError
+		message              string
+		permanent, immediate bool
+	}{
+		{&pusher.ConnectionError{Code: 4001, Message: "app disabled"}, "pusher: error 4001: app disabled", true, false},
+		{&pusher.ConnectionError{Code: 4100, Message: "over capacity"}, "pusher: error 4100: over capacity", false, false},
+		{&pusher.ConnectionError{Code: 4201, Message: "pong not received"}, "pusher: error 4201: pong not received", false, true},
+		{&pusher.ConnectionError{Message: "unknown"}, "pusher: error: unknown", false, false},
+	} {
+		if test.err.Error() != test.message || test.err.Permanent() != test.permanent || test.err.Immediate() != test.immediate {
+			t.Errorf("unexpected %q, permanent %v, immediate %v", test.err, test.err.Permanent(), test.err.Immediate())
+		}
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict %v", strict), func(t *testing.T) {
+			config := pusher.ClientConfig{DisableAutoConnect: true, StrictProtocol: strict, MaxReconnectDelay: time.Millisecond}
+			config.Logger = &recordingLogger{}
+			client, conns, _, _ := fakeClient(t, config, func() error { return nil })
+			errs := make(chan interface{}, 10)
+			client.BindError(func(err error) { errs <- err })
+			if err := client.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			callbacks := nextConn(t, conns)
+			callbacks.OnMessage <- established
+			waitState(t, client, pusher.StateConnected)
+
+			callbacks.OnMessage <- "not json"
+			var decodeErr *pusher.DecodeError
+			if err := receive(t, errs).(error); !errors.As(err, &decodeErr) || decodeErr.Message != "not json" {
+				t.Fatalf("expected a DecodeError for the message, got %v", err)
+			}
+			if count := client.InvalidMessages(); count != 1 {
+				t.Fatalf("counted %v invalid messages", count)
+			}
+			select {
+			case <-conns:
+				if !strict {
+					t.Fatal("reconnected after an invalid message")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if strict {
+					t.Fatal("did not reconnect after an invalid message")
+				}
+			}
+		})
+	}
+}
+
+// TestServerErrors delivers pusher:error events as *ConnectionError to global
+// bindings, error handlers and OnConnectionError
+func TestServerErrors(t *testing.T) {
+	connectionErrors := make(chan interface{}, 10)
+	config := pusher.ClientConfig{DisableAutoConnect: true}
+	config.Logger = &recordingLogger{}
+	config.OnConnectionError = func(err *pusher.ConnectionError) { connectionErrors <- err }
+	client, conns, _, _ := fakeClient(t, config, func() error { return nil })
+	global, handled := make(chan interface{}, 10), make(chan interface{}, 10)
+	client.BindGlobal(func(channel, event string, data interface{}) {
+		if event == "pusher:error" {
+			global <- data
+		}
+	})
+	client.BindError(func(err error) { handled <- err })
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	callbacks := nextConn(t, conns)
+	callbacks.OnMessage <- established
+
+	for _, test := range []struct {
+		message string
+		code    int
+		text    string
+	}{
+		{`{"event":"pusher:error","data":{"code":4301,"message":"Client event rejected due to rate limit"}}`, 4301, "Client event rejected due to rate limit"},
+		{`{"event":"pusher:error","data":"unstructured"}`, 0, "unstructured"},
+	} {
+		callbacks.OnMessage <- test.message
+		reported := receive(t, connectionErrors).(*pusher.ConnectionError)
+		if reported.Code != test.code || reported.Message != test.text {
+			t.Fatalf("OnConnectionError received %+v for %v", reported, test.message)
+		}
+		if data := receive(t, global); data != reported {
+			t.Fatalf("global binding received %v", data)
+		}
+		if err := receive(t, handled); err != reported {
+			t.Fatalf("error handler received %v", err)
+		}
+	}
+	if state := client.State(); state != pusher.StateConnected {
+		t.Fatalf("client is %v after server errors", state)
+	}
+}
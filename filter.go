@@ -0,0 +1,38 @@
+package pusher
+
+import (
+	s "strings"
+)
+
+// Filter decides whether an event is dispatched to a channel's bindings, so
+// that high-volume channels can be filtered once rather than in every
+// handler. The celfilter package compiles filters from CEL expressions.
+//
+// Filters only see application events: internal pusher: events, such as
+// member updates on presence channels, are always dispatched.
+type Filter func(event Event) bool
+
+// SetFilter sets a filter evaluated before dispatch to any of the channel's
+// bindings, or removes it when nil. Global bindings are not filtered. The
+// filter runs on the client's run loop, so must be quick.
+func (self *Channel) SetFilter(filter Filter) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.filter = filter
+}
+
+func (self *Channel) accepts(event Event) bool {
+	self.mutex.Lock()
+	filter := self.filter
+	self.mutex.Unlock()
+	return filter == nil || filter(event)
+}
+
+// filterableEvent returns the event being dispatched, if filters apply to it
+func filterableEvent(channel, name, userID string, data interface{}) (Event, bool) {
+	payload, ok := data.(string)
+	if !ok || s.HasPrefix(name, "pusher:") {
+		return Event{}, false
+	}
+	return Event{Name: name, Channel: channel, Data: payload, UserId: userID}, true
+}
@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: grpcbridge/bridgepb/bridge.proto
+
+package bridgepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubscribeRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Channel string                 `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	// Only stream events with this name, or all events when empty
+	Event         string `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_grpcbridge_bridgepb_bridge_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcbridge_bridgepb_bridge_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_grpcbridge_bridgepb_bridge_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeRequest) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+type Event struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Channel string                 `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	Event   string                 `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`
+	Data    string                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// Set on client events delivered on presence channels
+	UserId        string `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_grpcbridge_bridgepb_bridge_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcbridge_bridgepb_bridge_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_grpcbridge_bridgepb_bridge_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Event) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *Event) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *Event) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *Event) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+var File_grpcbridge_bridgepb_bridge_proto protoreflect.FileDescriptor
+
+const file_grpcbridge_bridgepb_bridge_proto_rawDesc = "" +
+	"\n" +
+	" grpcbridge/bridgepb/bridge.proto\x12\x10pusher.bridge.v1\"B\n" +
+	"\x10SubscribeRequest\x12\x18\n" +
+	"\achannel\x18\x01 \x01(\tR\achannel\x12\x14\n" +
+	"\x05event\x18\x02 \x01(\tR\x05event\"d\n" +
+	"\x05Event\x12\x18\n" +
+	"\achannel\x18\x01 \x01(\tR\achannel\x12\x14\n" +
+	"\x05event\x18\x02 \x01(\tR\x05event\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\tR\x04data\x12\x17\n" +
+	"\auser_id\x18\x04 \x01(\tR\x06userId2T\n" +
+	"\x06Bridge\x12J\n" +
+	"\tSubscribe\x12\".pusher.bridge.v1.SubscribeRequest\x1a\x17.pusher.bridge.v1.Event0\x01B;Z9github.com/mnaser/pusher-websocket-go/grpcbridge/bridgepbb\x06proto3"
+
+var (
+	file_grpcbridge_bridgepb_bridge_proto_rawDescOnce sync.Once
+	file_grpcbridge_bridgepb_bridge_proto_rawDescData []byte
+)
+
+func file_grpcbridge_bridgepb_bridge_proto_rawDescGZIP() []byte {
+	file_grpcbridge_bridgepb_bridge_proto_rawDescOnce.Do(func() {
+		file_grpcbridge_bridgepb_bridge_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_grpcbridge_bridgepb_bridge_proto_rawDesc), len(file_grpcbridge_bridgepb_bridge_proto_rawDesc)))
+	})
+	return file_grpcbridge_bridgepb_bridge_proto_rawDescData
+}
+
+var file_grpcbridge_bridgepb_bridge_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_grpcbridge_bridgepb_bridge_proto_goTypes = []any{
+	(*SubscribeRequest)(nil), // 0: pusher.bridge.v1.SubscribeRequest
+	(*Event)(nil),            // 1: pusher.bridge.v1.Event
+}
+var file_grpcbridge_bridgepb_bridge_proto_depIdxs = []int32{
+	0, // 0: pusher.bridge.v1.Bridge.Subscribe:input_type -> pusher.bridge.v1.SubscribeRequest
+	1, // 1: pusher.bridge.v1.Bridge.Subscribe:output_type -> pusher.bridge.v1.Event
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_grpcbridge_bridgepb_bridge_proto_init() }
+func file_grpcbridge_bridgepb_bridge_proto_init() {
+	if File_grpcbridge_bridgepb_bridge_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_grpcbridge_bridgepb_bridge_proto_rawDesc), len(file_grpcbridge_bridgepb_bridge_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grpcbridge_bridgepb_bridge_proto_goTypes,
+		DependencyIndexes: file_grpcbridge_bridgepb_bridge_proto_depIdxs,
+		MessageInfos:      file_grpcbridge_bridgepb_bridge_proto_msgTypes,
+	}.Build()
+	File_grpcbridge_bridgepb_bridge_proto = out.File
+	file_grpcbridge_bridgepb_bridge_proto_goTypes = nil
+	file_grpcbridge_bridgepb_bridge_proto_depIdxs = nil
+}
@@ -0,0 +1,104 @@
+// Package grpcbridge exposes the events received by a pusher client over a
+// gRPC server-streaming API, so that services in other languages can consume
+// Pusher events through one shared sidecar. The service is defined in
+// bridgepb/bridge.proto.
+package grpcbridge
+
+import (
+	"context"
+	"errors"
+	s "strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/grpcbridge/bridgepb"
+	"github.com/mnaser/pusher-websocket-go/internal/relay"
+)
+
+// Default number of events buffered for each stream
+const DefaultBuffer = 256
+
+// Server implements bridgepb.BridgeServer on top of a pusher client. Each
+// stream subscribes through its own client facade, which is disconnected when
+// the stream ends, so the stream's channels are released and unsubscribed
+// once their last stream ends.
+type Server struct {
+	bridgepb.UnimplementedBridgeServer
+
+	client *pusher.Client
+
+	// Buffer is the number of events buffered for each stream. Streams which
+	// fall further behind are ended with ResourceExhausted.
+	Buffer int
+	// Authorize is called before streaming, e.g. to check credentials in the
+	// context's metadata. Returning an error ends the stream with
+	// PermissionDenied. Without it, only public channels may be streamed, as
+	// private and presence channels are authorized with the client's own
+	// credentials.
+	Authorize func(ctx context.Context, channel string) error
+}
+
+func NewServer(client *pusher.Client) *Server {
+	return &Server{client: client, Buffer: DefaultBuffer}
+}
+
+// Register registers the bridge service on a gRPC server
+func (self *Server) Register(server *grpc.Server) {
+	bridgepb.RegisterBridgeServer(server, self)
+}
+
+func (self *Server) Subscribe(req *bridgepb.SubscribeRequest, stream grpc.ServerStreamingServer[bridgepb.Event]) error {
+	if req.Channel == "" {
+		return status.Error(codes.InvalidArgument, "channel is required")
+	}
+	if err := self.authorize(stream.Context(), req.Channel); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	overflow := make(chan struct{})
+	overflowed := false
+	events := relay.Start(self.client, []string{req.Channel}, self.Buffer, func(event pusher.Event) (*bridgepb.Event, bool) {
+		if req.Event != "" && event.Name != req.Event {
+			return nil, false
+		}
+		return &bridgepb.Event{
+			Channel: event.Channel,
+			Event:   event.Name,
+			Data:    event.Data,
+			UserId:  event.UserId,
+		}, true
+	}, func(*bridgepb.Event) {
+		if !overflowed {
+			overflowed = true
+			close(overflow)
+		}
+	})
+	// Releases the stream's channel, and its bindings, when the stream ends
+	defer events.Close()
+
+	for {
+		select {
+		case event := <-events.Queue():
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "stream fell too far behind")
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (self *Server) authorize(ctx context.Context, channel string) error {
+	if self.Authorize != nil {
+		return self.Authorize(ctx, channel)
+	}
+	if s.HasPrefix(channel, "private-") || s.HasPrefix(channel, "presence-") {
+		return errors.New("private and presence channels require Authorize")
+	}
+	return nil
+}
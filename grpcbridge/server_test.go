@@ -0,0 +1,119 @@
+package grpcbridge_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/grpcbridge"
+	"github.com/mnaser/pusher-websocket-go/grpcbridge/bridgepb"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// serve starts a bridge for a client of srv, returning a gRPC client of it
+func serve(t *testing.T, srv *pushertest.Server, configure func(*grpcbridge.Server)) bridgepb.BridgeClient {
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	t.Cleanup(client.Disconnect)
+	bridge := grpcbridge.NewServer(client)
+	if configure != nil {
+		configure(bridge)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	bridge.Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bridge",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return bridgepb.NewBridgeClient(conn)
+}
+
+// eventually waits for condition to hold
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	bridge := serve(t, srv, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	streamCtx, end := context.WithCancel(ctx)
+	stream, err := bridge.Subscribe(streamCtx, &bridgepb.SubscribeRequest{Channel: "x", Event: "wanted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return srv.Subscribers("x") == 1 }, "the bridge did not subscribe")
+	srv.Trigger("x", "unwanted", "skipped")
+	srv.Trigger("x", "wanted", map[string]int{"a": 1})
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Channel != "x" || event.Event != "wanted" || event.Data != `{"a":1}` {
+		t.Fatalf("unexpected event %v", event)
+	}
+
+	end()
+	eventually(t, func() bool { return srv.Subscribers("x") == 0 }, "the channel was not released when the stream ended")
+}
+
+func TestSubscribeRejected(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	denied := serve(t, srv, func(bridge *grpcbridge.Server) {
+		bridge.Authorize = func(ctx context.Context, channel string) error {
+			if channel == "forbidden" {
+				return errors.New("not allowed")
+			}
+			return nil
+		}
+	})
+	bridge := serve(t, srv, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for _, test := range []struct {
+		bridge  bridgepb.BridgeClient
+		channel string
+		code    codes.Code
+	}{
+		{bridge, "", codes.InvalidArgument},
+		{bridge, "private-x", codes.PermissionDenied},
+		{bridge, "presence-x", codes.PermissionDenied},
+		{denied, "forbidden", codes.PermissionDenied},
+	} {
+		stream, err := test.bridge.Subscribe(ctx, &bridgepb.SubscribeRequest{Channel: test.channel})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != test.code {
+			t.Errorf("subscribing to %q failed with %v, expected %v", test.channel, err, test.code)
+		}
+	}
+}
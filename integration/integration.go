@@ -0,0 +1,84 @@
+//go:build integration
+
+// Package integration is an opt-in suite which exercises the client against
+// a real Pusher app or a Pusher-compatible server such as soketi:
+//
+//	PUSHER_KEY=... PUSHER_SECRET=... go test -tags integration ./integration
+//
+// The app must have client events enabled. The helpers used by the suite can
+// also be used by downstream projects in their own integration tests.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// Timeout for each step of the suite
+var Timeout = 10 * time.Second
+
+// ConfigFromEnv reads the app to test against from PUSHER_KEY, PUSHER_SECRET,
+// PUSHER_HOST, PUSHER_PORT and PUSHER_SCHEME, skipping the test when
+// PUSHER_KEY is not set
+func ConfigFromEnv(t testing.TB) pusher.ClientConfig {
+	t.Helper()
+
+	config := pusher.ClientConfig{
+		Scheme: getenv("PUSHER_SCHEME", "wss"),
+		Host:   getenv("PUSHER_HOST", "ws.pusherapp.com"),
+		Port:   getenv("PUSHER_PORT", "443"),
+		Key:    os.Getenv("PUSHER_KEY"),
+		Secret: os.Getenv("PUSHER_SECRET"),
+	}
+	if config.Key == "" {
+		t.Skip("PUSHER_KEY not set, skipping integration tests")
+	}
+	return config
+}
+
+func getenv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// ChannelName returns a unique channel name with the given prefix
+func ChannelName(prefix string) string {
+	return fmt.Sprintf("%vintegration-%v-%v", prefix, time.Now().UnixNano(), rand.Int63())
+}
+
+// NewClient connects a client, which is disconnected when the test ends.
+// Without an AuthFunc, private channels are signed with the app secret, as an
+// auth endpoint would.
+func NewClient(t testing.TB, config pusher.ClientConfig) *pusher.Client {
+	t.Helper()
+
+	if config.AuthFunc == nil && config.Secret != "" {
+		config.AuthFunc = func(socketID, channel string) (string, error) {
+			return pusher.GenerateAuth(config.Key, config.Secret, socketID, channel, nil), nil
+		}
+	}
+	client := pusher.NewWithConfig(config)
+	t.Cleanup(client.Disconnect)
+	return client
+}
+
+// Subscribe subscribes to a channel and waits for the subscription to succeed
+func Subscribe(t testing.TB, client *pusher.Client, name string) *pusher.Channel {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	channel := client.Subscribe(name)
+	if err := channel.WaitSubscribed(ctx); err != nil {
+		t.Fatalf("subscribing to %v: %v", name, err)
+	}
+	return channel
+}
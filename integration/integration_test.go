@@ -0,0 +1,112 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+func TestPublic(t *testing.T) {
+	config := ConfigFromEnv(t)
+	client := NewClient(t, config)
+	Subscribe(t, client, ChannelName(""))
+}
+
+func TestPrivate(t *testing.T) {
+	config := ConfigFromEnv(t)
+	if config.AuthFunc == nil && config.Secret == "" {
+		t.Skip("no AuthFunc or secret to authorize private channels")
+	}
+	client := NewClient(t, config)
+	Subscribe(t, client, ChannelName("private-"))
+}
+
+func TestPresence(t *testing.T) {
+	config := ConfigFromEnv(t)
+	if config.Secret == "" {
+		t.Skip("no secret to authorize presence channels")
+	}
+	client := NewClient(t, config)
+	client.UserData = pusher.Member{UserId: fmt.Sprint("user-", rand.Int63())}
+	channel := Subscribe(t, client, ChannelName("presence-"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	members, err := channel.AwaitMembers(ctx)
+	if err != nil {
+		t.Fatalf("awaiting members: %v", err)
+	}
+	if len(members) != 1 || members[0].UserId != client.UserData.UserId {
+		t.Fatalf("expected only %v as member, got %v", client.UserData.UserId, members)
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	config := ConfigFromEnv(t)
+	if config.AuthFunc == nil && config.Secret == "" {
+		t.Skip("no AuthFunc or secret to authorize private channels")
+	}
+	name := ChannelName("private-")
+	sender := Subscribe(t, NewClient(t, config), name)
+	receiver := Subscribe(t, NewClient(t, config), name)
+
+	received := make(chan interface{}, 1)
+	receiver.Bind("client-integration", func(data interface{}) {
+		received <- data
+	})
+	if err := sender.Trigger("client-integration", map[string]string{"hello": "world"}); err != nil {
+		t.Fatalf("triggering client event: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if data != `{"hello":"world"}` {
+			t.Fatalf("unexpected client event data %v", data)
+		}
+	case <-time.After(Timeout):
+		t.Fatal("timed out waiting for client event")
+	}
+}
+
+// TestReconnect drops the receiver's connection using chaos mode, while the
+// sender keeps triggering events, and waits for the resubscription
+func TestReconnect(t *testing.T) {
+	config := ConfigFromEnv(t)
+	if config.AuthFunc == nil && config.Secret == "" {
+		t.Skip("no AuthFunc or secret to authorize private channels")
+	}
+	name := ChannelName("private-")
+	sender := Subscribe(t, NewClient(t, config), name)
+
+	chaosConfig := config
+	chaosConfig.Chaos = &pusher.ChaosConfig{Seed: 1, DropProbability: 0.3}
+	receiver := NewClient(t, chaosConfig).Subscribe(name)
+
+	resubscribed := make(chan struct{}, 1)
+	receiver.OnResubscribed(func() {
+		select {
+		case resubscribed <- struct{}{}:
+		default:
+		}
+	})
+
+	deadline := time.After(3 * Timeout)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-resubscribed:
+			return
+		case <-ticker.C:
+			sender.Trigger("client-integration", map[string]string{})
+		case <-deadline:
+			t.Fatal("timed out waiting for resubscription")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Package relay hands the events a pusher client receives on some channels to
+// a goroutine of their own, as the bridges and sinks do. Events arrive on the
+// client's run loop, which must never block, so they are queued, and dropped
+// when the queue is full.
+package relay
+
+import (
+	"sync"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// Relay queues the events of its channels, received through a facade of the
+// client, until it is closed
+type Relay[T any] struct {
+	facade   *pusher.Client
+	channels map[string]*pusher.Channel
+	queue    chan T
+	mutex    sync.Mutex
+	closed   bool
+}
+
+// Start subscribes a facade of client to channels, passing each of their
+// events to convert and queueing the result, up to buffer items. Events for
+// which convert returns false are skipped, and items which do not fit in the
+// queue are passed to drop instead. Both are called on the client's run loop.
+func Start[T any](client *pusher.Client, channels []string, buffer int, convert func(pusher.Event) (T, bool), drop func(T)) *Relay[T] {
+	self := &Relay[T]{
+		facade:   client.NewFacade(),
+		channels: make(map[string]*pusher.Channel, len(channels)),
+		queue:    make(chan T, buffer),
+	}
+
+	names := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		names[channel] = true
+	}
+	self.facade.BindGlobalEvent(func(event pusher.Event) {
+		if !names[event.Channel] {
+			return
+		}
+		item, ok := convert(event)
+		if !ok {
+			return
+		}
+
+		self.mutex.Lock()
+		defer self.mutex.Unlock()
+		if self.closed {
+			return
+		}
+		select {
+		case self.queue <- item:
+		default:
+			drop(item)
+		}
+	})
+	for _, channel := range channels {
+		self.channels[channel] = self.facade.Subscribe(channel)
+	}
+	return self
+}
+
+// Queue returns the queued items, which is closed by Close
+func (self *Relay[T]) Queue() <-chan T {
+	return self.queue
+}
+
+// Channel returns one of the relay's channels
+func (self *Relay[T]) Channel(name string) *pusher.Channel {
+	return self.channels[name]
+}
+
+// Close unsubscribes the relay's channels, releasing them and their bindings,
+// and closes the queue. It does nothing on a nil or already closed relay.
+func (self *Relay[T]) Close() {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	if self.closed {
+		self.mutex.Unlock()
+		return
+	}
+	self.closed = true
+	close(self.queue)
+	self.mutex.Unlock()
+	self.facade.Disconnect()
+}
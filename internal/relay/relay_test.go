@@ -0,0 +1,108 @@
+package relay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/internal/relay"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// start starts a relay of event names on channels, waiting until they are
+// subscribed
+func start(t *testing.T, srv *pushertest.Server, buffer int, channels []string, drop func(string)) *relay.Relay[string] {
+	t.Helper()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	t.Cleanup(client.Disconnect)
+	r := relay.Start(client, channels, buffer, func(event pusher.Event) (string, bool) {
+		return event.Channel + " " + event.Name, event.Name != "skipped"
+	}, drop)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, channel := range channels {
+		if err := r.Channel(channel).WaitSubscribed(ctx); err != nil {
+			t.Fatalf("subscribing to %v: %v", channel, err)
+		}
+	}
+	return r
+}
+
+// TestRelay queues the converted events of the relay's channels, skipping
+// those convert rejects and those of other channels
+func TestRelay(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	r := start(t, srv, 8, []string{"a", "b"}, func(string) { t.Error("unexpected drop") })
+
+	other := pusher.NewWithConfig(srv.ClientConfig())
+	defer other.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := other.SubscribeWithResult(ctx, "c"); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	srv.Trigger("c", "ignored", "{}")
+	srv.Trigger("a", "skipped", "{}")
+	srv.Trigger("a", "first", "{}")
+	srv.Trigger("b", "second", "{}")
+	for _, expected := range []string{"a first", "b second"} {
+		select {
+		case item := <-r.Queue():
+			if item != expected {
+				t.Fatalf("expected %q, got %q", expected, item)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+
+	r.Close()
+	if _, ok := <-r.Queue(); ok {
+		t.Fatal("expected the queue to be closed")
+	}
+	r.Close()
+	eventually(t, func() bool { return srv.Subscribers("a") == 0 }, "the relay's channels were not released")
+}
+
+// TestRelayDrop fills the queue, after which items are passed to drop
+func TestRelayDrop(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	dropped := make(chan string, 1)
+	r := start(t, srv, 1, []string{"a"}, func(item string) { dropped <- item })
+	defer r.Close()
+
+	srv.Trigger("a", "kept", "{}")
+	srv.Trigger("a", "lost", "{}")
+	select {
+	case item := <-dropped:
+		if item != "a lost" {
+			t.Fatalf("expected the second event to be dropped, got %q", item)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing was dropped")
+	}
+	if item := <-r.Queue(); item != "a kept" {
+		t.Fatalf("expected the first event to be kept, got %q", item)
+	}
+}
+
+func TestCloseNil(t *testing.T) {
+	var r *relay.Relay[string]
+	r.Close()
+}
+
+// eventually fails the test unless condition becomes true within a while
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
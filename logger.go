@@ -0,0 +1,53 @@
+package pusher
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// Logger receives a client's diagnostics, so that they can be routed into an
+// application's own logging
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// stdLogger writes to the standard log package when the package-level Debug
+// or its client's Debug is set
+type stdLogger struct {
+	debug *atomic.Bool
+}
+
+func (self stdLogger) printf(format string, args ...interface{}) {
+	if Debug || (self.debug != nil && self.debug.Load()) {
+		log.Printf(format, args...)
+	}
+}
+
+func (self stdLogger) Debugf(format string, args ...interface{}) {
+	self.printf(format, args...)
+}
+
+func (self stdLogger) Infof(format string, args ...interface{}) {
+	self.printf(format, args...)
+}
+
+func (self stdLogger) Errorf(format string, args ...interface{}) {
+	self.printf(format, args...)
+}
+
+// logger returns the configured logger, or one writing to the log package
+func (c ClientConfig) logger() Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return stdLogger{debug: c.debug}
+}
+
+// SetDebug enables or disables diagnostics for the client, and any facades
+// sharing its connection, while it is running. It has no effect on a custom
+// Logger.
+func (self *Client) SetDebug(debug bool) {
+	self.debug.Store(debug)
+}
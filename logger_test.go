@@ -0,0 +1,137 @@
+package pusher_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// recordingLogger records a client's diagnostics by level
+type recordingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (self *recordingLogger) record(level, format string, args ...interface{}) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.lines = append(self.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (self *recordingLogger) Debugf(format string, args ...interface{}) {
+	self.record("debug", format, args...)
+}
+
+func (self *recordingLogger) Infof(format string, args ...interface{}) {
+	self.record("info", format, args...)
+}
+
+func (self *recordingLogger) Errorf(format string, args ...interface{}) {
+	self.record("error", format, args...)
+}
+
+// find returns the first line recorded starting with prefix
+func (self *recordingLogger) find(prefix string) string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, line := range self.lines {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	return ""
+}
+
+// TestLogger routes a client's diagnostics to its own Logger, regardless of
+// Debug
+func TestLogger(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	logger := &recordingLogger{}
+	config := srv.ClientConfig()
+	config.Logger = logger
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	waitState(t, client, pusher.StateConnected)
+
+	if logger.find("debug Connecting to ") == "" {
+		t.Fatal("connecting was not logged")
+	}
+	client.Subscribe("private-x")
+	deadline := time.Now().Add(2 * time.Second)
+	for logger.find("error Authorization for private-x failed") == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("the authorization failure was not logged as an error")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// lockedBuffer collects output written from several goroutines, such as the
+// log package's or a recording's
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   strings.Builder
+}
+
+func (self *lockedBuffer) Write(p []byte) (int, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.buf.Write(p)
+}
+
+func (self *lockedBuffer) String() string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.buf.String()
+}
+
+// TestSetDebug logs one client's diagnostics only while its Debug is set
+func TestSetDebug(t *testing.T) {
+	var output lockedBuffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	quiet := pusher.NewWithConfig(srv.ClientConfig())
+	defer quiet.Disconnect()
+	// Quieten it again before the output is restored
+	defer quiet.SetDebug(false)
+	waitState(t, quiet, pusher.StateConnected)
+	if logged := output.String(); logged != "" {
+		t.Fatalf("logged without Debug: %v", logged)
+	}
+
+	config := srv.ClientConfig()
+	config.Debug = true
+	verbose := pusher.NewWithConfig(config)
+	defer verbose.Disconnect()
+	waitState(t, verbose, pusher.StateConnected)
+	if !strings.Contains(output.String(), "Connecting to") {
+		t.Fatal("did not log with Debug")
+	}
+
+	verbose.SetDebug(false)
+	quiet.SetDebug(true)
+	before := output.String()
+	verbose.Subscribe("verbose")
+	quiet.Subscribe("quiet")
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(output.String(), "quiet") {
+		if time.Now().After(deadline) {
+			t.Fatal("did not log once SetDebug enabled diagnostics")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if logged := strings.TrimPrefix(output.String(), before); strings.Contains(logged, "verbose") {
+		t.Fatalf("logged once SetDebug disabled diagnostics: %v", logged)
+	}
+}
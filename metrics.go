@@ -0,0 +1,54 @@
+package pusher
+
+import (
+	"time"
+)
+
+// MetricsCollector receives a client's operational metrics, so that it can be
+// wired into existing monitoring. Its methods are called from the run loop,
+// from the goroutine calling Trigger for sent messages, and from binding
+// goroutines for handler latency, so they must be safe for concurrent use and
+// must not block.
+type MetricsCollector interface {
+	// Connected is called once the connection is established
+	Connected()
+	// Disconnected is called when the connection closes or is closed
+	Disconnected()
+	// MessageReceived is called for every received event, with the size of
+	// its data
+	MessageReceived(channel, event string, size int)
+	// MessageSent is called for every client event triggered, with the size
+	// of the encoded message
+	MessageSent(channel, event string, size int)
+	SubscriptionSucceeded(channel string)
+	SubscriptionFailed(channel string, err error)
+	// Reconnecting is called when a reconnection is scheduled, with the
+	// number of consecutive attempts and the delay before the next one
+	Reconnecting(attempt int, delay time.Duration)
+	// HandlerLatency is called after a channel binding has handled an event,
+	// with the time it took
+	HandlerLatency(channel, event string, duration time.Duration)
+	// PingRTT is called with the round trip time of each pusher:ping sent
+	// on an idle connection
+	PingRTT(rtt time.Duration)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) Connected()                                            {}
+func (noopMetrics) Disconnected()                                         {}
+func (noopMetrics) MessageReceived(channel, event string, size int)       {}
+func (noopMetrics) MessageSent(channel, event string, size int)           {}
+func (noopMetrics) SubscriptionSucceeded(channel string)                  {}
+func (noopMetrics) SubscriptionFailed(channel string, err error)          {}
+func (noopMetrics) Reconnecting(attempt int, delay time.Duration)         {}
+func (noopMetrics) HandlerLatency(channel, event string, d time.Duration) {}
+func (noopMetrics) PingRTT(rtt time.Duration)                             {}
+
+// metrics returns the configured collector, or one which discards metrics
+func (c ClientConfig) metrics() MetricsCollector {
+	if c.Metrics != nil {
+		return c.Metrics
+	}
+	return noopMetrics{}
+}
@@ -0,0 +1,139 @@
+package pusher_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// latencies reports the events of HandlerLatency, discarding other metrics
+type latencies chan string
+
+func (latencies) Connected()                                      {}
+func (latencies) Disconnected()                                   {}
+func (latencies) MessageReceived(channel, event string, size int) {}
+func (latencies) MessageSent(channel, event string, size int)     {}
+func (latencies) SubscriptionSucceeded(channel string)            {}
+func (latencies) SubscriptionFailed(channel string, err error)    {}
+func (latencies) Reconnecting(attempt int, delay time.Duration)   {}
+func (latencies) PingRTT(rtt time.Duration)                       {}
+
+func (self latencies) HandlerLatency(channel, event string, duration time.Duration) {
+	self <- event
+}
+
+// TestHandlerLatencyEventName binds to an event pattern before setting
+// Metrics, whose latency must still be reported, under the event's own name
+func TestHandlerLatencyEventName(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "orders")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	channel.BindEventPattern("order-*", func(event string, data interface{}) {})
+	reported := make(latencies, 1)
+	client.Metrics = reported
+
+	srv.Trigger("orders", "order-created", "{}")
+	select {
+	case event := <-reported:
+		if event != "order-created" {
+			t.Fatalf("latency reported for %v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no latency reported")
+	}
+}
+
+// recordedMetrics reports each metric as a string
+type recordedMetrics chan string
+
+func (self recordedMetrics) record(format string, args ...interface{}) {
+	select {
+	case self <- fmt.Sprintf(format, args...):
+	default:
+	}
+}
+
+func (self recordedMetrics) Connected()    { self.record("connected") }
+func (self recordedMetrics) Disconnected() { self.record("disconnected") }
+func (self recordedMetrics) MessageReceived(channel, event string, size int) {
+	self.record("received %v %v", channel, event)
+}
+func (self recordedMetrics) MessageSent(channel, event string, size int) {
+	self.record("sent %v %v", channel, event)
+}
+func (self recordedMetrics) SubscriptionSucceeded(channel string) {
+	self.record("subscribed %v", channel)
+}
+func (self recordedMetrics) SubscriptionFailed(channel string, err error) {
+	self.record("failed %v", channel)
+}
+func (self recordedMetrics) Reconnecting(attempt int, delay time.Duration) {
+	self.record("reconnecting %v", attempt)
+}
+func (self recordedMetrics) PingRTT(rtt time.Duration) {}
+func (self recordedMetrics) HandlerLatency(channel, event string, duration time.Duration) {
+	self.record("handled %v %v", channel, event)
+}
+
+// expectMetric waits for a metric, skipping others
+func expectMetric(t *testing.T, metrics recordedMetrics, expected string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case metric := <-metrics:
+			if metric == expected {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v", expected)
+		}
+	}
+}
+
+// TestMetrics connects, subscribes, sends and receives events and
+// reconnects, each of which is reported to the collector
+func TestMetrics(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	metrics := make(recordedMetrics, 100)
+	config := srv.ClientConfig()
+	config.Metrics = metrics
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	client.UserData = pusher.Member{UserId: "alice"}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+
+	expectMetric(t, metrics, "connected")
+	channel := client.Subscribe("presence-orders")
+	expectMetric(t, metrics, "subscribed presence-orders")
+	if err := channel.Trigger("client-typing", "{}"); err != nil {
+		t.Fatalf("triggering: %v", err)
+	}
+	expectMetric(t, metrics, "sent presence-orders client-typing")
+	channel.Bind("created", func(interface{}) {})
+	srv.Trigger("presence-orders", "created", "{}")
+	expectMetric(t, metrics, "received presence-orders created")
+	expectMetric(t, metrics, "handled presence-orders created")
+
+	client.Subscribe("private-orders")
+	expectMetric(t, metrics, "failed private-orders")
+	srv.DisconnectAll(4200, "reconnect")
+	expectMetric(t, metrics, "disconnected")
+	expectMetric(t, metrics, "connected")
+}
@@ -0,0 +1,138 @@
+package pusher
+
+import (
+	"encoding/json"
+)
+
+// Middleware processes an application event before it reaches any binding.
+// It continues by passing the event, possibly modified, to next, or drops it
+// by returning without calling next.
+type Middleware func(event Event, next func(Event))
+
+// DropEvents returns middleware which drops the named events, e.g. noisy
+// client events which no binding needs. It is registered as the "drop"
+// middleware, with its events configured as {"events": ["client-typing"]}.
+func DropEvents(events ...string) Middleware {
+	dropped := map[string]bool{}
+	for _, event := range events {
+		dropped[event] = true
+	}
+	return func(event Event, next func(Event)) {
+		if !dropped[event.Name] {
+			next(event)
+		}
+	}
+}
+
+func init() {
+	Middlewares.Register("drop", func(config json.RawMessage) (Middleware, error) {
+		var options struct {
+			Events []string `json:"events"`
+		}
+		if err := json.Unmarshal(config, &options); err != nil {
+			return nil, err
+		}
+		return DropEvents(options.Events...), nil
+	})
+}
+
+// Use adds middleware which every application event passes through, in the
+// order added, before bindings fire, e.g. for logging, metrics, validation or
+// transforming payloads in one place:
+//
+//	client.Use(func(event pusher.Event, next func(pusher.Event)) {
+//		start := time.Now()
+//		next(event)
+//		log.Printf("%v %v took %v", event.Channel, event.Name, time.Since(start))
+//	})
+//
+// Middleware runs on the run loop, so should not block. Protocol events such
+// as pusher:connection_established do not pass through it. Middleware added
+// to a facade applies to every event of the connection.
+func (self *Client) Use(middleware Middleware) {
+	root := self.root()
+	root.bindingsMutex.Lock()
+	defer root.bindingsMutex.Unlock()
+	root.middleware = append(root.middleware, middleware)
+}
+
+// applyMiddleware passes an event through the middleware chain, ending with
+// dispatch
+func (self *Client) applyMiddleware(event Event, dispatch func(Event)) {
+	self.bindingsMutex.RLock()
+	chain := self.middleware
+	self.bindingsMutex.RUnlock()
+	defer self.recoverHandler(nil, event.Channel, event.Name)
+
+	var next func(int, Event)
+	next = func(i int, event Event) {
+		if i == len(chain) {
+			dispatch(event)
+			return
+		}
+		chain[i](event, func(event Event) { next(i+1, event) })
+	}
+	next(0, event)
+}
+
+// OutgoingMessage is a message about to be sent to the server: a
+// subscription, an unsubscription or a client event. Channel is only set for
+// client events; the data of pusher:subscribe and pusher:unsubscribe is a
+// map[string]string holding the channel name instead.
+type OutgoingMessage struct {
+	Event   string
+	Channel string
+	Data    interface{}
+}
+
+// Interceptor processes an outgoing message before it is sent. It continues
+// by passing the message, possibly modified, to next. Returning without
+// calling next drops the message, and an error returned is reported to the
+// caller of Trigger, or as a subscription error.
+type Interceptor func(message OutgoingMessage, next func(OutgoingMessage) error) error
+
+// Intercept adds an interceptor which every outgoing subscription,
+// unsubscription and client event passes through, in the order added, e.g.
+// for audit logging, enriching client events or vetoing messages:
+//
+//	client.Intercept(func(message pusher.OutgoingMessage, next func(pusher.OutgoingMessage) error) error {
+//		if message.Event == "client-delete" && !admin {
+//			return errors.New("not allowed")
+//		}
+//		return next(message)
+//	})
+//
+// Interceptors added to a facade apply to every message of the connection.
+func (self *Client) Intercept(interceptor Interceptor) {
+	root := self.root()
+	root.bindingsMutex.Lock()
+	defer root.bindingsMutex.Unlock()
+	root.interceptors = append(root.interceptors, interceptor)
+}
+
+// intercept passes a message through the interceptors and encodes it,
+// returning nil if it was dropped
+func (self *Client) intercept(message OutgoingMessage) ([]byte, error) {
+	root := self.root()
+	root.bindingsMutex.RLock()
+	chain := root.interceptors
+	root.bindingsMutex.RUnlock()
+
+	var encoded []byte
+	var next func(int, OutgoingMessage) error
+	next = func(i int, message OutgoingMessage) (err error) {
+		if i < len(chain) {
+			return chain[i](message, func(message OutgoingMessage) error { return next(i+1, message) })
+		}
+		var channel *string
+		if message.Channel != "" {
+			channel = &message.Channel
+		}
+		encoded, err = encode(message.Event, message.Data, channel)
+		return err
+	}
+	if err := next(0, message); err != nil {
+		return nil, err
+	}
+	return encoded, nil
+}
@@ -0,0 +1,118 @@
+package pusher_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// subscribed returns a client of srv subscribed to channel
+func subscribed(t *testing.T, srv *pushertest.Server, channel string) (*pusher.Client, *pusher.Channel) {
+	t.Helper()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	t.Cleanup(client.Disconnect)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	subscription, err := client.SubscribeWithResult(ctx, channel)
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	return client, subscription
+}
+
+func TestUse(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client, channel := subscribed(t, srv, "x")
+
+	var order []string
+	client.Use(func(event pusher.Event, next func(pusher.Event)) {
+		order = append(order, "first")
+		event.Data = "changed"
+		next(event)
+	})
+	client.Use(func(event pusher.Event, next func(pusher.Event)) {
+		order = append(order, "second")
+		next(event)
+	})
+	client.Use(pusher.DropEvents("noisy"))
+	received := make(chan interface{}, 10)
+	channel.Bind("event", func(data interface{}) { received <- data })
+	channel.Bind("noisy", func(data interface{}) { received <- "noisy" })
+
+	srv.Trigger("x", "noisy", "dropped")
+	srv.Trigger("x", "event", "original")
+	if data := receive(t, received); data != "changed" {
+		t.Fatalf("received %v, expected the data transformed by middleware", data)
+	}
+	if len(order) != 4 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("middleware ran in order %v", order)
+	}
+}
+
+func TestDropMiddlewarePlugin(t *testing.T) {
+	middleware, err := pusher.Middlewares.New("drop", []byte(`{"events": ["client-typing"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var passed []string
+	for _, name := range []string{"client-typing", "client-message"} {
+		middleware(pusher.Event{Name: name}, func(event pusher.Event) { passed = append(passed, event.Name) })
+	}
+	if len(passed) != 1 || passed[0] != "client-message" {
+		t.Fatalf("expected only client-message to pass, got %v", passed)
+	}
+	if _, err := pusher.Middlewares.New("drop", []byte(`{"events": "client-typing"}`)); err == nil {
+		t.Fatal("expected invalid config to be rejected")
+	}
+}
+
+func TestIntercept(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	received := make(chan interface{}, 10)
+	srv.OnClientEvent = func(socketID string, event pusher.Event) { received <- event }
+	client := connectAs(t, srv, "alice")
+	vetoed := errors.New("not allowed")
+	client.Intercept(func(message pusher.OutgoingMessage, next func(pusher.OutgoingMessage) error) error {
+		switch message.Event {
+		case "client-delete":
+			return vetoed
+		case "client-typing":
+			return nil
+		case "client-message":
+			message.Data = map[string]string{"text": "hello", "via": "interceptor"}
+		}
+		return next(message)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "presence-x")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	if err := channel.Trigger("client-delete", nil); err != vetoed {
+		t.Fatalf("expected the interceptor's error, got %v", err)
+	}
+	if err := channel.Trigger("client-typing", nil); err != nil {
+		t.Fatalf("expected a dropped message to succeed, got %v", err)
+	}
+	if err := channel.Trigger("client-message", map[string]string{"text": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	event := receive(t, received).(pusher.Event)
+	if event.Name != "client-message" || !strings.Contains(event.Data, `"via":"interceptor"`) {
+		t.Fatalf("server received %+v, expected the intercepted client-message", event)
+	}
+	select {
+	case event := <-received:
+		t.Fatalf("server received %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,33 @@
+//go:build pusher_minimal
+
+package pusher_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// TestMinimalFallback checks that pusher_minimal builds leave out the HTTP
+// fallback, while still connecting over WebSocket
+func TestMinimalFallback(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	for _, transport := range []string{pusher.TransportXHRStreaming, pusher.TransportXHRPolling} {
+		config := srv.ClientConfig()
+		config.DisableAutoConnect = true
+		config.Transports = []string{transport}
+		client := pusher.NewWithConfig(config)
+		err := client.Connect()
+		client.Disconnect()
+		if err == nil || !strings.Contains(err.Error(), "not available in pusher_minimal builds") {
+			t.Errorf("expected %v to be unavailable, got %v", transport, err)
+		}
+	}
+
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	waitState(t, client, pusher.StateConnected)
+}
@@ -0,0 +1,151 @@
+// Package mobile provides a simplified facade over the pusher client which
+// can be bound with gomobile, for use from Android and iOS applications.
+//
+// Exported signatures only use types supported by gomobile: event data is
+// always delivered as a string (JSON encoded where it is not already a
+// string), and callbacks are interfaces implemented on the host platform.
+package mobile
+
+import (
+	"encoding/json"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// EventListener receives events bound on a channel or globally
+type EventListener interface {
+	OnEvent(channel, event, data string)
+}
+
+// Authorizer signs subscriptions to private channels, typically by calling
+// the application's auth endpoint
+type Authorizer interface {
+	Authorize(socketID, channel string) (string, error)
+}
+
+// Config holds the connection settings for a Client
+type Config struct {
+	Scheme         string
+	Host           string
+	Port           string
+	PathPrefix     string
+	Key            string
+	Secret         string
+	EnableFallback bool
+	// FallbackHost serves the HTTP fallback, defaulting to Host. NewConfig
+	// sets Pusher's own, sockjs.pusher.com, so clear it when changing Host to
+	// a self-hosted server.
+	FallbackHost string
+	ReadOnly     bool
+}
+
+// NewConfig returns a config for connecting to Pusher with the given key,
+// with the same defaults as pusher.New
+func NewConfig(key string) *Config {
+	defaults := pusher.DefaultConfig(key)
+	return &Config{
+		Scheme:       defaults.Scheme,
+		Host:         defaults.Host,
+		Port:         defaults.Port,
+		Key:          defaults.Key,
+		FallbackHost: defaults.FallbackHost,
+	}
+}
+
+// Client is a connection to Pusher and the channels subscribed over it
+type Client struct {
+	client *pusher.Client
+}
+
+// NewClient creates a client, which connects once Connect is called
+func NewClient(config *Config) *Client {
+	return &Client{client: pusher.NewWithConfig(pusher.ClientConfig{
+		Scheme:             config.Scheme,
+		Host:               config.Host,
+		Port:               config.Port,
+		PathPrefix:         config.PathPrefix,
+		Key:                config.Key,
+		Secret:             config.Secret,
+		EnableFallback:     config.EnableFallback,
+		FallbackHost:       config.FallbackHost,
+		ReadOnly:           config.ReadOnly,
+		DisableAutoConnect: true,
+	})}
+}
+
+// Connect starts connecting, and returns the error of the first attempt. The
+// client keeps retrying in the background either way. SetAuthorizer and
+// SetUser must be called before.
+func (self *Client) Connect() error {
+	return self.client.Connect()
+}
+
+// SetAuthorizer sets the authorizer used for private channels. It must be
+// called before Connect.
+func (self *Client) SetAuthorizer(authorizer Authorizer) {
+	self.client.AuthFunc = authorizer.Authorize
+}
+
+// SetUser sets the member data sent when subscribing to presence channels.
+// userInfo is a JSON object of strings, and may be empty. It must be called
+// before Connect.
+func (self *Client) SetUser(userID, userInfo string) error {
+	member := pusher.Member{UserId: userID}
+	if userInfo != "" {
+		if err := json.Unmarshal([]byte(userInfo), &member.UserInfo); err != nil {
+			return err
+		}
+	}
+	self.client.UserData = member
+	return nil
+}
+
+// Subscribe subscribes to a channel, returning it at once so that events can
+// be bound before the subscription succeeds
+func (self *Client) Subscribe(channel string) *Channel {
+	return &Channel{channel: self.client.Subscribe(channel)}
+}
+
+// Unsubscribe unsubscribes from a channel
+func (self *Client) Unsubscribe(channel string) {
+	self.client.Unsubscribe(channel)
+}
+
+// BindGlobal binds a listener to every event on every channel
+func (self *Client) BindGlobal(listener EventListener) {
+	self.client.BindGlobal(func(channel, event string, data interface{}) {
+		listener.OnEvent(channel, event, pusher.DataString(data))
+	})
+}
+
+// Disconnect closes the connection, after which the client cannot be used
+func (self *Client) Disconnect() {
+	self.client.Disconnect()
+}
+
+// Channel is a channel subscribed by a Client
+type Channel struct {
+	channel *pusher.Channel
+}
+
+// Name returns the name of the channel
+func (self *Channel) Name() string {
+	return self.channel.Name
+}
+
+// Bind binds a listener to an event on this channel
+func (self *Channel) Bind(event string, listener EventListener) {
+	name := self.channel.Name
+	self.channel.Bind(event, func(data interface{}) {
+		listener.OnEvent(name, event, pusher.DataString(data))
+	})
+}
+
+// Trigger sends a client event. data is sent as JSON when it is valid JSON,
+// and as a plain string otherwise.
+func (self *Channel) Trigger(event, data string) error {
+	if json.Valid([]byte(data)) {
+		return self.channel.Trigger(event, json.RawMessage(data))
+	}
+	return self.channel.Trigger(event, data)
+}
@@ -0,0 +1,82 @@
+package mobile_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/mobile"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// listener passes the application events it receives to a channel
+type listener chan [3]string
+
+func (self listener) OnEvent(channel, event, data string) {
+	if !strings.HasPrefix(event, "pusher") {
+		self <- [3]string{channel, event, data}
+	}
+}
+
+// TestNewConfig checks that NewConfig starts from the package defaults,
+// including the host of the HTTP fallback
+func TestNewConfig(t *testing.T) {
+	config := mobile.NewConfig("key")
+	defaults := pusher.DefaultConfig("key")
+	if config.Scheme != defaults.Scheme || config.Host != defaults.Host || config.Port != defaults.Port || config.Key != "key" {
+		t.Fatalf("unexpected endpoint in %+v", config)
+	}
+	if config.FallbackHost == "" || config.FallbackHost != defaults.FallbackHost {
+		t.Fatalf("expected fallback host %q, got %q", defaults.FallbackHost, config.FallbackHost)
+	}
+}
+
+func TestSetUserInvalid(t *testing.T) {
+	client := mobile.NewClient(mobile.NewConfig("key"))
+	defer client.Disconnect()
+	if err := client.SetUser("alice", "{"); err == nil {
+		t.Fatal("expected an error for user info which is not JSON")
+	}
+}
+
+// TestBind receives an event with object data through channel and global
+// bindings, each of which must be given its JSON encoding
+func TestBind(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	server := srv.ClientConfig()
+	config := &mobile.Config{Scheme: server.Scheme, Host: server.Host, Port: server.Port, Key: server.Key}
+	client := mobile.NewClient(config)
+	defer client.Disconnect()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+
+	received := make(listener, 2)
+	channel := client.Subscribe("orders")
+	if channel.Name() != "orders" {
+		t.Fatalf("unexpected channel name %q", channel.Name())
+	}
+	channel.Bind("created", received)
+	client.BindGlobal(received)
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.Subscribers("orders") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the client did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	srv.Trigger("orders", "created", map[string]int{"id": 1})
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-received:
+			if event != [3]string{"orders", "created", `{"id":1}`} {
+				t.Fatalf("unexpected event %v", event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("the event was not received")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package mqttbridge republishes the events received by a pusher client to an
+// MQTT broker, so that devices which only speak MQTT can consume them through
+// a single gateway process.
+package mqttbridge
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/internal/relay"
+)
+
+const (
+	// Default prefix of the topics events are published to
+	DefaultPrefix = "pusher/"
+	// Default number of events queued while the broker is unavailable
+	DefaultBuffer = 1024
+	// Default time to wait for the broker to acknowledge a publish
+	DefaultTimeout = 10 * time.Second
+)
+
+var (
+	// ErrDropped is reported to OnError for events dropped because the
+	// queue was full
+	ErrDropped = errors.New("mqttbridge: queue full, event dropped")
+	// ErrTimeout is reported to OnError for publishes the broker did not
+	// acknowledge in time
+	ErrTimeout = errors.New("mqttbridge: publish timed out")
+)
+
+// Publication is an event mapped onto MQTT
+type Publication struct {
+	Topic    string
+	QoS      byte
+	Retained bool
+	Payload  []byte
+}
+
+// Bridge subscribes to channels and publishes their events to MQTT. By
+// default event "bar" on channel "foo" is published to Prefix+"foo/bar" with
+// the bridge's QoS and Retained settings and the event data as payload.
+//
+// Configure the MQTT client with AutoReconnect so that publishing resumes
+// after the broker connection drops; the pusher client resubscribes on its
+// own.
+type Bridge struct {
+	client *pusher.Client
+	mqtt   mqtt.Client
+
+	Prefix   string
+	QoS      byte
+	Retained bool
+	// Map overrides the default mapping. Events for which it returns false
+	// are skipped.
+	Map func(pusher.Event) (Publication, bool)
+	// Buffer is the number of events queued while publishing is blocked
+	Buffer  int
+	Timeout time.Duration
+	// OnError is called for events which could not be published
+	OnError func(pusher.Event, error)
+
+	relay *relay.Relay[queued]
+	done  sync.WaitGroup
+}
+
+type queued struct {
+	event       pusher.Event
+	publication Publication
+}
+
+func New(client *pusher.Client, broker mqtt.Client) *Bridge {
+	return &Bridge{
+		client:  client,
+		mqtt:    broker,
+		Prefix:  DefaultPrefix,
+		Buffer:  DefaultBuffer,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Start subscribes to the given channels and begins publishing their events
+func (self *Bridge) Start(channels ...string) {
+	self.relay = relay.Start(self.client, channels, self.Buffer, func(event pusher.Event) (queued, bool) {
+		publication, ok := self.publication(event)
+		return queued{event, publication}, ok
+	}, func(item queued) {
+		self.fail(item.event, ErrDropped)
+	})
+
+	self.done.Add(1)
+	go self.run()
+}
+
+// Close unsubscribes and waits for queued events to be published. It does
+// nothing if the bridge was never started, or is already closed.
+func (self *Bridge) Close() {
+	if self.relay == nil {
+		return
+	}
+	self.relay.Close()
+	self.done.Wait()
+}
+
+func (self *Bridge) publication(event pusher.Event) (Publication, bool) {
+	if self.Map != nil {
+		return self.Map(event)
+	}
+	return Publication{
+		Topic:    self.Prefix + event.Channel + "/" + event.Name,
+		QoS:      self.QoS,
+		Retained: self.Retained,
+		Payload:  []byte(event.Data),
+	}, true
+}
+
+func (self *Bridge) run() {
+	defer self.done.Done()
+
+	for item := range self.relay.Queue() {
+		p := item.publication
+		token := self.mqtt.Publish(p.Topic, p.QoS, p.Retained, p.Payload)
+		if !token.WaitTimeout(self.Timeout) {
+			self.fail(item.event, ErrTimeout)
+		} else if err := token.Error(); err != nil {
+			self.fail(item.event, err)
+		}
+	}
+}
+
+func (self *Bridge) fail(event pusher.Event, err error) {
+	if self.OnError != nil {
+		self.OnError(event, err)
+	}
+}
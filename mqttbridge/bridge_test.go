@@ -0,0 +1,161 @@
+package mqttbridge_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/mqttbridge"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// fakeBroker records publications, acknowledging them with the result of ack
+type fakeBroker struct {
+	mqtt.Client
+	published chan mqttbridge.Publication
+	ack       func(mqttbridge.Publication) *token
+}
+
+func (self *fakeBroker) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	publication := mqttbridge.Publication{Topic: topic, QoS: qos, Retained: retained, Payload: payload.([]byte)}
+	self.published <- publication
+	if self.ack != nil {
+		return self.ack(publication)
+	}
+	return &token{done: closed()}
+}
+
+func closed() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// token completes when done is closed, with err
+type token struct {
+	done chan struct{}
+	err  error
+}
+
+func (self *token) Wait() bool {
+	<-self.done
+	return true
+}
+
+func (self *token) WaitTimeout(timeout time.Duration) bool {
+	select {
+	case <-self.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (self *token) Done() <-chan struct{} { return self.done }
+func (self *token) Error() error          { return self.err }
+
+// start starts a bridge of channels, waiting until they are subscribed
+func start(t *testing.T, srv *pushertest.Server, broker *fakeBroker, configure func(*mqttbridge.Bridge), channels ...string) *mqttbridge.Bridge {
+	t.Helper()
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	t.Cleanup(client.Disconnect)
+	bridge := mqttbridge.New(client, broker)
+	if configure != nil {
+		configure(bridge)
+	}
+	bridge.Start(channels...)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, channel := range channels {
+		if err := client.Subscribe(channel).WaitSubscribed(ctx); err != nil {
+			t.Fatalf("subscribing to %v: %v", channel, err)
+		}
+	}
+	return bridge
+}
+
+// next waits for the next publication
+func next(t *testing.T, broker *fakeBroker) mqttbridge.Publication {
+	t.Helper()
+	select {
+	case publication := <-broker.published:
+		return publication
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a publication")
+		return mqttbridge.Publication{}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	broker := &fakeBroker{published: make(chan mqttbridge.Publication, 10)}
+	bridge := start(t, srv, broker, func(bridge *mqttbridge.Bridge) {
+		bridge.QoS = 1
+		bridge.Retained = true
+	}, "orders")
+
+	srv.Trigger("orders", "created", map[string]int{"id": 1})
+	publication := next(t, broker)
+	if publication.Topic != "pusher/orders/created" || publication.QoS != 1 || !publication.Retained || string(publication.Payload) != `{"id":1}` {
+		t.Fatalf("unexpected publication %+v", publication)
+	}
+	bridge.Close()
+	bridge.Close()
+}
+
+func TestMap(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	broker := &fakeBroker{published: make(chan mqttbridge.Publication, 10)}
+	bridge := start(t, srv, broker, func(bridge *mqttbridge.Bridge) {
+		bridge.Map = func(event pusher.Event) (mqttbridge.Publication, bool) {
+			return mqttbridge.Publication{Topic: "devices/" + event.Name, Payload: []byte(event.Data)}, event.Name != "skipped"
+		}
+	}, "x")
+	defer bridge.Close()
+
+	srv.Trigger("x", "skipped", "{}")
+	srv.Trigger("x", "mapped", "{}")
+	if publication := next(t, broker); publication.Topic != "devices/mapped" {
+		t.Fatalf("unexpected publication %+v", publication)
+	}
+}
+
+func TestPublishErrors(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	refused := errors.New("not authorized")
+	broker := &fakeBroker{
+		published: make(chan mqttbridge.Publication, 10),
+		ack: func(publication mqttbridge.Publication) *token {
+			if publication.Topic == "pusher/x/refused" {
+				return &token{done: closed(), err: refused}
+			}
+			return &token{done: make(chan struct{})}
+		},
+	}
+	errs := make(chan error, 10)
+	bridge := start(t, srv, broker, func(bridge *mqttbridge.Bridge) {
+		bridge.Timeout = 10 * time.Millisecond
+		bridge.OnError = func(event pusher.Event, err error) { errs <- err }
+	}, "x")
+	defer bridge.Close()
+
+	srv.Trigger("x", "refused", "{}")
+	srv.Trigger("x", "unacknowledged", "{}")
+	for _, expected := range []error{refused, mqttbridge.ErrTimeout} {
+		select {
+		case err := <-errs:
+			if err != expected {
+				t.Fatalf("reported %v, expected %v", err, expected)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %v", expected)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package otel traces a pusher client with OpenTelemetry. Set a Tracer as
+// ClientConfig.Tracer to record spans for connections, subscriptions and the
+// dispatch of received events.
+package otel
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the instrumentation scope
+const instrumentationName = "github.com/mnaser/pusher-websocket-go"
+
+// Default field of event data carrying the trace context
+const DefaultContextField = "trace_context"
+
+// Tracer is a pusher.Tracer recording OpenTelemetry spans
+type Tracer struct {
+	Tracer     trace.Tracer
+	Propagator propagation.TextMapPropagator
+	// ContextField is the field of JSON event data carrying the trace
+	// context of the backend which triggered the event, either as an object
+	// of propagation headers such as traceparent, or as a traceparent
+	// string. Dispatch spans are children of the extracted context.
+	ContextField string
+}
+
+// New returns a tracer using the global tracer provider and propagator
+func New() *Tracer {
+	return &Tracer{
+		Tracer:       otel.Tracer(instrumentationName),
+		Propagator:   otel.GetTextMapPropagator(),
+		ContextField: DefaultContextField,
+	}
+}
+
+func (self *Tracer) StartConnect(transport string) func(err error) {
+	_, span := self.Tracer.Start(context.Background(), "pusher.connect",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("pusher.transport", transport)))
+	return endWithError(span)
+}
+
+func (self *Tracer) StartSubscribe(channel string) func(err error) {
+	_, span := self.Tracer.Start(context.Background(), "pusher.subscribe",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("pusher.channel", channel)))
+	return endWithError(span)
+}
+
+func (self *Tracer) StartDispatch(event pusher.Event) func() {
+	_, span := self.Tracer.Start(self.extract(event), "pusher.dispatch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("pusher.channel", event.Channel),
+			attribute.String("pusher.event", event.Name),
+		))
+	return func() { span.End() }
+}
+
+// extract returns a context holding the trace context carried by the event,
+// if any
+func (self *Tracer) extract(event pusher.Event) context.Context {
+	ctx := context.Background()
+	if self.ContextField == "" || self.Propagator == nil {
+		return ctx
+	}
+
+	var data map[string]json.RawMessage
+	if json.Unmarshal([]byte(event.Data), &data) != nil || data[self.ContextField] == nil {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	if json.Unmarshal(data[self.ContextField], &carrier) != nil {
+		var traceparent string
+		if json.Unmarshal(data[self.ContextField], &traceparent) != nil {
+			return ctx
+		}
+		carrier["traceparent"] = traceparent
+	}
+	return self.Propagator.Extract(ctx, carrier)
+}
+
+func endWithError(span trace.Span) func(err error) {
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
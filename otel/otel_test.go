@@ -0,0 +1,91 @@
+package otel_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go"
+	pusherotel "github.com/mnaser/pusher-websocket-go/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+// newTracer returns a tracer recording its spans
+func newTracer() (*pusherotel.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := pusherotel.New()
+	tracer.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	tracer.Propagator = propagation.TraceContext{}
+	return tracer, recorder
+}
+
+func TestSpans(t *testing.T) {
+	tracer, recorder := newTracer()
+	tracer.StartConnect("ws")(nil)
+	tracer.StartSubscribe("private-x")(errors.New("denied"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %v", len(spans))
+	}
+	connect, subscribe := spans[0], spans[1]
+	if connect.Name() != "pusher.connect" || connect.Status().Code == codes.Error ||
+		!hasAttribute(connect.Attributes(), attribute.String("pusher.transport", "ws")) {
+		t.Fatalf("unexpected connect span %v %v %v", connect.Name(), connect.Status(), connect.Attributes())
+	}
+	if subscribe.Name() != "pusher.subscribe" || subscribe.Status().Code != codes.Error || subscribe.Status().Description != "denied" ||
+		!hasAttribute(subscribe.Attributes(), attribute.String("pusher.channel", "private-x")) {
+		t.Fatalf("unexpected subscribe span %v %v %v", subscribe.Name(), subscribe.Status(), subscribe.Attributes())
+	}
+}
+
+// TestDispatchContext extracts the trace context of events, given either as
+// propagation headers or as a traceparent string
+func TestDispatchContext(t *testing.T) {
+	for _, test := range []struct {
+		data   string
+		traced bool
+	}{
+		{`{"trace_context":{"traceparent":"` + traceparent + `"}}`, true},
+		{`{"trace_context":"` + traceparent + `"}`, true},
+		{`{"trace_context":3}`, false},
+		{`{"other":1}`, false},
+		{`not json`, false},
+	} {
+		tracer, recorder := newTracer()
+		tracer.StartDispatch(pusher.Event{Name: "created", Channel: "orders", Data: test.data})()
+		span := recorder.Ended()[0]
+		if traced := span.Parent().TraceID().String() == traceID; traced != test.traced {
+			t.Errorf("dispatching %v: parent %v", test.data, span.Parent())
+		}
+		if span.Name() != "pusher.dispatch" || !hasAttribute(span.Attributes(), attribute.String("pusher.event", "created")) {
+			t.Errorf("unexpected dispatch span %v %v", span.Name(), span.Attributes())
+		}
+	}
+}
+
+func TestDispatchContextField(t *testing.T) {
+	tracer, recorder := newTracer()
+	tracer.ContextField = "trace"
+	tracer.StartDispatch(pusher.Event{Name: "created", Data: `{"trace":"` + traceparent + `"}`})()
+	if parent := recorder.Ended()[0].Parent(); parent.TraceID().String() != traceID {
+		t.Fatalf("expected the context from the configured field, got %v", parent)
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, expected attribute.KeyValue) bool {
+	for _, kv := range attributes {
+		if kv == expected {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package pusher_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// TestOverflowPolicies dispatches five events to a binding with a buffer of
+// two, whose handler is blocked on the first until the rest are dispatched
+func TestOverflowPolicies(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		policy    pusher.OverflowPolicy
+		delivered []string
+		dropped   []string
+	}{
+		{"block", pusher.OverflowBlock, []string{"1", "2", "3", "4", "5"}, nil},
+		{"drop oldest", pusher.OverflowDropOldest, []string{"1", "4", "5"}, []string{"2", "3"}},
+		{"drop newest", pusher.OverflowDropNewest, []string{"1", "2", "3"}, []string{"4", "5"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			srv := pushertest.NewServer("key", "secret")
+			defer srv.Close()
+			config := srv.ClientConfig()
+			config.BindingBuffer = 2
+			config.OverflowPolicy = test.policy
+			var mutex sync.Mutex
+			var delivered, dropped []string
+			droppedAll := make(chan struct{})
+			config.OnOverflow = func(channel, event string, data interface{}) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				dropped = append(dropped, data.(string))
+				if len(dropped) == len(test.dropped) {
+					close(droppedAll)
+				}
+			}
+			client := pusher.NewWithConfig(config)
+			defer client.Disconnect()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			channel, err := client.SubscribeWithResult(ctx, "x")
+			if err != nil {
+				t.Fatalf("subscribing: %v", err)
+			}
+
+			started, release := make(chan struct{}), make(chan struct{})
+			done := make(chan struct{})
+			channel.Bind("event", func(data interface{}) {
+				mutex.Lock()
+				delivered = append(delivered, data.(string))
+				count := len(delivered)
+				mutex.Unlock()
+				if count == 1 {
+					close(started)
+					<-release
+				}
+				if count == len(test.delivered) {
+					close(done)
+				}
+			})
+
+			srv.Trigger("x", "event", "1")
+			<-started
+			for i := 2; i <= 5; i++ {
+				srv.Trigger("x", "event", fmt.Sprint(i))
+			}
+			if test.dropped != nil {
+				select {
+				case <-droppedAll:
+				case <-time.After(2 * time.Second):
+					t.Fatal("timed out waiting for events to be dropped")
+				}
+			} else {
+				time.Sleep(100 * time.Millisecond)
+			}
+			close(release)
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for the remaining events")
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if !reflect.DeepEqual(delivered, test.delivered) {
+				t.Fatalf("delivered %v, expected %v", delivered, test.delivered)
+			}
+			if !reflect.DeepEqual(dropped, test.dropped) {
+				t.Fatalf("dropped %v, expected %v", dropped, test.dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package pusher
+
+import (
+	s "strings"
+)
+
+// patternBinding is a global binding to the events whose names match a
+// pattern, on channels whose names match another, with either callback
+type patternBinding struct {
+	channel string
+	event   string
+
+	callback      func(channel string, data interface{})
+	eventCallback func(Event)
+}
+
+// patternEvent is delivered to the channel bindings of event name patterns,
+// which need the name of the event as well as its data
+type patternEvent struct {
+	name string
+	data interface{}
+}
+
+// BindChannelPattern binds a callback to an event on every subscribed
+// channel whose name matches pattern, in which * matches any run of
+// characters, e.g. "private-orders-*". The event may also be a pattern. The
+// callback receives the name of the channel with the data, and runs on the
+// client's run loop like global bindings. It returns an ID with which the
+// binding can be removed by UnbindGlobal.
+func (self *Client) BindChannelPattern(pattern, event string, callback func(channel string, data interface{})) BindingID {
+	return self.bindPattern(&patternBinding{channel: pattern, event: event, callback: callback})
+}
+
+// BindEventPattern binds a callback which receives every application event
+// in full whose name matches pattern, e.g. "order.*", on any channel. It
+// returns an ID with which the binding can be removed by UnbindGlobal.
+func (self *Client) BindEventPattern(pattern string, callback func(Event)) BindingID {
+	return self.bindPattern(&patternBinding{channel: "*", event: pattern, eventCallback: callback})
+}
+
+func (self *Client) bindPattern(binding *patternBinding) BindingID {
+	self.bindingsMutex.Lock()
+	defer self.bindingsMutex.Unlock()
+	self.lastBindingID++
+	self.patternBindings[self.lastBindingID] = binding
+	return self.lastBindingID
+}
+
+// BindEventPattern binds a callback to every event on the channel whose name
+// matches pattern, in which * matches any run of characters, e.g. "order.*".
+// The callback receives the name of the event with the data, and runs like
+// those bound with Bind.
+func (self *Channel) BindEventPattern(pattern string, callback func(event string, data interface{})) BindingID {
+	return self.addBinding(pattern, true, false, nil, func(data interface{}) {
+		event := data.(patternEvent)
+		callback(event.name, event.data)
+	}).id
+}
+
+// BindAll binds a callback to every event on the channel, including internal
+// pusher: events, receiving data as BindFunc does.
+func (self *Channel) BindAll(callback func(event string, data string)) BindingID {
+	return self.addBinding("*", true, false, nil, func(data interface{}) {
+		event := data.(patternEvent)
+		callback(event.name, DataString(event.data))
+	}).id
+}
+
+// matchingBindings returns the bindings of a channel to an event, including
+// patterns without a *, followed by those to other patterns matching it, with
+// the owning client's bindingsMutex held
+func matchingBindings(bindings evBind, event string) []*binding {
+	matched := append([]*binding(nil), bindings[event]...)
+	for pattern, patternBindings := range bindings {
+		if pattern == event || !s.Contains(pattern, "*") || !matchPattern(pattern, event) {
+			continue
+		}
+		for _, binding := range patternBindings {
+			if binding.pattern {
+				matched = append(matched, binding)
+			}
+		}
+	}
+	return matched
+}
+
+// triggerPatterns calls the client's pattern bindings which match an event
+func (self *Client) triggerPatterns(channel, event string, data interface{}) {
+	if channel == "" {
+		return
+	}
+
+	self.bindingsMutex.RLock()
+	var matched []*patternBinding
+	for _, binding := range self.patternBindings {
+		if binding.callback != nil && matchPattern(binding.channel, channel) && matchPattern(binding.event, event) {
+			matched = append(matched, binding)
+		}
+	}
+	self.bindingsMutex.RUnlock()
+
+	for _, binding := range matched {
+		binding := binding
+		self.dispatch(channel, event, func() { binding.callback(channel, data) })
+	}
+}
+
+// triggerEventPatterns calls the client's event pattern bindings which match
+// an application event
+func (self *Client) triggerEventPatterns(event Event) {
+	self.bindingsMutex.RLock()
+	var matched []*patternBinding
+	for _, binding := range self.patternBindings {
+		if binding.eventCallback != nil && matchPattern(binding.event, event.Name) {
+			matched = append(matched, binding)
+		}
+	}
+	self.bindingsMutex.RUnlock()
+
+	for _, binding := range matched {
+		binding := binding
+		self.dispatch(event.Channel, event.Name, func() { binding.eventCallback(event) })
+	}
+}
+
+// matchPattern reports whether name matches pattern, in which * matches any
+// run of characters, including none
+func matchPattern(pattern, name string) bool {
+	parts := s.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+
+	if !s.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := s.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return len(name) >= len(last) && s.HasSuffix(name, last)
+}
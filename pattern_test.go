@@ -0,0 +1,36 @@
+package pusher
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*", "anything", true},
+		{"*", "", true},
+		{"", "", true},
+		{"", "a", false},
+		{"a", "", false},
+		{"orders", "orders", true},
+		{"orders", "orders-1", false},
+		{"private-orders-*", "private-orders-42", true},
+		{"private-orders-*", "private-orders-", true},
+		{"private-orders-*", "private-order", false},
+		{"*-created", "order-created", true},
+		{"*-created", "order-created-later", false},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "a-b-b-c", true},
+		{"a*b*c", "acb", false},
+		{"*.*.*", "a.b.c", true},
+		{"*.*.*", "a.b", false},
+		{"a*a", "a", false},
+		{"a*a", "aa", true},
+		{"a*a", "aba", true},
+		{"ab*ba", "aba", false},
+	} {
+		if match := matchPattern(test.pattern, test.name); match != test.match {
+			t.Errorf("matchPattern(%q, %q) = %v, expected %v", test.pattern, test.name, match, test.match)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package pusher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory builds a plugin from its configuration, typically a section of a
+// config file. Factories decode the configuration into their own types.
+type Factory[T any] func(config json.RawMessage) (T, error)
+
+// Registry maps names to plugin factories, so that extensions can be
+// configured by name. Plugins register themselves from init functions, in
+// the same way as database/sql drivers, so that importing a plugin's package
+// is enough to make it available.
+type Registry[T any] struct {
+	kind      string
+	mutex     sync.RWMutex
+	factories map[string]Factory[T]
+}
+
+// NewRegistry creates a registry for one kind of plugin, named in errors
+func NewRegistry[T any](kind string) *Registry[T] {
+	return &Registry[T]{kind: kind, factories: map[string]Factory[T]{}}
+}
+
+// Register makes a plugin available by name. It panics if the name is
+// already taken or factory is nil.
+func (self *Registry[T]) Register(name string, factory Factory[T]) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if factory == nil {
+		panic("pusher: Register " + self.kind + " factory is nil")
+	}
+	if _, dup := self.factories[name]; dup {
+		panic("pusher: Register called twice for " + self.kind + " " + name)
+	}
+	self.factories[name] = factory
+}
+
+// New builds the named plugin from its configuration
+func (self *Registry[T]) New(name string, config json.RawMessage) (T, error) {
+	self.mutex.RLock()
+	factory := self.factories[name]
+	self.mutex.RUnlock()
+	if factory == nil {
+		var zero T
+		return zero, fmt.Errorf("pusher: unknown %v %q (forgotten import?)", self.kind, name)
+	}
+	return factory(config)
+}
+
+// Names returns the sorted names of the registered plugins
+func (self *Registry[T]) Names() []string {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	names := make([]string, 0, len(self.factories))
+	for name := range self.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registries of the core extension points. Sinks are registered in
+// sink.Publishers.
+var (
+	// Transformers rewrite event payloads, see ClientConfig.Scrubber
+	Transformers      = NewRegistry[Scrubber]("transformer")
+	Codecs            = NewRegistry[Codec]("codec")
+	Middlewares       = NewRegistry[Middleware]("middleware")
+	Filters           = NewRegistry[Filter]("filter")
+	MetricsCollectors = NewRegistry[MetricsCollector]("metrics collector")
+)
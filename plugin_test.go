@@ -0,0 +1,63 @@
+package pusher_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// panics returns the value f panics with, or nil
+func panics(f func()) (recovered interface{}) {
+	defer func() { recovered = recover() }()
+	f()
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	registry := pusher.NewRegistry[string]("greeter")
+	registry.Register("hello", func(config json.RawMessage) (string, error) {
+		var c struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return "", err
+		}
+		return "hello " + c.Name, nil
+	})
+	registry.Register("broken", func(config json.RawMessage) (string, error) {
+		return "", errors.New("broken")
+	})
+
+	if greeting, err := registry.New("hello", []byte(`{"name": "world"}`)); err != nil || greeting != "hello world" {
+		t.Fatalf("built %q, %v", greeting, err)
+	}
+	if _, err := registry.New("broken", nil); err == nil || err.Error() != "broken" {
+		t.Fatalf("expected the factory's error, got %v", err)
+	}
+	if _, err := registry.New("goodbye", nil); err == nil || !strings.Contains(err.Error(), `unknown greeter "goodbye"`) {
+		t.Fatalf("expected an unknown plugin error, got %v", err)
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"broken", "hello"}) {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	registry := pusher.NewRegistry[string]("greeter")
+	factory := func(config json.RawMessage) (string, error) { return "", nil }
+	registry.Register("hello", factory)
+
+	if recovered := panics(func() { registry.Register("hello", factory) }); recovered == nil || !strings.Contains(recovered.(string), "twice") {
+		t.Fatalf("expected registering a name twice to panic, got %v", recovered)
+	}
+	if recovered := panics(func() { registry.Register("nil", nil) }); recovered == nil || !strings.Contains(recovered.(string), "nil") {
+		t.Fatalf("expected registering a nil factory to panic, got %v", recovered)
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"hello"}) {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
@@ -0,0 +1,133 @@
+package pusher_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// pollUntil polls client until condition holds
+func pollUntil(t *testing.T, client *pusher.Client, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		if worked, err := client.Poll(); err != nil {
+			t.Fatal(err)
+		} else if !worked {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// idle waits without polling, checking that client stays in state
+func idle(t *testing.T, client *pusher.Client, state pusher.ConnectionState) {
+	t.Helper()
+	time.Sleep(50 * time.Millisecond)
+	if current := client.State(); current != state {
+		t.Fatalf("client moved to %v without being polled", current)
+	}
+}
+
+func TestManualPoll(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.ManualPoll = true
+	config.MaxReconnectDelay = time.Millisecond
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	idle(t, client, pusher.StateInitialized)
+	pollUntil(t, client, func() bool { return client.State() == pusher.StateConnected }, "did not connect")
+
+	received := make(chan interface{}, 1)
+	client.Subscribe("x").Bind("event", func(data interface{}) { received <- data })
+	time.Sleep(50 * time.Millisecond)
+	if count := srv.Subscribers("x"); count != 0 {
+		t.Fatal("subscribed without being polled")
+	}
+	pollUntil(t, client, func() bool { return srv.Subscribers("x") == 1 }, "did not subscribe")
+	srv.Trigger("x", "event", "polled")
+	var data interface{}
+	pollUntil(t, client, func() bool {
+		select {
+		case data = <-received:
+			return true
+		default:
+			return false
+		}
+	}, "the event was not delivered")
+	if data != "polled" {
+		t.Fatalf("received %v", data)
+	}
+
+	// Once the pending work is done, Poll finds none and returns at once
+	for i := 0; ; i++ {
+		worked, _ := client.Poll()
+		if !worked {
+			break
+		} else if i == 100 {
+			t.Fatal("Poll kept finding work on an idle client")
+		}
+	}
+	start := time.Now()
+	if worked, err := client.Poll(); worked || err != nil || time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("Poll on an idle client returned %v, %v after %v", worked, err, time.Since(start))
+	}
+}
+
+// TestManualPollReconnect drops the connection of a client which nobody polls,
+// which must reconnect, and resubscribe, only once polled again
+func TestManualPollReconnect(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.ManualPoll = true
+	config.MaxReconnectDelay = time.Millisecond
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	client.Subscribe("x")
+	pollUntil(t, client, func() bool { return srv.Subscribers("x") == 1 }, "did not subscribe")
+
+	srv.DisconnectAll(4100, "over capacity")
+	idle(t, client, pusher.StateConnected)
+	if count := srv.Subscribers("x"); count != 0 {
+		t.Fatalf("reconnected without being polled, with %v subscribers", count)
+	}
+	pollUntil(t, client, func() bool { return client.State() != pusher.StateConnected }, "did not notice the disconnection")
+	// The reconnection is scheduled after a backoff, on a timer which only
+	// fires when polled
+	idle(t, client, client.State())
+	pollUntil(t, client, func() bool { return srv.Subscribers("x") == 1 }, "did not reconnect and resubscribe")
+
+	client.Disconnect()
+	for i := 0; ; i++ {
+		running, err := client.RunOnce()
+		if err != nil {
+			t.Fatal(err)
+		} else if !running {
+			break
+		} else if i == 100 {
+			t.Fatal("RunOnce kept running after Disconnect")
+		}
+	}
+	if worked, err := client.Poll(); worked || err != nil {
+		t.Fatalf("Poll after Disconnect returned %v, %v", worked, err)
+	}
+}
+
+func TestManualPollRequired(t *testing.T) {
+	client := pusher.NewWithConfig(pusher.ClientConfig{DisableAutoConnect: true})
+	defer client.Disconnect()
+	if _, err := client.RunOnce(); err != pusher.ErrNotManualPoll {
+		t.Errorf("RunOnce returned %v, expected ErrNotManualPoll", err)
+	}
+	if _, err := client.Poll(); err != pusher.ErrNotManualPoll {
+		t.Errorf("Poll returned %v, expected ErrNotManualPoll", err)
+	}
+}
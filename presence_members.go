@@ -1,7 +1,10 @@
 package pusher
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"sort"
 )
 
 type rawMembers struct {
@@ -9,9 +12,9 @@ type rawMembers struct {
 }
 
 type rawPresence struct {
-	Count int                          `json:"count"`
-	Ids   []string                     `json:"ids"`
-	Hash  map[string]map[string]string `json:"hash"`
+	Count int                        `json:"count"`
+	Ids   []string                   `json:"ids"`
+	Hash  map[string]json.RawMessage `json:"hash"`
 }
 
 type Members struct {
@@ -21,8 +24,59 @@ type Members struct {
 }
 
 type Member struct {
-	UserId   string            `json:"user_id"`
+	UserId string `json:"user_id"`
+	// UserInfo holds the string fields of user_info, see DecodeInto for
+	// other values
 	UserInfo map[string]string `json:"user_info,omitempty"`
+
+	// user_info as received from the server
+	rawInfo json.RawMessage
+}
+
+func newMember(id string, info json.RawMessage) Member {
+	member := Member{UserId: id}
+	if len(info) == 0 || string(info) == "null" {
+		return member
+	}
+	member.rawInfo = info
+
+	var fields map[string]interface{}
+	if json.Unmarshal(info, &fields) == nil {
+		for key, value := range fields {
+			if str, ok := value.(string); ok {
+				if member.UserInfo == nil {
+					member.UserInfo = map[string]string{}
+				}
+				member.UserInfo[key] = str
+			}
+		}
+	}
+	return member
+}
+
+func (self *Member) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		UserId   string          `json:"user_id"`
+		UserInfo json.RawMessage `json:"user_info"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*self = newMember(raw.UserId, raw.UserInfo)
+	return nil
+}
+
+// DecodeInto decodes the member's user_info into v, e.g. a pointer to a
+// struct, so that nested and non-string values can be used
+func (self Member) DecodeInto(v interface{}) error {
+	info := self.rawInfo
+	if info == nil {
+		var err error
+		if info, err = json.Marshal(self.UserInfo); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(info, v)
 }
 
 func unmarshalledMember(data string) (member *Member, err error) {
@@ -40,7 +94,7 @@ func unmarshalledMembers(data string, myID string) (members *Members, err error)
 	var me Member
 
 	for _, id := range rawData.Presence.Ids {
-		_member := Member{UserId: id, UserInfo: rawData.Presence.Hash[id]}
+		_member := newMember(id, rawData.Presence.Hash[id])
 		_members = append(_members, _member)
 
 		if id == myID {
@@ -57,3 +111,150 @@ func unmarshalledMembers(data string, myID string) (members *Members, err error)
 
 	return
 }
+
+// setPresenceID records the user ID with which a presence channel is
+// subscribed, so that its member is known as Me
+func (self *Client) setPresenceID(channel, userID string) {
+	if self.loop.presenceIDs == nil {
+		self.loop.presenceIDs = map[string]string{}
+	}
+	self.loop.presenceIDs[channel] = userID
+}
+
+// AwaitMembers waits until the initial member list of a presence channel has
+// been received, and returns the current members ordered by user ID, or the
+// error decoding the list. After the channel is resubscribed, e.g. on
+// reconnection, it waits for the new member list.
+func (self *Channel) AwaitMembers(ctx context.Context) ([]Member, error) {
+	if !self.isPresence() {
+		return nil, errors.New("pusher: " + self.Name + " is not a presence channel")
+	}
+
+	self.mutex.Lock()
+	synced := self.membersSynced
+	self.mutex.Unlock()
+
+	select {
+	case <-synced:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	self.mutex.Lock()
+	err := self.membersErr
+	self.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return self.Members(), nil
+}
+
+// Members returns the current members of a presence channel ordered by user
+// ID, or nil until the member list has been received since the channel was
+// last subscribed
+func (self *Channel) Members() []Member {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.members == nil {
+		return nil
+	}
+	members := make([]Member, 0, len(self.members))
+	for _, member := range self.members {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserId < members[j].UserId
+	})
+	return members
+}
+
+// Me returns this client's own member of a presence channel, and whether it
+// is known yet
+func (self *Channel) Me() (Member, bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	member, ok := self.members[self.me]
+	return member, ok && self.me != ""
+}
+
+// MemberCount returns the number of members of a presence channel
+func (self *Channel) MemberCount() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return len(self.members)
+}
+
+func (self *Channel) setMembers(members *Members) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.members = make(map[string]Member, len(members.Members))
+	for _, member := range members.Members {
+		self.members[member.UserId] = member
+	}
+	self.me = members.Me.UserId
+	self.membersErr = nil
+
+	select {
+	case <-self.membersSynced:
+	default:
+		close(self.membersSynced)
+	}
+}
+
+// membersFailed resolves AwaitMembers with the error decoding the member list
+func (self *Channel) membersFailed(err error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.membersErr = err
+	select {
+	case <-self.membersSynced:
+	default:
+		close(self.membersSynced)
+	}
+}
+
+// copyMembers copies the member list of another facade's channel
+func (self *Channel) copyMembers(other *Channel) {
+	other.mutex.Lock()
+	members := make(map[string]Member, len(other.members))
+	for id, member := range other.members {
+		members[id] = member
+	}
+	membersErr := other.membersErr
+	synced := other.members != nil || membersErr != nil
+	me := other.me
+	other.mutex.Unlock()
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.members = members
+	self.me = me
+	self.membersErr = membersErr
+	if synced {
+		select {
+		case <-self.membersSynced:
+		default:
+			close(self.membersSynced)
+		}
+	}
+}
+
+func (self *Channel) addMember(member Member) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.members != nil {
+		self.members[member.UserId] = member
+	}
+}
+
+func (self *Channel) removeMember(member Member) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	delete(self.members, member.UserId)
+}
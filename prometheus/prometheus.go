@@ -0,0 +1,159 @@
+// Package prometheus exposes a pusher client's metrics as a Prometheus
+// collector. Importing the package registers the "prometheus" metrics
+// collector, which registers itself on the default Prometheus registry.
+package prometheus
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Default namespace of metric names
+const DefaultNamespace = "pusher"
+
+func init() {
+	pusher.MetricsCollectors.Register("prometheus", func(config json.RawMessage) (pusher.MetricsCollector, error) {
+		var c struct {
+			Namespace *string `json:"namespace"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		namespace := DefaultNamespace
+		if c.Namespace != nil {
+			namespace = *c.Namespace
+		}
+		collector := New(namespace)
+		if err := prom.Register(collector); err != nil {
+			return nil, err
+		}
+		return collector, nil
+	})
+}
+
+// Collector is a pusher.MetricsCollector and a prometheus.Collector, to be
+// set as ClientConfig.Metrics and registered on a Prometheus registry. Event
+// metrics are labelled by channel, so beware of cardinality with many
+// distinct channels.
+type Collector struct {
+	connected      prom.Gauge
+	connections    prom.Counter
+	reconnects     prom.Counter
+	received       *prom.CounterVec
+	receivedBytes  *prom.CounterVec
+	sent           *prom.CounterVec
+	sentBytes      *prom.CounterVec
+	subscriptions  *prom.CounterVec
+	handlerLatency *prom.HistogramVec
+	pingRTT        prom.Histogram
+}
+
+// New returns a collector whose metric names start with namespace
+func New(namespace string) *Collector {
+	return &Collector{
+		connected: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace, Name: "connected",
+			Help: "Whether the connection is established.",
+		}),
+		connections: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Name: "connections_total",
+			Help: "Connections established.",
+		}),
+		reconnects: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Name: "reconnects_total",
+			Help: "Reconnections scheduled.",
+		}),
+		received: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "events_received_total",
+			Help: "Events received.",
+		}, []string{"channel"}),
+		receivedBytes: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "received_bytes_total",
+			Help: "Bytes of event data received.",
+		}, []string{"channel"}),
+		sent: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "events_sent_total",
+			Help: "Client events sent.",
+		}, []string{"channel"}),
+		sentBytes: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "sent_bytes_total",
+			Help: "Bytes of client events sent.",
+		}, []string{"channel"}),
+		subscriptions: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Name: "subscriptions_total",
+			Help: "Subscription attempts, by result.",
+		}, []string{"channel", "result"}),
+		handlerLatency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace, Name: "handler_duration_seconds",
+			Help:    "Time taken by bindings to handle events.",
+			Buckets: prom.DefBuckets,
+		}, []string{"channel"}),
+		pingRTT: prom.NewHistogram(prom.HistogramOpts{
+			Namespace: namespace, Name: "ping_rtt_seconds",
+			Help:    "Round trip time of pings on idle connections.",
+			Buckets: prom.DefBuckets,
+		}),
+	}
+}
+
+func (self *Collector) collectors() []prom.Collector {
+	return []prom.Collector{
+		self.connected, self.connections, self.reconnects,
+		self.received, self.receivedBytes, self.sent, self.sentBytes,
+		self.subscriptions, self.handlerLatency, self.pingRTT,
+	}
+}
+
+func (self *Collector) Describe(ch chan<- *prom.Desc) {
+	for _, collector := range self.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+func (self *Collector) Collect(ch chan<- prom.Metric) {
+	for _, collector := range self.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+func (self *Collector) Connected() {
+	self.connected.Set(1)
+	self.connections.Inc()
+}
+
+func (self *Collector) Disconnected() {
+	self.connected.Set(0)
+}
+
+func (self *Collector) MessageReceived(channel, event string, size int) {
+	self.received.WithLabelValues(channel).Inc()
+	self.receivedBytes.WithLabelValues(channel).Add(float64(size))
+}
+
+func (self *Collector) MessageSent(channel, event string, size int) {
+	self.sent.WithLabelValues(channel).Inc()
+	self.sentBytes.WithLabelValues(channel).Add(float64(size))
+}
+
+func (self *Collector) SubscriptionSucceeded(channel string) {
+	self.subscriptions.WithLabelValues(channel, "succeeded").Inc()
+}
+
+func (self *Collector) SubscriptionFailed(channel string, err error) {
+	self.subscriptions.WithLabelValues(channel, "failed").Inc()
+}
+
+func (self *Collector) Reconnecting(attempt int, delay time.Duration) {
+	self.reconnects.Inc()
+}
+
+func (self *Collector) HandlerLatency(channel, event string, duration time.Duration) {
+	self.handlerLatency.WithLabelValues(channel).Observe(duration.Seconds())
+}
+
+func (self *Collector) PingRTT(rtt time.Duration) {
+	self.pingRTT.Observe(rtt.Seconds())
+}
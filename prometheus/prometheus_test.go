@@ -0,0 +1,90 @@
+package prometheus_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	collector := prometheus.New("test")
+	registry := prom.NewPedanticRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	collector.Connected()
+	collector.MessageReceived("orders", "created", 10)
+	collector.MessageReceived("orders", "updated", 5)
+	collector.MessageSent("private-chat", "client-typing", 2)
+	collector.SubscriptionSucceeded("orders")
+	collector.SubscriptionFailed("private-chat", nil)
+	collector.Reconnecting(1, time.Second)
+	collector.HandlerLatency("orders", "created", 20*time.Millisecond)
+	collector.PingRTT(30 * time.Millisecond)
+
+	expected := `
+# HELP test_connected Whether the connection is established.
+# TYPE test_connected gauge
+test_connected 1
+# HELP test_connections_total Connections established.
+# TYPE test_connections_total counter
+test_connections_total 1
+# HELP test_events_received_total Events received.
+# TYPE test_events_received_total counter
+test_events_received_total{channel="orders"} 2
+# HELP test_events_sent_total Client events sent.
+# TYPE test_events_sent_total counter
+test_events_sent_total{channel="private-chat"} 1
+# HELP test_received_bytes_total Bytes of event data received.
+# TYPE test_received_bytes_total counter
+test_received_bytes_total{channel="orders"} 15
+# HELP test_reconnects_total Reconnections scheduled.
+# TYPE test_reconnects_total counter
+test_reconnects_total 1
+# HELP test_subscriptions_total Subscription attempts, by result.
+# TYPE test_subscriptions_total counter
+test_subscriptions_total{channel="orders",result="succeeded"} 1
+test_subscriptions_total{channel="private-chat",result="failed"} 1
+`
+	names := []string{"test_connected", "test_connections_total", "test_events_received_total", "test_events_sent_total",
+		"test_received_bytes_total", "test_reconnects_total", "test_subscriptions_total"}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), names...); err != nil {
+		t.Fatal(err)
+	}
+	if count := testutil.CollectAndCount(collector, "test_handler_duration_seconds", "test_ping_rtt_seconds"); count != 2 {
+		t.Fatalf("expected a handler latency and ping RTT histogram, got %v", count)
+	}
+
+	collector.Disconnected()
+	expected = `
+# HELP test_connected Whether the connection is established.
+# TYPE test_connected gauge
+test_connected 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_connected"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	collector, err := pusher.MetricsCollectors.New("prometheus", []byte(`{"namespace":"registered"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prom.Unregister(collector.(*prometheus.Collector))
+	collector.Connected()
+	if count, err := testutil.GatherAndCount(prom.DefaultGatherer, "registered_connected"); err != nil || count != 1 {
+		t.Fatalf("expected the collector on the default registry, got %v, %v", count, err)
+	}
+
+	// Registering the same names again fails
+	if _, err := pusher.MetricsCollectors.New("prometheus", []byte(`{"namespace":"registered"}`)); err == nil {
+		t.Fatal("expected registering a duplicate collector to fail")
+	}
+}
@@ -0,0 +1,166 @@
+// Package pushertest provides utilities for testing code built on the pusher
+// client.
+package pushertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// UpdateGoldenEnv names the environment variable which, when set to 1, makes
+// AssertGolden rewrite golden files instead of comparing against them
+const UpdateGoldenEnv = "PUSHERTEST_UPDATE_GOLDEN"
+
+// RecordedEvent is an event as dispatched to handlers. Data holds the
+// payload as JSON, so that golden files remain readable.
+type RecordedEvent struct {
+	Channel string          `json:"channel"`
+	Event   string          `json:"event"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Recorder records every event dispatched by a client, in dispatch order
+type Recorder struct {
+	mutex  sync.Mutex
+	events []RecordedEvent
+	notify chan struct{}
+}
+
+// NewRecorder creates a recorder bound globally to the client
+func NewRecorder(client *pusher.Client) *Recorder {
+	recorder := &Recorder{notify: make(chan struct{}, 1)}
+	client.BindGlobal(recorder.record)
+	return recorder
+}
+
+func (self *Recorder) record(channel, event string, data interface{}) {
+	self.mutex.Lock()
+	self.events = append(self.events, RecordedEvent{
+		Channel: channel,
+		Event:   event,
+		Data:    rawData(data),
+	})
+	self.mutex.Unlock()
+
+	select {
+	case self.notify <- struct{}{}:
+	default:
+	}
+}
+
+// rawData encodes event data as JSON, embedding string payloads which are
+// themselves JSON as is
+func rawData(data interface{}) json.RawMessage {
+	if str, ok := data.(string); ok && json.Valid([]byte(str)) {
+		return json.RawMessage(str)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		encoded, _ = json.Marshal(err.Error())
+	}
+	return encoded
+}
+
+// Events returns the events recorded so far
+func (self *Recorder) Events() []RecordedEvent {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return append([]RecordedEvent{}, self.events...)
+}
+
+// Reset forgets all recorded events
+func (self *Recorder) Reset() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.events = nil
+}
+
+// WaitFor waits until at least n events have been recorded, failing the test
+// if that does not happen within the timeout
+func (self *Recorder) WaitFor(t testing.TB, n int, timeout time.Duration) []RecordedEvent {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		if events := self.Events(); len(events) >= n {
+			return events
+		}
+		select {
+		case <-self.notify:
+		case <-deadline:
+			t.Fatalf("pushertest: timed out waiting for %v events, got %v", n, len(self.Events()))
+			return nil
+		}
+	}
+}
+
+// Normalizer rewrites volatile parts of a serialized session, so that golden
+// files remain stable between runs
+type Normalizer func(session []byte) []byte
+
+var (
+	socketIDPattern  = regexp.MustCompile(`("socket_id"\s*:\s*")\d+\.\d+"`)
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+)
+
+// NormalizeSocketIDs replaces the values of socket_id fields
+func NormalizeSocketIDs(session []byte) []byte {
+	return socketIDPattern.ReplaceAll(session, []byte(`${1}<socket_id>"`))
+}
+
+// NormalizeTimestamps replaces RFC 3339 timestamps
+func NormalizeTimestamps(session []byte) []byte {
+	return timestampPattern.ReplaceAll(session, []byte("<timestamp>"))
+}
+
+// DefaultNormalizers are applied by AssertGolden when no normalizers are given
+var DefaultNormalizers = []Normalizer{NormalizeTimestamps, NormalizeSocketIDs}
+
+// AssertGolden compares events against the JSON golden file at path, after
+// applying the normalizers. Set PUSHERTEST_UPDATE_GOLDEN=1 to (re)write the
+// golden file from the events instead.
+func AssertGolden(t testing.TB, path string, events []RecordedEvent, normalizers ...Normalizer) {
+	t.Helper()
+
+	if len(normalizers) == 0 {
+		normalizers = DefaultNormalizers
+	}
+	if events == nil {
+		events = []RecordedEvent{}
+	}
+
+	actual, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		t.Fatalf("pushertest: encoding events: %v", err)
+	}
+	for _, normalize := range normalizers {
+		actual = normalize(actual)
+	}
+	actual = append(actual, '\n')
+
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("pushertest: %v", err)
+		}
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("pushertest: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("pushertest: reading golden file (set %v=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("pushertest: events do not match %v\n--- expected\n%s\n--- actual\n%s", path, expected, actual)
+	}
+}
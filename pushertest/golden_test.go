@@ -0,0 +1,109 @@
+package pushertest_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// recordingTB records the failures reported to it, ending the goroutine on
+// fatal ones as testing.T does
+type recordingTB struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (self *recordingTB) Errorf(format string, args ...interface{}) {
+	self.failures = append(self.failures, fmt.Sprintf(format, args...))
+}
+
+func (self *recordingTB) Fatalf(format string, args ...interface{}) {
+	self.Errorf(format, args...)
+	self.fatal = true
+	runtime.Goexit()
+}
+
+// assertGolden runs AssertGolden against a recordingTB
+func assertGolden(t *testing.T, path string, events []pushertest.RecordedEvent) *recordingTB {
+	tb := &recordingTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pushertest.AssertGolden(tb, path, events)
+	}()
+	<-done
+	return tb
+}
+
+func TestRecorder(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := newClient(t, srv.ClientConfig())
+	recorder := pushertest.NewRecorder(client)
+	subscribe(t, client, "orders")
+	recorder.Reset()
+
+	srv.Trigger("orders", "created", map[string]int{"id": 1})
+	srv.Trigger("orders", "noted", "plain text")
+	events := recorder.WaitFor(t, 2, timeout)
+	if len(events) != 2 || events[0].Channel != "orders" || events[0].Event != "created" || string(events[0].Data) != `{"id":1}` ||
+		events[1].Event != "noted" || string(events[1].Data) != `"plain text"` {
+		t.Fatalf("recorded %+v", events)
+	}
+	events[0].Event = "modified"
+	if recorder.Events()[0].Event != "created" {
+		t.Fatal("Events returned the recorder's own slice")
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "session.golden")
+	events := []pushertest.RecordedEvent{{Channel: "orders", Event: "created", Data: []byte(`{"at":"2024-01-02T15:04:05Z"}`)}}
+
+	if tb := assertGolden(t, path, events); !tb.fatal || !strings.Contains(tb.failures[0], pushertest.UpdateGoldenEnv) {
+		t.Fatalf("expected a missing golden file to fail with how to create it, got %v", tb.failures)
+	}
+
+	t.Setenv(pushertest.UpdateGoldenEnv, "1")
+	if tb := assertGolden(t, path, events); len(tb.failures) != 0 {
+		t.Fatalf("updating failed with %v", tb.failures)
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(golden), `"at": "<timestamp>"`) {
+		t.Fatalf("expected the golden file to be normalized, got %s", golden)
+	}
+
+	t.Setenv(pushertest.UpdateGoldenEnv, "")
+	events[0].Data = []byte(`{"at":"2025-06-07T08:09:10.5+02:00"}`)
+	if tb := assertGolden(t, path, events); len(tb.failures) != 0 {
+		t.Fatalf("expected events differing only in timestamps to match, got %v", tb.failures)
+	}
+	events[0].Event = "deleted"
+	if tb := assertGolden(t, path, events); tb.fatal || len(tb.failures) != 1 || !strings.Contains(tb.failures[0], `"event": "deleted"`) {
+		t.Fatalf("expected a mismatch showing the actual events, got %v", tb.failures)
+	}
+}
+
+func TestNormalizers(t *testing.T) {
+	for _, test := range []struct {
+		normalize       pushertest.Normalizer
+		session, normal string
+	}{
+		{pushertest.NormalizeSocketIDs, `{"socket_id": "123.456", "id": "123.456"}`, `{"socket_id": "<socket_id>", "id": "123.456"}`},
+		{pushertest.NormalizeSocketIDs, `{"socket_id":"1.2"}`, `{"socket_id":"<socket_id>"}`},
+		{pushertest.NormalizeTimestamps, `["2024-01-02T15:04:05Z", "2024-01-02T15:04:05.123-05:00", "2024-01-02"]`, `["<timestamp>", "<timestamp>", "2024-01-02"]`},
+	} {
+		if normal := string(test.normalize([]byte(test.session))); normal != test.normal {
+			t.Errorf("%v normalized to %v, expected %v", test.session, normal, test.normal)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package pushertest
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// Server is an in-process fake Pusher server speaking the WebSocket
+// protocol, so that code built on the client can be tested without the real
+// service. It handles the handshake, subscriptions with authorization,
+// presence membership, ping/pong and client events, and injects events with
+// Trigger.
+type Server struct {
+	Key string
+	// Secret verifies the auth signatures of private and presence
+	// subscriptions. When empty, every subscription is accepted.
+	Secret string
+	// OnClientEvent is called with every client event received, before it
+	// is relayed to the other subscribers of its channel
+	OnClientEvent func(socketID string, event pusher.Event)
+
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+
+	mutex      sync.Mutex
+	conns      map[*serverConn]struct{}
+	lastSocket int
+	closed     bool
+
+	// Tracks the goroutines serving connections, which httptest stops
+	// tracking once they are upgraded
+	serving sync.WaitGroup
+}
+
+type serverConn struct {
+	ws       *websocket.Conn
+	socketID string
+	writes   sync.Mutex
+
+	// Guarded by the server's mutex
+	channels map[string]*pusher.Member
+}
+
+// NewServer starts a server, which must be closed with Close
+func NewServer(key, secret string) *Server {
+	server := &Server{Key: key, Secret: secret, conns: map[*serverConn]struct{}{}}
+	server.server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+// Close disconnects every client and stops the server
+func (self *Server) Close() {
+	self.mutex.Lock()
+	self.closed = true
+	self.mutex.Unlock()
+
+	self.DisconnectAll(websocket.CloseGoingAway, "server closed")
+	self.server.Close()
+	self.serving.Wait()
+}
+
+// ClientConfig returns the config for a client connecting to this server
+func (self *Server) ClientConfig() pusher.ClientConfig {
+	u, _ := url.Parse(self.server.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	return pusher.ClientConfig{
+		Scheme: "ws",
+		Host:   host,
+		Port:   port,
+		Key:    self.Key,
+		Secret: self.Secret,
+	}
+}
+
+// Trigger sends an event to every connection subscribed to the channel. Data
+// which is not a string is sent as JSON.
+func (self *Server) Trigger(channel, event string, data interface{}) error {
+	payload, err := encodeData(data)
+	if err != nil {
+		return err
+	}
+	self.broadcast(channel, nil, pusher.Event{Name: event, Channel: channel, Data: payload})
+	return nil
+}
+
+// Subscribers returns the number of connections subscribed to the channel
+func (self *Server) Subscribers(channel string) int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	count := 0
+	for conn := range self.conns {
+		if _, ok := conn.channels[channel]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+// Members returns the members of a presence channel, ordered by user ID
+func (self *Server) Members(channel string) []pusher.Member {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.members(channel)
+}
+
+// DisconnectAll closes every connection with the given close code, e.g.
+// 4200 to make clients reconnect straight away
+func (self *Server) DisconnectAll(code int, reason string) {
+	self.mutex.Lock()
+	conns := make([]*serverConn, 0, len(self.conns))
+	for conn := range self.conns {
+		conns = append(conns, conn)
+	}
+	self.mutex.Unlock()
+
+	for _, conn := range conns {
+		conn.writes.Lock()
+		conn.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+		conn.writes.Unlock()
+		conn.ws.Close()
+	}
+}
+
+func (self *Server) serve(w http.ResponseWriter, r *http.Request) {
+	ws, err := self.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	self.mutex.Lock()
+	if self.closed {
+		self.mutex.Unlock()
+		ws.Close()
+		return
+	}
+	self.serving.Add(1)
+	defer self.serving.Done()
+	self.lastSocket++
+	conn := &serverConn{
+		ws:       ws,
+		socketID: fmt.Sprintf("%d.%d", self.lastSocket, self.lastSocket),
+		channels: map[string]*pusher.Member{},
+	}
+	self.conns[conn] = struct{}{}
+	self.mutex.Unlock()
+
+	defer func() {
+		self.mutex.Lock()
+		delete(self.conns, conn)
+		channels := conn.channels
+		self.mutex.Unlock()
+		for channel, member := range channels {
+			self.left(channel, member)
+		}
+		ws.Close()
+	}()
+
+	established, _ := json.Marshal(map[string]interface{}{
+		"socket_id":        conn.socketID,
+		"activity_timeout": 120,
+	})
+	conn.send(pusher.Event{Name: "pusher:connection_established", Data: string(established)})
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var event struct {
+			Name    string          `json:"event"`
+			Channel string          `json:"channel"`
+			Data    json.RawMessage `json:"data"`
+		}
+		if json.Unmarshal(msg, &event) != nil {
+			continue
+		}
+
+		switch {
+		case event.Name == "pusher:subscribe":
+			var data struct {
+				Channel     string `json:"channel"`
+				Auth        string `json:"auth"`
+				ChannelData string `json:"channel_data"`
+			}
+			json.Unmarshal(event.Data, &data)
+			self.subscribe(conn, data.Channel, data.Auth, data.ChannelData)
+		case event.Name == "pusher:unsubscribe":
+			var data struct {
+				Channel string `json:"channel"`
+			}
+			json.Unmarshal(event.Data, &data)
+			self.mutex.Lock()
+			member, ok := conn.channels[data.Channel]
+			delete(conn.channels, data.Channel)
+			self.mutex.Unlock()
+			if ok {
+				self.left(data.Channel, member)
+			}
+		case event.Name == "pusher:ping":
+			conn.send(pusher.Event{Name: "pusher:pong", Data: "{}"})
+		case strings.HasPrefix(event.Name, "client-"):
+			self.clientEvent(conn, event.Channel, event.Name, event.Data)
+		}
+	}
+}
+
+func (self *Server) subscribe(conn *serverConn, channel, auth, channelData string) {
+	private := strings.HasPrefix(channel, "private-")
+	presence := strings.HasPrefix(channel, "presence-")
+
+	if (private || presence) && self.Secret != "" {
+		var data *string
+		if presence {
+			data = &channelData
+		}
+		expected := pusher.GenerateAuth(self.Key, self.Secret, conn.socketID, channel, data)
+		if !hmac.Equal([]byte(auth), []byte(expected)) {
+			data, _ := json.Marshal(map[string]interface{}{
+				"type":   "AuthError",
+				"error":  "Invalid signature for " + channel,
+				"status": 401,
+			})
+			conn.send(pusher.Event{Name: "pusher:subscription_error", Channel: channel, Data: string(data)})
+			return
+		}
+	}
+
+	var member *pusher.Member
+	if presence {
+		member = &pusher.Member{}
+		if err := json.Unmarshal([]byte(channelData), member); err != nil || member.UserId == "" {
+			conn.send(pusher.Event{Name: "pusher:subscription_error", Channel: channel,
+				Data: `{"type":"InvalidChannelData","error":"Invalid channel_data","status":400}`})
+			return
+		}
+	}
+
+	self.mutex.Lock()
+	joined := member != nil && !self.hasMember(channel, member.UserId)
+	conn.channels[channel] = member
+	var data string
+	if presence {
+		data = presenceData(self.members(channel))
+	} else {
+		data = "{}"
+	}
+	self.mutex.Unlock()
+
+	conn.send(pusher.Event{Name: "pusher_internal:subscription_succeeded", Channel: channel, Data: data})
+	if joined {
+		added, _ := json.Marshal(rawMember(*member))
+		self.broadcast(channel, conn, pusher.Event{Name: "pusher_internal:member_added", Channel: channel, Data: string(added)})
+	}
+}
+
+// left removes a member from a presence channel once its last connection has
+// unsubscribed
+func (self *Server) left(channel string, member *pusher.Member) {
+	if member == nil {
+		return
+	}
+	self.mutex.Lock()
+	stillPresent := self.hasMember(channel, member.UserId)
+	self.mutex.Unlock()
+
+	if !stillPresent {
+		removed, _ := json.Marshal(map[string]string{"user_id": member.UserId})
+		self.broadcast(channel, nil, pusher.Event{Name: "pusher_internal:member_removed", Channel: channel, Data: string(removed)})
+	}
+}
+
+func (self *Server) clientEvent(conn *serverConn, channel, name string, data json.RawMessage) {
+	if !strings.HasPrefix(channel, "private-") && !strings.HasPrefix(channel, "presence-") {
+		return
+	}
+	self.mutex.Lock()
+	member, subscribed := conn.channels[channel]
+	self.mutex.Unlock()
+	if !subscribed {
+		return
+	}
+
+	event := pusher.Event{Name: name, Channel: channel}
+	if err := json.Unmarshal(data, &event.Data); err != nil {
+		event.Data = string(data)
+	}
+	if member != nil {
+		event.UserId = member.UserId
+	}
+	if self.OnClientEvent != nil {
+		self.OnClientEvent(conn.socketID, event)
+	}
+	self.broadcast(channel, conn, event)
+}
+
+// broadcast sends an event to the subscribers of its channel, except one
+func (self *Server) broadcast(channel string, except *serverConn, event pusher.Event) {
+	self.mutex.Lock()
+	var conns []*serverConn
+	for conn := range self.conns {
+		if _, ok := conn.channels[channel]; ok && conn != except {
+			conns = append(conns, conn)
+		}
+	}
+	self.mutex.Unlock()
+
+	for _, conn := range conns {
+		conn.send(event)
+	}
+}
+
+// hasMember reports whether any connection is subscribed to the presence
+// channel as the user, with the mutex held
+func (self *Server) hasMember(channel, userID string) bool {
+	for conn := range self.conns {
+		if member := conn.channels[channel]; member != nil && member.UserId == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// members returns the members of a presence channel, with the mutex held
+func (self *Server) members(channel string) []pusher.Member {
+	byID := map[string]pusher.Member{}
+	for conn := range self.conns {
+		if member := conn.channels[channel]; member != nil {
+			byID[member.UserId] = *member
+		}
+	}
+	members := make([]pusher.Member, 0, len(byID))
+	for _, member := range byID {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserId < members[j].UserId
+	})
+	return members
+}
+
+func (self *serverConn) send(event pusher.Event) {
+	msg, _ := json.Marshal(event)
+	self.writes.Lock()
+	defer self.writes.Unlock()
+	self.ws.WriteMessage(websocket.TextMessage, msg)
+}
+
+// rawMember returns a member with its user_info as received
+func rawMember(member pusher.Member) map[string]interface{} {
+	var info json.RawMessage
+	if member.DecodeInto(&info) != nil || string(info) == "null" {
+		info = nil
+	}
+	raw := map[string]interface{}{"user_id": member.UserId}
+	if info != nil {
+		raw["user_info"] = info
+	}
+	return raw
+}
+
+func presenceData(members []pusher.Member) string {
+	ids := make([]string, 0, len(members))
+	hash := map[string]interface{}{}
+	for _, member := range members {
+		ids = append(ids, member.UserId)
+		hash[member.UserId] = rawMember(member)["user_info"]
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"presence": map[string]interface{}{
+			"count": len(members),
+			"ids":   ids,
+			"hash":  hash,
+		},
+	})
+	return string(data)
+}
+
+func encodeData(data interface{}) (string, error) {
+	if str, ok := data.(string); ok {
+		return str, nil
+	}
+	encoded, err := json.Marshal(data)
+	return string(encoded), err
+}
@@ -0,0 +1,120 @@
+package pushertest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+const timeout = 2 * time.Second
+
+// eventually fails the test unless condition becomes true within timeout
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newClient(t *testing.T, config pusher.ClientConfig) *pusher.Client {
+	client := pusher.NewWithConfig(config)
+	t.Cleanup(client.Disconnect)
+	return client
+}
+
+func subscribe(t *testing.T, client *pusher.Client, channel string) *pusher.Channel {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ch, err := client.SubscribeWithResult(ctx, channel)
+	if err != nil {
+		t.Fatalf("subscribing to %v: %v", channel, err)
+	}
+	return ch
+}
+
+func TestHandshake(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	client := newClient(t, srv.ClientConfig())
+
+	eventually(t, client.IsConnected, "client did not connect")
+	subscribe(t, client, "public")
+	if count := srv.Subscribers("public"); count != 1 {
+		t.Fatalf("expected one subscriber, got %v", count)
+	}
+
+	received := make(chan interface{}, 1)
+	client.FindChannel("public").Bind("event", func(data interface{}) { received <- data })
+	srv.Trigger("public", "event", map[string]string{"hello": "world"})
+	select {
+	case data := <-received:
+		if data != `{"hello":"world"}` {
+			t.Fatalf("unexpected event data %v", data)
+		}
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for the event")
+	}
+}
+
+func TestAuthRejection(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.AuthFunc = func(socketID, channel string) (string, error) {
+		return pusher.GenerateAuth(config.Key, "wrong", socketID, channel, nil), nil
+	}
+	client := newClient(t, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := client.SubscribeWithResult(ctx, "private-x")
+	var subErr *pusher.SubscriptionError
+	if !errors.As(err, &subErr) || subErr.Type != "AuthError" || subErr.Status != 401 {
+		t.Fatalf("expected a 401 AuthError, got %v", err)
+	}
+	if count := srv.Subscribers("private-x"); count != 0 {
+		t.Fatalf("expected no subscribers, got %v", count)
+	}
+}
+
+func TestPresenceMembership(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	first := newClient(t, srv.ClientConfig())
+	first.UserData = pusher.Member{UserId: "first"}
+	second := newClient(t, srv.ClientConfig())
+	second.UserData = pusher.Member{UserId: "second"}
+
+	channel := subscribe(t, first, "presence-x")
+	subscribe(t, second, "presence-x")
+
+	members := srv.Members("presence-x")
+	if len(members) != 2 || members[0].UserId != "first" || members[1].UserId != "second" {
+		t.Fatalf("unexpected server members %v", members)
+	}
+	eventually(t, func() bool { return len(channel.Members()) == 2 }, "first client did not see the second join")
+
+	second.Disconnect()
+	eventually(t, func() bool { return len(channel.Members()) == 1 }, "first client did not see the second leave")
+	if members := srv.Members("presence-x"); len(members) != 1 || members[0].UserId != "first" {
+		t.Fatalf("unexpected server members %v", members)
+	}
+}
+
+func TestClose(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	client := newClient(t, srv.ClientConfig())
+	subscribe(t, client, "public")
+
+	srv.Close()
+	eventually(t, func() bool { return !client.IsConnected() }, "client still connected after Close")
+}
@@ -0,0 +1,79 @@
+package pusher
+
+import (
+	"sync"
+	"time"
+)
+
+// Pusher accepts at most this many client events per second on a connection
+const defaultClientEventRate = 10
+
+// rateLimiter is a token bucket refilled at rate tokens per second, allowing
+// bursts of up to rate events. Tokens go negative while queued events hold
+// reservations of tokens not yet refilled, each waiting its turn.
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	// Replaceable in tests
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	if rate < 0 {
+		return nil
+	}
+	if rate == 0 {
+		rate = defaultClientEventRate
+	}
+	return &rateLimiter{rate: float64(rate), tokens: float64(rate), last: time.Now(), now: time.Now, sleep: time.Sleep}
+}
+
+func (self *rateLimiter) refill(now time.Time) {
+	self.tokens += now.Sub(self.last).Seconds() * self.rate
+	if self.tokens > self.rate {
+		self.tokens = self.rate
+	}
+	self.last = now
+}
+
+// allow takes a token if one is available
+func (self *rateLimiter) allow() bool {
+	if self == nil {
+		return true
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.refill(self.now())
+	if self.tokens < 1 {
+		return false
+	}
+	self.tokens--
+	return true
+}
+
+// reserve takes a token, returning how long to wait before it may be used
+func (self *rateLimiter) reserve() time.Duration {
+	if self == nil {
+		return 0
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.refill(self.now())
+	self.tokens--
+	if self.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-self.tokens / self.rate * float64(time.Second))
+}
+
+// wait takes a token, waiting until it may be used
+func (self *rateLimiter) wait() {
+	if delay := self.reserve(); delay > 0 {
+		self.sleep(delay)
+	}
+}
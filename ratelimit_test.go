@@ -0,0 +1,97 @@
+package pusher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter whose clock only moves when advanced, or
+// when it sleeps, returning the delays slept
+func newTestLimiter(rate int) (limiter *rateLimiter, advance func(time.Duration), slept *[]time.Duration) {
+	now := time.Unix(0, 0)
+	limiter = newRateLimiter(rate)
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+	advance = func(delay time.Duration) { now = now.Add(delay) }
+	slept = new([]time.Duration)
+	limiter.sleep = func(delay time.Duration) {
+		*slept = append(*slept, delay)
+		advance(delay)
+	}
+	return
+}
+
+// allowed returns how many of n events the limiter allows
+func allowed(limiter *rateLimiter, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if limiter.allow() {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter, _, _ := newTestLimiter(5)
+	if count := allowed(limiter, 8); count != 5 {
+		t.Fatalf("allowed a burst of %v events, expected 5", count)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	limiter, advance, _ := newTestLimiter(5)
+	allowed(limiter, 5)
+
+	advance(100 * time.Millisecond)
+	if count := allowed(limiter, 5); count != 0 {
+		t.Fatalf("allowed %v events before a token was refilled", count)
+	}
+	advance(300 * time.Millisecond)
+	if count := allowed(limiter, 5); count != 2 {
+		t.Fatalf("allowed %v events after 400ms at 5/s, expected 2", count)
+	}
+	advance(time.Minute)
+	if count := allowed(limiter, 10); count != 5 {
+		t.Fatalf("allowed %v events after a minute, expected a burst of 5", count)
+	}
+}
+
+func TestRateLimiterQueue(t *testing.T) {
+	limiter, _, slept := newTestLimiter(10)
+	for i := 0; i < 13; i++ {
+		limiter.wait()
+	}
+	expected := []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}
+	if !reflect.DeepEqual(*slept, expected) {
+		t.Fatalf("waited %v, expected %v", *slept, expected)
+	}
+}
+
+// TestRateLimiterReserved rejects events while queued events hold the
+// tokens being refilled
+func TestRateLimiterReserved(t *testing.T) {
+	limiter, advance, _ := newTestLimiter(10)
+	for i := 0; i < 12; i++ {
+		limiter.reserve()
+	}
+	advance(250 * time.Millisecond)
+	if limiter.allow() {
+		t.Fatal("allowed an event while reservations were outstanding")
+	}
+	advance(100 * time.Millisecond)
+	if !limiter.allow() {
+		t.Fatal("rejected an event once the reservations were refilled")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := newRateLimiter(-1)
+	if count := allowed(limiter, 100); count != 100 {
+		t.Fatalf("disabled limiter allowed %v of 100 events", count)
+	}
+	if delay := limiter.reserve(); delay != 0 {
+		t.Fatalf("disabled limiter reserved a delay of %v", delay)
+	}
+}
@@ -0,0 +1,16 @@
+package pusher
+
+import (
+	"time"
+)
+
+// ReconnectStrategy decides how long to wait before each attempt to
+// reconnect, and when to give up.
+type ReconnectStrategy interface {
+	// NextDelay is called after a connection attempt fails or an
+	// established connection is lost. attempt counts the attempts since the
+	// last successful handshake, starting at 1, and lastErr is the failure,
+	// which may be nil. Returning false gives up, moving the client to
+	// StateFailed. It is called from the run loop, so must not block.
+	NextDelay(attempt int, lastErr error) (time.Duration, bool)
+}
@@ -0,0 +1,377 @@
+package pusher_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// fakeConn is a connection which discards what it is sent
+type fakeConn struct{}
+
+func (fakeConn) Send(message []byte) error                { return nil }
+func (fakeConn) SetActivityTimeout(timeout time.Duration) {}
+func (fakeConn) Close() error                             { return nil }
+
+func (fakeConn) Shutdown(messages [][]byte) <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+const established = `{"event":"pusher:connection_established","data":"{\"socket_id\":\"1.1\",\"activity_timeout\":120}"}`
+
+// reconnect is a reconnection reported to MetricsCollector.Reconnecting
+type reconnect struct {
+	attempt int
+	delay   time.Duration
+}
+
+// reconnects reports reconnections, discarding other metrics
+type reconnects chan reconnect
+
+func (reconnects) Connected()                                                   {}
+func (reconnects) Disconnected()                                                {}
+func (reconnects) MessageReceived(channel, event string, size int)              {}
+func (reconnects) MessageSent(channel, event string, size int)                  {}
+func (reconnects) SubscriptionSucceeded(channel string)                         {}
+func (reconnects) SubscriptionFailed(channel string, err error)                 {}
+func (reconnects) PingRTT(rtt time.Duration)                                    {}
+func (reconnects) HandlerLatency(channel, event string, duration time.Duration) {}
+
+func (self reconnects) Reconnecting(attempt int, delay time.Duration) {
+	self <- reconnect{attempt, delay}
+}
+
+// fakeClient starts a client whose connections are fakeConns. dial decides
+// whether each attempt succeeds, and the callbacks of those which do are
+// returned in order.
+func fakeClient(t *testing.T, config pusher.ClientConfig, dial func() error) (client *pusher.Client, conns chan pusher.ConnCallbacks, reconnected reconnects, failed chan error) {
+	conns = make(chan pusher.ConnCallbacks, 16)
+	reconnected = make(reconnects, 16)
+	failed = make(chan error, 1)
+	config.NewConn = func(c pusher.ClientConfig, transport string, callbacks pusher.ConnCallbacks) (pusher.Conn, error) {
+		if err := dial(); err != nil {
+			return nil, err
+		}
+		conns <- callbacks
+		return fakeConn{}, nil
+	}
+	config.Metrics = reconnected
+	config.OnConnectionFailed = func(err error) { failed <- err }
+	client = pusher.NewWithConfig(config)
+	t.Cleanup(client.Disconnect)
+	return
+}
+
+// nextConn waits for the client's next connection
+func nextConn(t *testing.T, conns chan pusher.ConnCallbacks) pusher.ConnCallbacks {
+	t.Helper()
+	select {
+	case callbacks := <-conns:
+		return callbacks
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a connection")
+		return pusher.ConnCallbacks{}
+	}
+}
+
+// TestCloseCodes closes connections with each class of Pusher error code,
+// carried by the close frame or by a preceding pusher:error
+func TestCloseCodes(t *testing.T) {
+	const (
+		giveUp = iota
+		backOff
+		immediate
+	)
+	serverError := func(code int) string {
+		return fmt.Sprintf(`{"event":"pusher:error","data":{"code":%v,"message":"error"}}`, code)
+	}
+	closed := errors.New("pusher: connection closed with code 1000")
+
+	for _, test := range []struct {
+		name    string
+		message string
+		err     error
+		outcome int
+		code    int
+	}{
+		{"4000-4099 close", "", &pusher.ConnectionError{Code: 4001, Message: "app disabled"}, giveUp, 4001},
+		{"4100-4199 close", "", &pusher.ConnectionError{Code: 4100, Message: "over capacity"}, backOff, 0},
+		{"4200-4299 close", "", &pusher.ConnectionError{Code: 4200, Message: "reconnect"}, immediate, 0},
+		{"abnormal close", "", errors.New("pusher: connection closed with code 1006"), backOff, 0},
+		{"4000-4099 pusher:error", serverError(4009), closed, giveUp, 4009},
+		{"4100-4199 pusher:error", serverError(4100), closed, backOff, 0},
+		{"4200-4299 pusher:error", serverError(4201), closed, immediate, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, conns, reconnected, failed := fakeClient(t, pusher.ClientConfig{}, func() error { return nil })
+			callbacks := nextConn(t, conns)
+			callbacks.OnMessage <- established
+			if test.message != "" {
+				callbacks.OnMessage <- test.message
+			}
+			callbacks.OnClose <- test.err
+
+			select {
+			case err := <-failed:
+				var connErr *pusher.ConnectionError
+				if test.outcome != giveUp {
+					t.Fatalf("gave up after %v", err)
+				} else if !errors.As(err, &connErr) || connErr.Code != test.code {
+					t.Fatalf("gave up with %v, expected code %v", err, test.code)
+				}
+				if state := client.State(); state != pusher.StateFailed {
+					t.Fatalf("client is %v after giving up", state)
+				}
+			case reconnect := <-reconnected:
+				switch {
+				case test.outcome == giveUp:
+					t.Fatalf("reconnecting in %v instead of giving up", reconnect.delay)
+				case test.outcome == immediate && reconnect.delay != 0:
+					t.Fatalf("reconnecting in %v, expected immediately", reconnect.delay)
+				case test.outcome == backOff && reconnect.delay == 0:
+					t.Fatal("reconnecting immediately, expected a backoff")
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("neither reconnected nor gave up")
+			}
+		})
+	}
+}
+
+// TestCloseCodeFromServer closes a WebSocket connection with a Pusher code,
+// which the transport must report as a ConnectionError
+func TestCloseCodeFromServer(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	failed := make(chan error, 1)
+	config.OnConnectionFailed = func(err error) { failed <- err }
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := client.SubscribeWithResult(ctx, "x"); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	srv.DisconnectAll(4003, "app disabled")
+	select {
+	case err := <-failed:
+		var connErr *pusher.ConnectionError
+		if !errors.As(err, &connErr) || connErr.Code != 4003 {
+			t.Fatalf("gave up with %v, expected code 4003", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not give up after a 4000-4099 close")
+	}
+}
+
+// TestMaxReconnectAttempts fails every connection attempt, after
+// MaxReconnectAttempts of which the client must give up
+func TestMaxReconnectAttempts(t *testing.T) {
+	var dials atomic.Int32
+	refused := errors.New("connection refused")
+	config := pusher.ClientConfig{MaxReconnectAttempts: 3, MaxReconnectDelay: time.Millisecond}
+	client, _, reconnected, failed := fakeClient(t, config, func() error {
+		dials.Add(1)
+		return refused
+	})
+
+	select {
+	case err := <-failed:
+		if !errors.Is(err, refused) || !strings.Contains(err.Error(), "after 3 attempts") {
+			t.Fatalf("gave up with %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not give up")
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		if reconnect := <-reconnected; reconnect.attempt != attempt {
+			t.Fatalf("reconnection %v reported as attempt %v", attempt, reconnect.attempt)
+		}
+	}
+	if count := dials.Load(); count != 4 {
+		t.Fatalf("dialled %v times, expected the first attempt and 3 reconnections", count)
+	}
+	if state := client.State(); state != pusher.StateFailed {
+		t.Fatalf("client is %v after giving up", state)
+	}
+	if err := client.Connect(); err != pusher.ErrDisconnected {
+		t.Fatalf("expected ErrDisconnected connecting again, got %v", err)
+	}
+}
+
+// strategy is a ReconnectStrategy waiting delays[attempt-1], and giving up
+// after the last, recording the attempts and errors it is asked about
+type strategy struct {
+	delays []time.Duration
+	errs   chan error
+}
+
+func (self strategy) NextDelay(attempt int, lastErr error) (time.Duration, bool) {
+	self.errs <- lastErr
+	if attempt > len(self.delays) {
+		return 0, false
+	}
+	return self.delays[attempt-1], true
+}
+
+// TestReconnectStrategy fails every connection attempt, which must be retried
+// after the strategy's delays until it gives up
+func TestReconnectStrategy(t *testing.T) {
+	refused := errors.New("connection refused")
+	strategy := strategy{delays: []time.Duration{time.Millisecond, 3 * time.Millisecond}, errs: make(chan error, 16)}
+	config := pusher.ClientConfig{ReconnectStrategy: strategy}
+	client, _, reconnected, failed := fakeClient(t, config, func() error { return refused })
+
+	select {
+	case err := <-failed:
+		if !errors.Is(err, refused) || !strings.Contains(err.Error(), "after 2 attempts") {
+			t.Fatalf("gave up with %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not give up")
+	}
+	for attempt, delay := range strategy.delays {
+		if reconnect := <-reconnected; reconnect.attempt != attempt+1 || reconnect.delay != delay {
+			t.Fatalf("reconnection %v after %v, expected attempt %v after %v", reconnect.attempt, reconnect.delay, attempt+1, delay)
+		}
+	}
+	select {
+	case reconnect := <-reconnected:
+		t.Fatalf("reconnected after the strategy gave up: %+v", reconnect)
+	default:
+	}
+	if len(strategy.errs) != 3 {
+		t.Fatalf("strategy asked %v times, expected 3", len(strategy.errs))
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-strategy.errs; err != refused {
+			t.Fatalf("strategy was passed %v", err)
+		}
+	}
+	if state := client.State(); state != pusher.StateFailed {
+		t.Fatalf("client is %v after giving up", state)
+	}
+}
+
+// TestReconnectAttemptsReset completes a handshake after failed attempts,
+// which must restart the count towards MaxReconnectAttempts
+func TestReconnectAttemptsReset(t *testing.T) {
+	var dials atomic.Int32
+	config := pusher.ClientConfig{MaxReconnectAttempts: 2, MaxReconnectDelay: time.Millisecond}
+	_, conns, reconnected, failed := fakeClient(t, config, func() error {
+		if dials.Add(1) <= 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	callbacks := nextConn(t, conns)
+	callbacks.OnMessage <- established
+	callbacks.OnClose <- errors.New("connection lost")
+	for _, expected := range []int{1, 2, 1} {
+		select {
+		case reconnect := <-reconnected:
+			if reconnect.attempt != expected {
+				t.Fatalf("reconnection reported as attempt %v, expected %v", reconnect.attempt, expected)
+			}
+		case err := <-failed:
+			t.Fatalf("gave up after %v", err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a reconnection")
+		}
+	}
+}
+
+// TestAlternateHosts fails to connect to every host, which must be tried in
+// turn before returning to the first
+func TestAlternateHosts(t *testing.T) {
+	hosts := make(chan string, 100)
+	config := pusher.ClientConfig{
+		Host:              "primary",
+		Port:              "443",
+		AlternateHosts:    []string{"backup-1", "backup-2:8443"},
+		MaxReconnectDelay: time.Millisecond,
+		NewConn: func(c pusher.ClientConfig, transport string, callbacks pusher.ConnCallbacks) (pusher.Conn, error) {
+			hosts <- c.Host + ":" + c.Port
+			return nil, errors.New("connection refused")
+		},
+	}
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	var rotation []string
+	for len(rotation) < 4 {
+		select {
+		case host := <-hosts:
+			if len(rotation) == 0 || rotation[len(rotation)-1] != host {
+				rotation = append(rotation, host)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out after trying %v", rotation)
+		}
+	}
+	if expected := []string{"primary:443", "backup-1:443", "backup-2:8443", "primary:443"}; strings.Join(rotation, " ") != strings.Join(expected, " ") {
+		t.Fatalf("tried %v, expected %v", rotation, expected)
+	}
+}
+
+// TestNoResubscribe drops the channels which opted out of resubscription on
+// reconnection, and resubscribes the others after BeforeResubscribe
+func TestNoResubscribe(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	for _, noResubscribe := range []bool{false, true} {
+		t.Run(fmt.Sprintf("NoResubscribe %v", noResubscribe), func(t *testing.T) {
+			before := make(chan interface{}, 10)
+			config := srv.ClientConfig()
+			config.NoResubscribe = noResubscribe
+			config.BeforeResubscribe = func(channels []string) { before <- strings.Join(channels, " ") }
+			client := pusher.NewWithConfig(config)
+			defer client.Disconnect()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			// Each client's kept and dropped channels, whether by default or
+			// overridden
+			kept, dropped := fmt.Sprintf("kept-%v", noResubscribe), fmt.Sprintf("dropped-%v", noResubscribe)
+			events := make(chan interface{}, 10)
+			for _, name := range []string{kept, dropped} {
+				channel, err := client.SubscribeWithResult(ctx, name)
+				if err != nil {
+					t.Fatalf("subscribing: %v", err)
+				}
+				name := name
+				channel.OnResubscribed(func() { events <- "resubscribed " + name })
+				channel.OnUnsubscribed(func() { events <- "unsubscribed " + name })
+			}
+			if noResubscribe {
+				client.FindChannel(kept).SetResubscribe(true)
+			} else {
+				client.FindChannel(dropped).SetResubscribe(false)
+			}
+
+			srv.DisconnectAll(4200, "reconnect")
+			if channels := receive(t, before); channels != kept {
+				t.Fatalf("BeforeResubscribe called with %v, expected %v", channels, kept)
+			}
+			got := map[interface{}]bool{receive(t, events): true, receive(t, events): true}
+			if !got["resubscribed "+kept] || !got["unsubscribed "+dropped] {
+				t.Fatalf("unexpected callbacks %v", got)
+			}
+			if srv.Subscribers(kept) != 1 || srv.Subscribers(dropped) != 0 {
+				t.Fatalf("server has %v subscribers to %v and %v to %v", srv.Subscribers(kept), kept, srv.Subscribers(dropped), dropped)
+			}
+		})
+	}
+}
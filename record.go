@@ -0,0 +1,206 @@
+package pusher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Directions of recorded frames
+const (
+	FrameIn    = "in"
+	FrameOut   = "out"
+	FrameOpen  = "open"
+	FrameClose = "close"
+)
+
+// Frame is an entry of a session recording: a message received or sent, or
+// a connection being opened or ending
+type Frame struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"dir"`
+	// Data is the message, or for FrameClose the error which ended the
+	// connection, or the reason given with its Code
+	Data string `json:"data,omitempty"`
+	// Code is the close code of a FrameClose, when the server closed the
+	// connection with one of Pusher's own codes, see ConnectionError
+	Code int `json:"code,omitempty"`
+}
+
+// recorder writes frames to ClientConfig.Recording as JSON lines, from the
+// read and write loops of every connection
+type recorder struct {
+	mutex    sync.Mutex
+	encoder  *json.Encoder
+	scrubber Scrubber
+	raw      bool
+}
+
+func newRecorder(c ClientConfig) *recorder {
+	if c.Recording == nil {
+		return nil
+	}
+	return &recorder{encoder: json.NewEncoder(c.Recording), scrubber: c.Scrubber, raw: c.RecordRaw}
+}
+
+func (self *recorder) record(direction, data string) {
+	if self == nil {
+		return
+	}
+	if !self.raw && (direction == FrameIn || direction == FrameOut) {
+		data = maskAuth(scrubMessage(self.scrubber, []byte(data)))
+	}
+	self.write(Frame{Time: time.Now(), Direction: direction, Data: data})
+}
+
+// recordClose records the error which ended a connection
+func (self *recorder) recordClose(err error) {
+	if self == nil {
+		return
+	}
+	frame := Frame{Time: time.Now(), Direction: FrameClose, Data: err.Error()}
+	var connErr *ConnectionError
+	if errors.As(err, &connErr) && connErr.Code != 0 {
+		frame.Data, frame.Code = connErr.Message, connErr.Code
+	}
+	self.write(frame)
+}
+
+func (self *recorder) write(frame Frame) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.encoder.Encode(frame)
+}
+
+// maskAuth masks the auth signature of a pusher:subscribe message
+func maskAuth(msg string) string {
+	var payload map[string]json.RawMessage
+	if json.Unmarshal([]byte(msg), &payload) != nil || string(payload["event"]) != `"pusher:subscribe"` {
+		return msg
+	}
+	var data map[string]json.RawMessage
+	if json.Unmarshal(payload["data"], &data) != nil || data["auth"] == nil {
+		return msg
+	}
+	data["auth"] = json.RawMessage(`"[redacted]"`)
+	payload["data"], _ = json.Marshal(data)
+	masked, _ := json.Marshal(payload)
+	return string(masked)
+}
+
+// Replayer feeds a recorded session back through a client, so that issues
+// seen in production can be reproduced deterministically. Set
+// ClientConfig.NewConn to its NewConn method: each connection the client
+// opens replays the received frames of the next recorded connection, and
+// ends with the error it ended with. Messages sent by the client are
+// discarded. Once the recording is exhausted, connections stay open without
+// receiving anything.
+type Replayer struct {
+	// Realtime reproduces the recorded delays between received frames,
+	// rather than delivering them at once
+	Realtime bool
+
+	mutex    sync.Mutex
+	sessions [][]Frame
+}
+
+// NewReplayer reads a recording made with ClientConfig.Recording
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	replayer := &Replayer{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, err
+		}
+		switch {
+		case frame.Direction == FrameOpen:
+			replayer.sessions = append(replayer.sessions, nil)
+		case len(replayer.sessions) == 0:
+			return nil, errors.New("pusher: recording does not start with a connection")
+		default:
+			last := len(replayer.sessions) - 1
+			replayer.sessions[last] = append(replayer.sessions[last], frame)
+		}
+	}
+	return replayer, scanner.Err()
+}
+
+// NewConn opens a connection replaying the next recorded connection
+func (self *Replayer) NewConn(c ClientConfig, transport string, callbacks ConnCallbacks) (Conn, error) {
+	self.mutex.Lock()
+	var frames []Frame
+	if len(self.sessions) > 0 {
+		frames, self.sessions = self.sessions[0], self.sessions[1:]
+	}
+	self.mutex.Unlock()
+
+	conn := &replayConn{closed: make(chan struct{})}
+	go conn.replay(frames, callbacks, self.Realtime)
+	return conn, nil
+}
+
+type replayConn struct {
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (self *replayConn) replay(frames []Frame, callbacks ConnCallbacks, realtime bool) {
+	var last time.Time
+	for _, frame := range frames {
+		if realtime && !last.IsZero() {
+			select {
+			case <-time.After(frame.Time.Sub(last)):
+			case <-self.closed:
+				return
+			}
+		}
+		last = frame.Time
+
+		switch frame.Direction {
+		case FrameIn:
+			select {
+			case callbacks.OnMessage <- frame.Data:
+			case <-self.closed:
+				return
+			}
+		case FrameClose:
+			select {
+			case callbacks.OnClose <- frame.err():
+			case <-self.closed:
+			}
+			return
+		}
+	}
+}
+
+// err rebuilds the error recorded in a FrameClose
+func (self Frame) err() error {
+	if self.Code != 0 {
+		return closeError(self.Code, self.Data)
+	}
+	return errors.New(self.Data)
+}
+
+func (self *replayConn) Send(message []byte) error { return nil }
+
+func (self *replayConn) SetActivityTimeout(timeout time.Duration) {}
+
+func (self *replayConn) Shutdown(messages [][]byte) <-chan struct{} {
+	self.Close()
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (self *replayConn) Close() error {
+	self.closeOnce.Do(func() { close(self.closed) })
+	return nil
+}
@@ -0,0 +1,120 @@
+package pusher_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// collect binds a callback collecting the client's events on channel x
+func collect(client *pusher.Client) chan interface{} {
+	events := make(chan interface{}, 10)
+	client.BindGlobalEvent(func(event pusher.Event) {
+		if event.Channel == "x" {
+			events <- event.Name + " " + event.Data
+		}
+	})
+	return events
+}
+
+// TestRecordReplay records a session, which replayed through another client
+// must deliver the same events
+func TestRecordReplay(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	var recording lockedBuffer
+	config := srv.ClientConfig()
+	config.Recording = &recording
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	recorded := collect(client)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := client.SubscribeWithResult(ctx, "x"); err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	srv.Trigger("x", "created", map[string]int{"id": 1})
+	srv.Trigger("x", "updated", "text")
+	var events []interface{}
+	for i := 0; i < 2; i++ {
+		events = append(events, receive(t, recorded))
+	}
+
+	replayer, err := pusher.NewReplayer(strings.NewReader(recording.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = pusher.ClientConfig{Key: "key", NewConn: replayer.NewConn, DisableAutoConnect: true}
+	replay := pusher.NewWithConfig(config)
+	defer replay.Disconnect()
+	replayed := collect(replay)
+	replay.Subscribe("x")
+	if err := replay.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	var replayedEvents []interface{}
+	for i := 0; i < 2; i++ {
+		replayedEvents = append(replayedEvents, receive(t, replayed))
+	}
+	if !reflect.DeepEqual(replayedEvents, events) {
+		t.Fatalf("replayed %v, recorded %v", replayedEvents, events)
+	}
+}
+
+// TestRecordMasksAuth records a presence subscription, whose auth signature
+// must be masked unless RecordRaw is set
+func TestRecordMasksAuth(t *testing.T) {
+	for _, raw := range []bool{false, true} {
+		srv := pushertest.NewServer("key", "secret")
+		var recording lockedBuffer
+		config := srv.ClientConfig()
+		config.Recording = &recording
+		config.RecordRaw = raw
+		client := pusher.NewWithConfig(config)
+		client.UserData = pusher.Member{UserId: "me"}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := client.SubscribeWithResult(ctx, "presence-x")
+		cancel()
+		client.Disconnect()
+		srv.Close()
+		if err != nil {
+			t.Fatalf("subscribing: %v", err)
+		}
+
+		var auth, channelData string
+		scanner := bufio.NewScanner(strings.NewReader(recording.String()))
+		for scanner.Scan() {
+			var frame pusher.Frame
+			var message struct {
+				Event string `json:"event"`
+				Data  struct {
+					Auth        string `json:"auth"`
+					ChannelData string `json:"channel_data"`
+				} `json:"data"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &frame) != nil || frame.Direction != pusher.FrameOut {
+				continue
+			}
+			if json.Unmarshal([]byte(frame.Data), &message) == nil && message.Event == "pusher:subscribe" {
+				auth, channelData = message.Data.Auth, message.Data.ChannelData
+			}
+		}
+		if raw && !strings.HasPrefix(auth, "key:") {
+			t.Fatalf("expected the raw auth signature to be recorded, got %q", auth)
+		}
+		if !raw && auth != "[redacted]" {
+			t.Fatalf("expected the auth signature to be masked, got %q", auth)
+		}
+		if !strings.Contains(channelData, `"me"`) {
+			t.Fatalf("expected the channel data to be kept, got %q", channelData)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package redisbridge republishes the events received by a pusher client to
+// Redis pub/sub, and can mirror Redis publishes back onto private channels as
+// client events.
+package redisbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	s "strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/internal/relay"
+)
+
+const (
+	// Default prefix of the Redis channels events are published to
+	DefaultPrefix = "pusher:"
+	// Default prefix of the Redis channels mirrored back as client events
+	DefaultInboundPrefix = "pusher-in:"
+	// Default number of events queued while Redis is unavailable
+	DefaultBuffer = 1024
+
+	maxRetryDelay = 30 * time.Second
+)
+
+// ErrDropped is reported to OnError for events dropped because the queue was
+// full, and for mirrored messages still held when the bridge is closed
+var ErrDropped = errors.New("redisbridge: queue full, event dropped")
+
+// Message is the JSON payload published to, and read from, Redis
+type Message struct {
+	Channel string          `json:"channel"`
+	Event   string          `json:"event"`
+	Data    json.RawMessage `json:"data"`
+	UserId  string          `json:"user_id,omitempty"`
+}
+
+// Bridge connects a pusher client to Redis. Events on channel "foo" are
+// published to Prefix+"foo". With Mirror set, messages published to
+// InboundPrefix+"private-foo" are triggered on private-foo as client events.
+//
+// Both sides survive reconnects: the pusher client resubscribes on its own,
+// go-redis resubscribes its pub/sub connection, publishes which fail are
+// retried with backoff while queued events wait, and mirrored messages are
+// held until their channel is subscribed again.
+type Bridge struct {
+	client *pusher.Client
+	redis  redis.UniversalClient
+
+	Prefix        string
+	InboundPrefix string
+	// Mirror enables forwarding Redis publishes to private and presence
+	// channels as client events
+	Mirror bool
+	// Buffer is the number of events queued while publishing is failing,
+	// and of mirrored messages held while their channel is not subscribed
+	Buffer int
+	// OnError is called for events which could not be forwarded
+	OnError func(error)
+
+	relay      *relay.Relay[Message]
+	cancel     context.CancelFunc
+	stopMirror context.CancelFunc
+	done       sync.WaitGroup
+
+	// held holds the mirrored messages of each channel waiting for it to be
+	// subscribed, and is only used by the mirror goroutine
+	held       map[string][]Message
+	heldCount  int
+	subscribed chan struct{}
+}
+
+func New(client *pusher.Client, rdb redis.UniversalClient) *Bridge {
+	return &Bridge{
+		client:        client,
+		redis:         rdb,
+		Prefix:        DefaultPrefix,
+		InboundPrefix: DefaultInboundPrefix,
+		Buffer:        DefaultBuffer,
+	}
+}
+
+// Start subscribes to the given channels and begins bridging them
+func (self *Bridge) Start(channels ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	self.cancel = cancel
+	self.relay = relay.Start(self.client, channels, self.Buffer, func(event pusher.Event) (Message, bool) {
+		return Message{Channel: event.Channel, Event: event.Name, Data: raw(event.Data), UserId: event.UserId}, true
+	}, func(Message) {
+		self.fail(ErrDropped)
+	})
+
+	var inbound []string
+	for _, channel := range channels {
+		if self.Mirror && (s.HasPrefix(channel, "private-") || s.HasPrefix(channel, "presence-")) {
+			inbound = append(inbound, self.InboundPrefix+channel)
+		}
+	}
+
+	self.done.Add(1)
+	go self.publish(ctx)
+
+	// Mirroring stops as soon as the bridge is closed, while publishing
+	// drains the queue
+	mirrorCtx, stopMirror := context.WithCancel(ctx)
+	self.stopMirror = stopMirror
+	if len(inbound) > 0 {
+		self.held = map[string][]Message{}
+		self.subscribed = make(chan struct{}, 1)
+		for _, name := range inbound {
+			channel := self.relay.Channel(s.TrimPrefix(name, self.InboundPrefix))
+			channel.OnSubscribed(self.notifySubscribed)
+			channel.OnResubscribed(self.notifySubscribed)
+		}
+		pubsub := self.redis.Subscribe(mirrorCtx, inbound...)
+		self.done.Add(1)
+		go self.mirror(mirrorCtx, pubsub)
+	}
+}
+
+// Close unsubscribes from both sides, waiting up to the context's deadline
+// for queued events to be published. It does nothing if the bridge was never
+// started, or is already closed.
+func (self *Bridge) Close(ctx context.Context) {
+	if self.relay == nil {
+		return
+	}
+	self.relay.Close()
+	self.stopMirror()
+
+	stop := context.AfterFunc(ctx, self.cancel)
+	defer stop()
+	self.done.Wait()
+	self.cancel()
+}
+
+func (self *Bridge) publish(ctx context.Context) {
+	defer self.done.Done()
+
+	for message := range self.relay.Queue() {
+		payload, err := json.Marshal(message)
+		if err != nil {
+			self.fail(err)
+			continue
+		}
+
+		delay := time.Second
+		for {
+			err := self.redis.Publish(ctx, self.Prefix+message.Channel, payload).Err()
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				self.fail(err)
+				break
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+		}
+	}
+}
+
+func (self *Bridge) mirror(ctx context.Context, pubsub *redis.PubSub) {
+	defer self.done.Done()
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				self.dropHeld()
+				return
+			}
+			self.hold(msg)
+		case <-self.subscribed:
+			for name := range self.held {
+				self.release(name)
+			}
+		case <-ctx.Done():
+			self.dropHeld()
+			return
+		}
+	}
+}
+
+// notifySubscribed wakes the mirror goroutine to release held messages. It
+// runs on the client's run loop, so must not block.
+func (self *Bridge) notifySubscribed() {
+	select {
+	case self.subscribed <- struct{}{}:
+	default:
+	}
+}
+
+// hold queues a mirrored message behind any others held for its channel, and
+// triggers as many of them as the channel accepts
+func (self *Bridge) hold(msg *redis.Message) {
+	var message Message
+	if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+		self.fail(err)
+		return
+	}
+	if self.heldCount >= self.Buffer {
+		self.fail(ErrDropped)
+		return
+	}
+	name := s.TrimPrefix(msg.Channel, self.InboundPrefix)
+	self.held[name] = append(self.held[name], message)
+	self.heldCount++
+	self.release(name)
+}
+
+// release triggers the held messages of a channel in order, stopping at the
+// first which cannot be sent until the channel is subscribed
+func (self *Bridge) release(name string) {
+	channel := self.relay.Channel(name)
+	held := self.held[name]
+	for len(held) > 0 {
+		message := held[0]
+		if !s.HasPrefix(message.Event, "client-") {
+			message.Event = "client-" + message.Event
+		}
+		err := channel.Trigger(message.Event, message.Data)
+		if errors.Is(err, pusher.ErrNotConnected) || errors.Is(err, pusher.ErrNotSubscribed) {
+			break
+		}
+		if err != nil {
+			self.fail(err)
+		}
+		held = held[1:]
+		self.heldCount--
+	}
+	if len(held) == 0 {
+		delete(self.held, name)
+	} else {
+		self.held[name] = held
+	}
+}
+
+// dropHeld reports the messages still held when mirroring stops
+func (self *Bridge) dropHeld() {
+	for name, held := range self.held {
+		for range held {
+			self.fail(ErrDropped)
+		}
+		delete(self.held, name)
+	}
+	self.heldCount = 0
+}
+
+func (self *Bridge) fail(err error) {
+	if self.OnError != nil {
+		self.OnError(err)
+	}
+}
+
+// raw passes JSON event data through untouched, and quotes anything else
+func raw(data string) json.RawMessage {
+	if json.Valid([]byte(data)) {
+		return json.RawMessage(data)
+	}
+	quoted, _ := json.Marshal(data)
+	return quoted
+}
@@ -0,0 +1,293 @@
+package redisbridge_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+	"github.com/mnaser/pusher-websocket-go/redisbridge"
+)
+
+// fakeRedis speaks just enough RESP2 for PUBLISH and SUBSCRIBE
+type fakeRedis struct {
+	listener net.Listener
+	// published receives "channel payload" for each PUBLISH
+	published chan string
+	// subscribed receives the channel of each SUBSCRIBE
+	subscribed chan string
+
+	mutex       sync.Mutex
+	subscribers map[string][]net.Conn
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	fake := &fakeRedis{
+		listener:    listener,
+		published:   make(chan string, 100),
+		subscribed:  make(chan string, 100),
+		subscribers: map[string][]net.Conn{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go fake.serve(conn)
+		}
+	}()
+	return fake
+}
+
+// client returns a go-redis client of the server
+func (self *fakeRedis) client(t *testing.T) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{Addr: self.listener.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func (self *fakeRedis) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	var writeMutex sync.Mutex
+	write := func(reply string) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		io.WriteString(conn, reply)
+	}
+	for {
+		command, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(command[0]) {
+		case "PING":
+			if len(self.channelsOf(conn)) > 0 {
+				write("*2\r\n" + bulk("pong") + bulk(""))
+			} else {
+				write("+PONG\r\n")
+			}
+		case "PUBLISH":
+			self.published <- command[1] + " " + command[2]
+			write(":1\r\n")
+		case "SUBSCRIBE":
+			for _, channel := range command[1:] {
+				self.mutex.Lock()
+				self.subscribers[channel] = append(self.subscribers[channel], conn)
+				self.mutex.Unlock()
+				write("*3\r\n" + bulk("subscribe") + bulk(channel) + fmt.Sprintf(":%v\r\n", len(self.channelsOf(conn))))
+				self.subscribed <- channel
+			}
+		default:
+			write("-ERR unknown command\r\n")
+		}
+	}
+}
+
+func (self *fakeRedis) channelsOf(conn net.Conn) (channels []string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for channel, conns := range self.subscribers {
+		for _, c := range conns {
+			if c == conn {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	return
+}
+
+// publish sends a message to the subscribers of channel
+func (self *fakeRedis) publish(channel, payload string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, conn := range self.subscribers[channel] {
+		io.WriteString(conn, "*3\r\n"+bulk("message")+bulk(channel)+bulk(payload))
+	}
+}
+
+func bulk(value string) string {
+	return fmt.Sprintf("$%v\r\n%v\r\n", len(value), value)
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	command := make([]string, count)
+	for i := range command {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return nil, err
+		}
+		command[i] = string(value[:size])
+	}
+	return command, nil
+}
+
+// receive waits for the next value on values
+func receive(t *testing.T, values <-chan string) string {
+	t.Helper()
+	select {
+	case value := <-values:
+		return value
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out")
+		return ""
+	}
+}
+
+// waitSubscribed waits until the bridge's client is subscribed to channel
+func waitSubscribed(t *testing.T, client *pusher.Client, channel string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Subscribe(channel).WaitSubscribed(ctx); err != nil {
+		t.Fatalf("subscribing to %v: %v", channel, err)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	fake := newFakeRedis(t)
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	defer client.Disconnect()
+	bridge := redisbridge.New(client, fake.client(t))
+	bridge.Start("x")
+	defer bridge.Close(context.Background())
+	waitSubscribed(t, client, "x")
+
+	srv.Trigger("x", "json", map[string]int{"a": 1})
+	srv.Trigger("x", "text", "hello")
+	for _, expected := range []string{
+		`pusher:x {"channel":"x","event":"json","data":{"a":1}}`,
+		`pusher:x {"channel":"x","event":"text","data":"hello"}`,
+	} {
+		if published := receive(t, fake.published); published != expected {
+			t.Fatalf("published %v, expected %v", published, expected)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	clientEvents := make(chan string, 10)
+	srv.OnClientEvent = func(socketID string, event pusher.Event) {
+		clientEvents <- event.Channel + " " + event.Name + " " + event.Data
+	}
+	fake := newFakeRedis(t)
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	client.UserData = pusher.Member{UserId: "bridge"}
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan string, 10)
+	bridge := redisbridge.New(client, fake.client(t))
+	bridge.Mirror = true
+	bridge.OnError = func(err error) { errs <- err.Error() }
+	bridge.Start("presence-x", "public")
+	defer bridge.Close(context.Background())
+	if channel := receive(t, fake.subscribed); channel != "pusher-in:presence-x" {
+		t.Fatalf("subscribed to %v, expected only the presence channel to be mirrored", channel)
+	}
+	waitSubscribed(t, client, "presence-x")
+
+	message, _ := json.Marshal(redisbridge.Message{Event: "typing", Data: json.RawMessage(`{"typing":true}`)})
+	fake.publish("pusher-in:presence-x", string(message))
+	if event := receive(t, clientEvents); event != `presence-x client-typing {"typing":true}` {
+		t.Fatalf("server received %v", event)
+	}
+
+	fake.publish("pusher-in:presence-x", "not json")
+	if err := receive(t, errs); !strings.Contains(err, "invalid character") {
+		t.Fatalf("expected the invalid message to be reported, got %v", err)
+	}
+}
+
+func TestMirrorHeldUntilSubscribed(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	clientEvents := make(chan string, 10)
+	srv.OnClientEvent = func(socketID string, event pusher.Event) {
+		clientEvents <- event.Name + " " + event.Data
+	}
+	fake := newFakeRedis(t)
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+	client.UserData = pusher.Member{UserId: "bridge"}
+
+	errs := make(chan error, 10)
+	bridge := redisbridge.New(client, fake.client(t))
+	bridge.Mirror = true
+	bridge.Buffer = 2
+	bridge.OnError = func(err error) { errs <- err }
+	bridge.Start("presence-x")
+	defer bridge.Close(context.Background())
+	receive(t, fake.subscribed)
+
+	// The client is not connected yet, so messages are held, up to Buffer
+	for _, event := range []string{"first", "second", "third"} {
+		message, _ := json.Marshal(redisbridge.Message{Event: event, Data: json.RawMessage(`{}`)})
+		fake.publish("pusher-in:presence-x", string(message))
+	}
+	select {
+	case err := <-errs:
+		if err != redisbridge.ErrDropped {
+			t.Fatalf("expected the message over Buffer to be dropped, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the message over Buffer to be dropped")
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"client-first {}", "client-second {}"} {
+		if event := receive(t, clientEvents); event != expected {
+			t.Fatalf("server received %v, expected %v", event, expected)
+		}
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error %v", err)
+	default:
+	}
+}
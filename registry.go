@@ -0,0 +1,158 @@
+package pusher
+
+import (
+	"sync"
+)
+
+// registry holds the channels of a client and its facades. It is modified by
+// the run loop, and by Subscribe registering new channels, and may be read
+// from any goroutine.
+type registry struct {
+	mutex    sync.RWMutex
+	channels []*Channel
+	// Unsubscribed channels whose bindings are retained, oldest first
+	idle []*Channel
+}
+
+// all returns a snapshot of the registered channels
+func (self *registry) all() []*Channel {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return append([]*Channel{}, self.channels...)
+}
+
+// named returns the channels with the given name, one for each facade which
+// subscribed to it
+func (self *registry) named(name string) (channels []*Channel) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	for _, ch := range self.channels {
+		if ch.Name == name {
+			channels = append(channels, ch)
+		}
+	}
+	return
+}
+
+// find returns the channel subscribed by client with the given name, or nil
+func (self *registry) find(client *Client, name string) *Channel {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	for _, ch := range self.channels {
+		if ch.Name == name && ch.client == client {
+			return ch
+		}
+	}
+	return nil
+}
+
+// owned returns the channels subscribed by client
+func (self *registry) owned(client *Client) (channels []*Channel) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	for _, ch := range self.channels {
+		if ch.client == client {
+			channels = append(channels, ch)
+		}
+	}
+	return
+}
+
+// obtain returns the channel subscribed by client with the given name,
+// registering the one made by create if there is none, so that concurrent
+// subscriptions to a name share one channel
+func (self *registry) obtain(client *Client, name string, create func() *Channel) *Channel {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, ch := range self.channels {
+		if ch.Name == name && ch.client == client {
+			return ch
+		}
+	}
+	channel := create()
+	self.channels = append(self.channels, channel)
+	return channel
+}
+
+// add registers a channel, unless it is already
+func (self *registry) add(channel *Channel) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, ch := range self.channels {
+		if ch == channel {
+			return
+		}
+	}
+	self.channels = append(self.channels, channel)
+}
+
+// setIdle adds a channel to the end of the idle list
+func (self *registry) setIdle(channel *Channel) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.idle = append(self.idle, channel)
+}
+
+// unidle removes a channel from the idle list, returning whether it was idle
+func (self *registry) unidle(channel *Channel) bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for i, ch := range self.idle {
+		if ch == channel {
+			self.idle = append(self.idle[:i], self.idle[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// remove unregisters a channel
+func (self *registry) remove(channel *Channel) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for i, ch := range self.channels {
+		if ch == channel {
+			self.channels = append(self.channels[:i], self.channels[i+1:]...)
+			break
+		}
+	}
+	for i, ch := range self.idle {
+		if ch == channel {
+			self.idle = append(self.idle[:i], self.idle[i+1:]...)
+			break
+		}
+	}
+}
+
+// evict removes the oldest idle channel if there are more than max, and
+// returns it
+func (self *registry) evict(max int) *Channel {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if len(self.idle) <= max {
+		return nil
+	}
+
+	channel := self.idle[0]
+	self.idle = self.idle[1:]
+	for i, ch := range self.channels {
+		if ch == channel {
+			self.channels = append(self.channels[:i], self.channels[i+1:]...)
+			break
+		}
+	}
+	return channel
+}
+
+// Channels returns the channels subscribed with this client, including
+// unsubscribed channels whose bindings are retained. It is safe to call from
+// any goroutine.
+func (self *Client) Channels() []*Channel {
+	return self.loop.channels.owned(self)
+}
+
+// FindChannel returns the channel with the given name subscribed with this
+// client, or nil. It is safe to call from any goroutine.
+func (self *Client) FindChannel(name string) *Channel {
+	return self.loop.channels.find(self, name)
+}
@@ -0,0 +1,276 @@
+//go:build !pusher_minimal
+
+package pusher
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	s "strings"
+	"time"
+)
+
+// Default time allowed for a request to the HTTP API
+const defaultAPITimeout = 10 * time.Second
+
+// Pusher accepts an event on at most this many channels at once
+const maxTriggerChannels = 100
+
+// ServerClient publishes events through the Pusher HTTP API, so that a
+// service can publish server-side as well as consume events with a Client:
+//
+//	server := pusher.NewServerClient(appID, key, secret, "eu")
+//	err := server.Trigger("orders", "created", order)
+type ServerClient struct {
+	AppID  string
+	Key    string
+	Secret string
+	// Cluster selects the API host api-{cluster}.pusher.com, unless Host is
+	// set
+	Cluster string
+	// Host is the API host, with any port, e.g. for a self-hosted server
+	Host string
+	// Insecure sends requests over plain HTTP
+	Insecure bool
+	Client   *http.Client
+	// Timeout bounds each request. Zero means the 10s default.
+	Timeout time.Duration
+	// Crypto replaces the default in-process use of Secret for signing
+	// requests
+	Crypto CryptoProvider
+}
+
+func NewServerClient(appID, key, secret, cluster string) *ServerClient {
+	return &ServerClient{
+		AppID:   appID,
+		Key:     key,
+		Secret:  secret,
+		Cluster: cluster,
+		Client:  http.DefaultClient,
+	}
+}
+
+// Trigger publishes an event on a channel. Data which is not a string is sent
+// as JSON.
+func (self *ServerClient) Trigger(channel, event string, data interface{}) error {
+	return self.trigger([]string{channel}, event, data, "")
+}
+
+// TriggerMulti publishes an event on up to 100 channels at once
+func (self *ServerClient) TriggerMulti(channels []string, event string, data interface{}) error {
+	return self.trigger(channels, event, data, "")
+}
+
+// TriggerExclusive publishes an event on a channel to every connection except
+// the one with the given socket ID, usually the one whose action caused it
+func (self *ServerClient) TriggerExclusive(channel, event string, data interface{}, socketID string) error {
+	return self.trigger([]string{channel}, event, data, socketID)
+}
+
+func (self *ServerClient) trigger(channels []string, event string, data interface{}, socketID string) error {
+	if len(channels) == 0 || len(channels) > maxTriggerChannels {
+		return fmt.Errorf("pusher: events must be triggered on 1 to %v channels", maxTriggerChannels)
+	}
+	payload, ok := data.(string)
+	if !ok {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		payload = string(encoded)
+	}
+
+	body := map[string]interface{}{
+		"name":     event,
+		"channels": channels,
+		"data":     payload,
+	}
+	if socketID != "" {
+		body["socket_id"] = socketID
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	_, err = self.request("POST", "/apps/"+self.AppID+"/events", nil, encoded)
+	return err
+}
+
+// Attributes which GetChannels and GetChannel can include in ChannelInfo
+const (
+	InfoUserCount         = "user_count"
+	InfoSubscriptionCount = "subscription_count"
+)
+
+// ChannelInfo describes the occupancy of a channel. UserCount, for presence
+// channels only, and SubscriptionCount are only set when requested, and the
+// latter must be enabled for the application.
+type ChannelInfo struct {
+	Occupied          bool `json:"occupied"`
+	UserCount         int  `json:"user_count"`
+	SubscriptionCount int  `json:"subscription_count"`
+}
+
+// GetChannels returns the occupied channels whose names start with prefix,
+// which may be empty, with the requested info attributes
+func (self *ServerClient) GetChannels(prefix string, info ...string) (map[string]ChannelInfo, error) {
+	params := url.Values{}
+	if prefix != "" {
+		params.Set("filter_by_prefix", prefix)
+	}
+	if len(info) > 0 {
+		params.Set("info", s.Join(info, ","))
+	}
+	body, err := self.request("GET", "/apps/"+self.AppID+"/channels", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Channels map[string]ChannelInfo `json:"channels"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("pusher: invalid channels response: %w", err)
+	}
+	for name, channel := range response.Channels {
+		channel.Occupied = true
+		response.Channels[name] = channel
+	}
+	return response.Channels, nil
+}
+
+// GetChannel returns the state of a channel, with the requested info
+// attributes
+func (self *ServerClient) GetChannel(name string, info ...string) (*ChannelInfo, error) {
+	params := url.Values{}
+	if len(info) > 0 {
+		params.Set("info", s.Join(info, ","))
+	}
+	body, err := self.request("GET", "/apps/"+self.AppID+"/channels/"+name, params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := &ChannelInfo{}
+	if err := json.Unmarshal(body, channel); err != nil {
+		return nil, fmt.Errorf("pusher: invalid channel response: %w", err)
+	}
+	return channel, nil
+}
+
+// request makes a signed request to the HTTP API, returning the response body
+func (self *ServerClient) request(method, path string, params url.Values, body []byte) ([]byte, error) {
+	if self.AppID == "" || self.Key == "" {
+		return nil, errors.New("pusher: ServerClient needs an AppID and Key")
+	}
+
+	query, err := self.sign(method, path, params, body, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := self.Timeout
+	if timeout <= 0 {
+		timeout = defaultAPITimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		reader = s.NewReader(string(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, self.baseURL()+path+"?"+query.Encode(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := self.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	response, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pusher: API returned %v: %s", res.Status, s.TrimSpace(string(response)))
+	}
+	return response, nil
+}
+
+// sign returns the query string parameters of a request made at timestamp,
+// including its authentication signature
+func (self *ServerClient) sign(method, path string, params url.Values, body []byte, timestamp int64) (url.Values, error) {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("auth_key", self.Key)
+	query.Set("auth_timestamp", fmt.Sprint(timestamp))
+	query.Set("auth_version", "1.0")
+	if body != nil {
+		sum := md5.Sum(body)
+		query.Set("body_md5", hex.EncodeToString(sum[:]))
+	}
+	signature, err := self.crypto().Sign(method + "\n" + path + "\n" + unescapedQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	query.Set("auth_signature", signature)
+	return query, nil
+}
+
+func (self *ServerClient) baseURL() string {
+	scheme := "https"
+	if self.Insecure {
+		scheme = "http"
+	}
+	host := self.Host
+	if host == "" {
+		host = "api.pusherapp.com"
+		if self.Cluster != "" {
+			host = "api-" + self.Cluster + ".pusher.com"
+		}
+	}
+	return scheme + "://" + host
+}
+
+func (self *ServerClient) crypto() CryptoProvider {
+	if self.Crypto != nil {
+		return self.Crypto
+	}
+	return secretCrypto{secret: self.Secret}
+}
+
+// unescapedQuery returns the query string which is signed: parameters sorted
+// by name and not escaped
+func unescapedQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+query.Get(name))
+	}
+	return s.Join(pairs, "&")
+}
@@ -0,0 +1,165 @@
+//go:build !pusher_minimal
+
+package pusher
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	s "strings"
+	"testing"
+)
+
+// TestSignPublishedExample signs the request of the example in Pusher's
+// documentation of the HTTP API's authentication
+func TestSignPublishedExample(t *testing.T) {
+	server := NewServerClient("3", "278d425bdf160c739803", "7ad3773142a6692b25b8", "")
+	body := []byte(`{"name":"foo","channels":["project-3"],"data":"{\"some\":\"data\"}"}`)
+	query, err := server.sign("POST", "/apps/3/events", nil, body, 1353088179)
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	if bodyMD5 := query.Get("body_md5"); bodyMD5 != "ec365a775a4cd0599faeb73354201b6f" {
+		t.Fatalf("body_md5 is %v", bodyMD5)
+	}
+	if signature := query.Get("auth_signature"); signature != "da454824c97ba181a32ccc17a72625ba02771f50b50e1e7430e47a1f3f457e6c" {
+		t.Fatalf("auth_signature is %v", signature)
+	}
+}
+
+// recordingCrypto signs with a secret held outside the client, recording
+// what it signs
+type recordingCrypto struct {
+	secretCrypto
+	signed *[]string
+}
+
+func (self recordingCrypto) Sign(stringToSign string) (string, error) {
+	*self.signed = append(*self.signed, stringToSign)
+	return self.secretCrypto.Sign(stringToSign)
+}
+
+// TestCryptoProvider signs the published example through a provider, in
+// place of the ServerClient's own secret
+func TestCryptoProvider(t *testing.T) {
+	var signed []string
+	server := NewServerClient("3", "278d425bdf160c739803", "", "")
+	server.Crypto = recordingCrypto{secretCrypto{secret: "7ad3773142a6692b25b8"}, &signed}
+	query, err := server.sign("POST", "/apps/3/events", nil, []byte(`{"name":"foo","channels":["project-3"],"data":"{\"some\":\"data\"}"}`), 1353088179)
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	if signature := query.Get("auth_signature"); signature != "da454824c97ba181a32ccc17a72625ba02771f50b50e1e7430e47a1f3f457e6c" {
+		t.Fatalf("auth_signature is %v", signature)
+	}
+	if len(signed) != 1 {
+		t.Fatalf("signed %v", signed)
+	}
+}
+
+// newAPIServer returns a ServerClient of a fake HTTP API served by handler
+func newAPIServer(t *testing.T, handler http.HandlerFunc) *ServerClient {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	server := NewServerClient("3", "key", "secret", "")
+	server.Host = s.TrimPrefix(srv.URL, "http://")
+	server.Insecure = true
+	return server
+}
+
+func TestTrigger(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := md5.Sum(body)
+		query := r.URL.Query()
+		switch {
+		case r.Method != "POST" || r.URL.Path != "/apps/3/events":
+			t.Errorf("request to %v %v", r.Method, r.URL.Path)
+		case r.Header.Get("Content-Type") != "application/json":
+			t.Errorf("request has content type %v", r.Header.Get("Content-Type"))
+		case query.Get("auth_key") != "key" || query.Get("auth_signature") == "":
+			t.Errorf("request is not signed: %v", r.URL.RawQuery)
+		case query.Get("body_md5") != hex.EncodeToString(sum[:]):
+			t.Errorf("body_md5 %v does not match the body", query.Get("body_md5"))
+		}
+		var event map[string]interface{}
+		json.Unmarshal(body, &event)
+		if event["name"] != "created" || event["data"] != `{"id":1}` || event["socket_id"] != "1.1" {
+			t.Errorf("unexpected event %s", body)
+		}
+		w.Write([]byte("{}"))
+	})
+	if err := server.TriggerExclusive("orders", "created", map[string]int{"id": 1}, "1.1"); err != nil {
+		t.Fatalf("triggering: %v", err)
+	}
+}
+
+func TestTriggerRejected(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+	})
+	err := server.Trigger("orders", "created", "{}")
+	if err == nil || !s.Contains(err.Error(), "401") || !s.Contains(err.Error(), "Invalid signature") {
+		t.Fatalf("expected the API's error, got %v", err)
+	}
+}
+
+func TestGetChannels(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.Method != "GET" || r.URL.Path != "/apps/3/channels" {
+			t.Errorf("request to %v %v", r.Method, r.URL.Path)
+		}
+		if query.Get("filter_by_prefix") != "presence-" || query.Get("info") != "user_count,subscription_count" {
+			t.Errorf("unexpected query %v", r.URL.RawQuery)
+		}
+		if query.Has("body_md5") {
+			t.Error("GET request signed with a body_md5")
+		}
+		w.Write([]byte(`{"channels":{"presence-a":{"user_count":3,"subscription_count":4},"presence-b":{}}}`))
+	})
+	channels, err := server.GetChannels("presence-", InfoUserCount, InfoSubscriptionCount)
+	if err != nil {
+		t.Fatalf("getting channels: %v", err)
+	}
+	expected := map[string]ChannelInfo{
+		"presence-a": {Occupied: true, UserCount: 3, SubscriptionCount: 4},
+		"presence-b": {Occupied: true},
+	}
+	if len(channels) != len(expected) {
+		t.Fatalf("got channels %+v", channels)
+	}
+	for name, info := range expected {
+		if channels[name] != info {
+			t.Fatalf("got %+v for %v, expected %+v", channels[name], name, info)
+		}
+	}
+}
+
+func TestGetChannel(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apps/3/channels/orders" || r.URL.Query().Get("info") != "subscription_count" {
+			t.Errorf("unexpected request to %v", r.URL)
+		}
+		w.Write([]byte(`{"occupied":true,"subscription_count":2}`))
+	})
+	channel, err := server.GetChannel("orders", InfoSubscriptionCount)
+	if err != nil {
+		t.Fatalf("getting channel: %v", err)
+	}
+	if *channel != (ChannelInfo{Occupied: true, SubscriptionCount: 2}) {
+		t.Fatalf("got %+v", *channel)
+	}
+}
+
+func TestGetChannelInvalidResponse(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`not json`))
+	})
+	if _, err := server.GetChannel("orders"); err == nil || !s.Contains(err.Error(), "invalid channel response") {
+		t.Fatalf("expected an invalid response error, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package pusher
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Scrubber masks personal data in event payloads. It receives the channel,
+// event name and data of each event, and returns the data to use in its place.
+// Presence events carry member data as JSON, which must remain valid.
+type Scrubber func(channel, event, data string) string
+
+// scrub applies the configured scrubber to a received event, before it is
+// logged or dispatched. Connection level pusher: events carry no payload
+// data, and are left alone.
+func scrub(scrubber Scrubber, event *Event) {
+	if scrubber == nil || strings.HasPrefix(event.Name, "pusher:") {
+		return
+	}
+	event.Data = scrubber(event.Channel, event.Name, event.Data)
+}
+
+// RedactFields returns a Scrubber which replaces the values of the named
+// fields, at any depth of JSON event data, with "[redacted]". Data which is
+// not JSON is left alone. It is registered as the "redact" transformer, with
+// its fields configured as {"fields": ["email", "phone"]}.
+func RedactFields(fields ...string) Scrubber {
+	redacted := map[string]bool{}
+	for _, field := range fields {
+		redacted[field] = true
+	}
+	return func(channel, event, data string) string {
+		var value interface{}
+		if err := json.Unmarshal([]byte(data), &value); err != nil {
+			return data
+		}
+		encoded, err := json.Marshal(redact(value, redacted))
+		if err != nil {
+			return data
+		}
+		return string(encoded)
+	}
+}
+
+func redact(value interface{}, fields map[string]bool) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if fields[key] {
+				value[key] = "[redacted]"
+			} else {
+				value[key] = redact(field, fields)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redact(item, fields)
+		}
+	}
+	return value
+}
+
+func init() {
+	Transformers.Register("redact", func(config json.RawMessage) (Scrubber, error) {
+		var options struct {
+			Fields []string `json:"fields"`
+		}
+		if err := json.Unmarshal(config, &options); err != nil {
+			return nil, err
+		}
+		return RedactFields(options.Fields...), nil
+	})
+}
+
+// scrubMessage returns an outgoing message with its data scrubbed, for
+// logging. Only the data is replaced: other fields are kept as they are, and
+// data sent as an object stays an object.
+func scrubMessage(scrubber Scrubber, msg []byte) string {
+	if scrubber == nil {
+		return string(msg)
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &payload); err != nil {
+		return "<unparseable message>"
+	}
+	raw, ok := payload["data"]
+	if !ok {
+		return string(msg)
+	}
+	var name, channel string
+	json.Unmarshal(payload["event"], &name)
+	json.Unmarshal(payload["channel"], &channel)
+
+	// Data is either a string, usually holding JSON, or JSON itself
+	var data string
+	quoted := json.Unmarshal(raw, &data) == nil
+	if !quoted {
+		data = string(raw)
+	}
+	event := Event{Name: name, Channel: channel, Data: data}
+	scrub(scrubber, &event)
+
+	if !quoted && json.Valid([]byte(event.Data)) {
+		payload["data"] = json.RawMessage(event.Data)
+	} else {
+		payload["data"], _ = json.Marshal(event.Data)
+	}
+	scrubbed, _ := json.Marshal(payload)
+	return string(scrubbed)
+}
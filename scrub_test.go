@@ -0,0 +1,50 @@
+package pusher
+
+import (
+	"testing"
+)
+
+func TestRedactFields(t *testing.T) {
+	scrubber := RedactFields("email", "phone")
+	for data, expected := range map[string]string{
+		`{"email":"a@example.com","name":"a"}`:                     `{"email":"[redacted]","name":"a"}`,
+		`{"users":[{"phone":"123","id":1}],"meta":{"email":null}}`: `{"meta":{"email":"[redacted]"},"users":[{"id":1,"phone":"[redacted]"}]}`,
+		`not json`: `not json`,
+	} {
+		if scrubbed := scrubber("c", "e", data); scrubbed != expected {
+			t.Errorf("scrubbing %v gave %v, expected %v", data, scrubbed, expected)
+		}
+	}
+}
+
+func TestScrubSkipsProtocolEvents(t *testing.T) {
+	scrubber := func(channel, event, data string) string { return "scrubbed" }
+	event := Event{Name: "pusher:connection_established", Data: `{"socket_id":"1.2"}`}
+	scrub(scrubber, &event)
+	if event.Data != `{"socket_id":"1.2"}` {
+		t.Fatalf("scrubbed a protocol event: %v", event.Data)
+	}
+	event = Event{Name: "update", Channel: "c", Data: "personal"}
+	scrub(scrubber, &event)
+	if event.Data != "scrubbed" {
+		t.Fatalf("did not scrub an event: %v", event.Data)
+	}
+}
+
+func TestScrubMessage(t *testing.T) {
+	scrubber := RedactFields("email")
+	for msg, expected := range map[string]string{
+		// Data sent as a string stays a string, and as an object an object
+		`{"event":"client-x","channel":"private-c","data":"{\"email\":\"a\"}"}`: `{"channel":"private-c","data":"{\"email\":\"[redacted]\"}","event":"client-x"}`,
+		`{"event":"client-x","channel":"private-c","data":{"email":"a"}}`:       `{"channel":"private-c","data":{"email":"[redacted]"},"event":"client-x"}`,
+		`{"event":"pusher:ping"}`: `{"event":"pusher:ping"}`,
+		`not json`:                `<unparseable message>`,
+	} {
+		if scrubbed := scrubMessage(scrubber, []byte(msg)); scrubbed != expected {
+			t.Errorf("scrubbing %v gave %v, expected %v", msg, scrubbed, expected)
+		}
+	}
+	if scrubbed := scrubMessage(nil, []byte("not json")); scrubbed != "not json" {
+		t.Errorf("scrubbed without a scrubber: %v", scrubbed)
+	}
+}
@@ -0,0 +1,108 @@
+package pusher
+
+import (
+	"fmt"
+	"time"
+)
+
+type shutdownRequest struct {
+	timeout time.Duration
+	result  chan error
+}
+
+// Shutdown disconnects gracefully: it unsubscribes from all channels and
+// waits up to timeout for the unsubscriptions, and any client events still
+// queued, to be sent before closing the connection. It returns
+// ErrShutdownTimeout if the connection had to be closed before then, or
+// ErrShutdownClosed wrapped with the close error if it closed first.
+//
+// Shutting down a facade only unsubscribes its channels, like Disconnect.
+// ManualPoll clients shut down during the next RunOnce or Poll, so Shutdown
+// returns nil at once.
+func (self *Client) Shutdown(timeout time.Duration) error {
+	if self.parent != nil {
+		self.Disconnect()
+		return nil
+	}
+	if state := self.State(); state == StateDisconnected {
+		return ErrDisconnected
+	}
+
+	if self.ManualPoll {
+		if !send(self.loop, self._shutdown, shutdownRequest{timeout: timeout}) {
+			return ErrDisconnected
+		}
+		return nil
+	}
+	result := make(chan error, 1)
+	if !send(self.loop, self._shutdown, shutdownRequest{timeout: timeout, result: result}) {
+		return ErrDisconnected
+	}
+	return <-result
+}
+
+func (self *Client) handleShutdown(request shutdownRequest) {
+	var err error
+	if self.conn != nil {
+		err = self.flushAndClose(request.timeout)
+		self.conn = nil
+	}
+	for _, ch := range self.loop.channels.all() {
+		ch.setUnsubscribed()
+	}
+	self.stop()
+
+	if request.result != nil {
+		request.result <- err
+	}
+}
+
+// flushAndClose unsubscribes from every subscribed channel and closes the
+// connection once the queued messages have been sent, or timeout has passed
+func (self *Client) flushAndClose(timeout time.Duration) error {
+	var messages [][]byte
+	unsubscribed := map[string]bool{}
+	for _, ch := range self.loop.channels.all() {
+		if ch.IsSubscribed() && !unsubscribed[ch.Name] {
+			message, err := self.intercept(OutgoingMessage{
+				Event: "pusher:unsubscribe",
+				Data:  map[string]string{"channel": ch.Name},
+			})
+			if err == nil && message != nil {
+				messages = append(messages, message)
+			}
+			unsubscribed[ch.Name] = true
+		}
+	}
+
+	done := self.conn.Shutdown(messages)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case <-done:
+			self.logger().Infof("Shut down gracefully")
+			return nil
+		case <-self.loop.onMessage:
+			// Keep the connection from blocking on delivery while it
+			// flushes; events arriving now are dropped
+		case err := <-self.loop.onClose:
+			self.conn.Close()
+			select {
+			case <-done:
+				self.logger().Infof("Shut down gracefully")
+				return nil
+			default:
+			}
+			if err == nil {
+				return ErrShutdownClosed
+			}
+			return fmt.Errorf("%w: %w", ErrShutdownClosed, err)
+		case <-deadline.C:
+			self.logger().Errorf("Shutdown timed out, closing")
+			self.conn.Close()
+			return ErrShutdownTimeout
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package pusher_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// flushingConn is a connection whose Shutdown finishes only once flushed is
+// closed, reporting when it starts
+type flushingConn struct {
+	fakeConn
+	shutdown chan struct{}
+	flushed  chan struct{}
+}
+
+func (self flushingConn) Shutdown(messages [][]byte) <-chan struct{} {
+	close(self.shutdown)
+	return self.flushed
+}
+
+// TestShutdownClosedByServer closes the connection while Shutdown is
+// flushing it, which must be reported as such rather than as a timeout, unless
+// the flush had completed
+func TestShutdownClosedByServer(t *testing.T) {
+	for _, flushed := range []bool{false, true} {
+		conn := flushingConn{shutdown: make(chan struct{}), flushed: make(chan struct{})}
+		conns := make(chan pusher.ConnCallbacks, 1)
+		client := pusher.NewWithConfig(pusher.ClientConfig{
+			NewConn: func(c pusher.ClientConfig, transport string, callbacks pusher.ConnCallbacks) (pusher.Conn, error) {
+				conns <- callbacks
+				return conn, nil
+			},
+		})
+		callbacks := nextConn(t, conns)
+		callbacks.OnMessage <- established
+
+		result := make(chan error, 1)
+		go func() { result <- client.Shutdown(2 * time.Second) }()
+		<-conn.shutdown
+		closeErr := &pusher.ConnectionError{Code: 4200, Message: "reconnect"}
+		if flushed {
+			// Shutdown may return on the flush before it reads the close,
+			// after which nothing is left to receive it
+			close(conn.flushed)
+			select {
+			case callbacks.OnClose <- closeErr:
+			case <-time.After(100 * time.Millisecond):
+			}
+		} else {
+			callbacks.OnClose <- closeErr
+		}
+
+		select {
+		case err := <-result:
+			if flushed && err != nil {
+				t.Fatalf("Shutdown returned %v after the flush completed", err)
+			}
+			if !flushed && (!errors.Is(err, pusher.ErrShutdownClosed) || !errors.Is(err, closeErr)) {
+				t.Fatalf("expected ErrShutdownClosed with the close error, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Shutdown waited for its timeout after the connection closed")
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package jsonl archives received events to JSON lines files, rotating and
+// optionally compressing them as they grow or age.
+package jsonl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	s "strings"
+	"sync"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/sink"
+)
+
+// Format of the timestamp inserted into rotated file names
+const rotateFormat = "20060102T150405.000"
+
+func init() {
+	sink.Publishers.Register("jsonl", func(config json.RawMessage) (sink.Publisher, error) {
+		var c struct {
+			Path     string `json:"path"`
+			MaxSize  int64  `json:"max_size"`
+			MaxAge   string `json:"max_age"`
+			Compress bool   `json:"compress"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		writer := NewWriter(c.Path)
+		writer.MaxSize = c.MaxSize
+		writer.Compress = c.Compress
+		if c.MaxAge != "" {
+			age, err := time.ParseDuration(c.MaxAge)
+			if err != nil {
+				return nil, err
+			}
+			writer.MaxAge = age
+		}
+		return writer, nil
+	})
+}
+
+// Record is the JSON line written for each event
+type Record struct {
+	Time    time.Time `json:"time"`
+	Channel string    `json:"channel"`
+	Event   string    `json:"event"`
+	Data    string    `json:"data"`
+	UserId  string    `json:"user_id,omitempty"`
+}
+
+// Encode encodes an event as a Record stamped with the current time. It is
+// the sink's Encode function, so runs as the event is received.
+func Encode(event pusher.Event) ([]byte, error) {
+	return json.Marshal(Record{
+		Time:    time.Now().UTC(),
+		Channel: event.Channel,
+		Event:   event.Name,
+		Data:    event.Data,
+		UserId:  event.UserId,
+	})
+}
+
+// New returns a sink archiving events to a Writer on path
+func New(client *pusher.Client, path string) (*sink.Sink, *Writer) {
+	writer := NewWriter(path)
+	archive := sink.New(client, writer)
+	archive.Encode = Encode
+	return archive, writer
+}
+
+// Writer is a sink.Publisher appending each message to a file as one line.
+// When the file exceeds MaxSize bytes or was started more than MaxAge ago it
+// is renamed with a timestamp, e.g. events-20060102T150405.000.jsonl, and a
+// new file is started. A counter is appended to the timestamp, as in
+// events-20060102T150405.000-1.jsonl, if that name is already taken.
+//
+// A file which already exists is appended to, and its age is taken from the
+// Time of its first Record, or from its modification time when that line is
+// not a Record. Rotation is checked as messages are written, so a file which
+// comes due while no events arrive is rotated with the next message.
+type Writer struct {
+	Path string
+	// MaxSize is the size in bytes after which the file is rotated. Zero
+	// disables size-based rotation.
+	MaxSize int64
+	// MaxAge is the time after which the file is rotated. Zero disables
+	// time-based rotation.
+	MaxAge time.Duration
+	// Compress gzips rotated files in the background
+	Compress bool
+	// OnError is called when compressing a rotated file fails
+	OnError func(error)
+
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	started  time.Time
+	compress sync.WaitGroup
+}
+
+func NewWriter(path string) *Writer {
+	return &Writer{Path: path}
+}
+
+func (self *Writer) Publish(ctx context.Context, messages []sink.Message) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for _, message := range messages {
+		if err := self.rotate(); err != nil {
+			return err
+		}
+		n, err := self.file.Write(append(message.Value, '\n'))
+		self.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the current file and waits for rotated files to be compressed
+func (self *Writer) Close() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	var err error
+	if self.file != nil {
+		err = self.file.Close()
+		self.file = nil
+	}
+	self.compress.Wait()
+	return err
+}
+
+// rotate opens the file if necessary, rotating it if it is due
+func (self *Writer) rotate() error {
+	if self.file == nil {
+		if err := self.open(); err != nil {
+			return err
+		}
+	}
+	full := self.MaxSize > 0 && self.size >= self.MaxSize
+	old := self.MaxAge > 0 && time.Since(self.started) >= self.MaxAge
+	if !full && !old {
+		return nil
+	}
+	if err := self.file.Close(); err != nil {
+		return err
+	}
+	self.file = nil
+
+	rotated := self.rotatedPath(time.Now())
+	if err := os.Rename(self.Path, rotated); err != nil {
+		return err
+	}
+	if self.Compress {
+		self.compress.Add(1)
+		go func() {
+			defer self.compress.Done()
+			if err := compress(rotated); err != nil && self.OnError != nil {
+				self.OnError(err)
+			}
+		}()
+	}
+	return self.open()
+}
+
+// open opens the file for appending, creating it if necessary
+func (self *Writer) open() error {
+	file, err := os.OpenFile(self.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	self.file = file
+	self.size = info.Size()
+	self.started = time.Now()
+	if self.size > 0 {
+		self.started = started(self.Path, info.ModTime())
+	}
+	return nil
+}
+
+// started returns the time of the first record of an existing file, or
+// modified if it has none
+func started(path string, modified time.Time) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return modified
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadBytes('\n')
+	var record Record
+	if json.Unmarshal(line, &record) != nil || record.Time.IsZero() {
+		return modified
+	}
+	return record.Time
+}
+
+// rotatedPath returns the name to rotate the file to, adding a counter when
+// a file rotated within the same millisecond, or its compressed copy, would
+// otherwise be overwritten
+func (self *Writer) rotatedPath(now time.Time) string {
+	ext := filepath.Ext(self.Path)
+	base := s.TrimSuffix(self.Path, ext) + "-" + now.UTC().Format(rotateFormat)
+	path := base + ext
+	for i := 1; exists(path) || exists(path+".gz"); i++ {
+		path = base + "-" + strconv.Itoa(i) + ext
+	}
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// compress replaces path with a gzipped copy at path.gz
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
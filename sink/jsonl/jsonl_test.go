@@ -0,0 +1,209 @@
+package jsonl_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/sink"
+	"github.com/mnaser/pusher-websocket-go/sink/jsonl"
+)
+
+func message(value string) []sink.Message {
+	return []sink.Message{{Topic: "orders", Value: []byte(value)}}
+}
+
+// lines returns the lines of a file, decompressing it if gzipped
+func lines(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var scanner *bufio.Scanner
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner = bufio.NewScanner(gz)
+	} else {
+		scanner = bufio.NewScanner(file)
+	}
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// rotated returns the rotated files beside path, oldest first
+func rotated(t *testing.T, path string) []string {
+	t.Helper()
+	files, err := filepath.Glob(strings.TrimSuffix(path, ".jsonl") + "-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestEncode(t *testing.T) {
+	encoded, err := jsonl.Encode(pusher.Event{Name: "created", Channel: "orders", Data: `{"id":1}`, UserId: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record jsonl.Record
+	if err := json.Unmarshal(encoded, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Channel != "orders" || record.Event != "created" || record.Data != `{"id":1}` || record.UserId != "alice" || time.Since(record.Time) > time.Minute {
+		t.Fatalf("unexpected record %+v", record)
+	}
+}
+
+// TestRotateSize rotates the file once it reaches MaxSize, never overwriting
+// a file rotated within the same millisecond
+func TestRotateSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	writer := jsonl.NewWriter(path)
+	writer.MaxSize = 4
+	for _, value := range []string{"1", "22", "333", "4"} {
+		if err := writer.Publish(context.Background(), message(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := rotated(t, path)
+	if len(files) != 2 {
+		t.Fatalf("expected two rotated files, got %v", files)
+	}
+	var contents [][]string
+	for _, file := range files {
+		contents = append(contents, lines(t, file))
+	}
+	contents = append(contents, lines(t, path))
+	sort.Slice(contents, func(i, j int) bool { return contents[i][0] < contents[j][0] })
+	if len(contents[0]) != 2 || contents[0][1] != "22" || contents[1][0] != "333" || contents[2][0] != "4" {
+		t.Fatalf("unexpected contents %v", contents)
+	}
+}
+
+// TestRotateAge rotates the file once it is older than MaxAge, compressing
+// the rotated file
+func TestRotateAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	writer := jsonl.NewWriter(path)
+	writer.MaxAge = 10 * time.Millisecond
+	writer.Compress = true
+	writer.OnError = func(err error) { t.Error(err) }
+	if err := writer.Publish(context.Background(), message("old")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := writer.Publish(context.Background(), message("new")); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	files := rotated(t, path)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".jsonl.gz") {
+		t.Fatalf("expected one compressed file, got %v", files)
+	}
+	if old := lines(t, files[0]); len(old) != 1 || old[0] != "old" {
+		t.Fatalf("unexpected rotated contents %v", old)
+	}
+	if current := lines(t, path); len(current) != 1 || current[0] != "new" {
+		t.Fatalf("unexpected current contents %v", current)
+	}
+}
+
+// TestAppend reopens an existing file, counting its size towards MaxSize
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writer := jsonl.NewWriter(path)
+	writer.MaxSize = 10
+	for _, value := range []string{"a", "b"} {
+		if err := writer.Publish(context.Background(), message(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writer.Close()
+
+	files := rotated(t, path)
+	if len(files) != 1 {
+		t.Fatalf("expected one rotated file, got %v", files)
+	}
+	if old := lines(t, files[0]); len(old) != 2 || old[0] != "existing" || old[1] != "a" {
+		t.Fatalf("unexpected rotated contents %v", old)
+	}
+}
+
+// TestAppendAge rotates an existing file by the age of its first record,
+// falling back to its modification time, before writing to it
+func TestAppendAge(t *testing.T) {
+	dir := t.TempDir()
+	hourAgo := time.Now().Add(-time.Hour)
+	first, _ := json.Marshal(jsonl.Record{Time: hourAgo, Channel: "orders", Event: "created"})
+	// old.jsonl was just modified, but its first record is an hour old
+	for name, contents := range map[string]string{
+		"old.jsonl":    string(first) + "\n",
+		"recent.jsonl": `{"time":"` + time.Now().Format(time.RFC3339Nano) + `"}` + "\n",
+		"custom.jsonl": "not a record\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// custom.jsonl holds no record, so is judged by its modification time
+	if err := os.Chtimes(filepath.Join(dir, "custom.jsonl"), hourAgo, hourAgo); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]int{"old.jsonl": 1, "recent.jsonl": 0, "custom.jsonl": 1} {
+		path := filepath.Join(dir, name)
+		writer := jsonl.NewWriter(path)
+		writer.MaxAge = time.Minute
+		if err := writer.Publish(context.Background(), message("new")); err != nil {
+			t.Fatal(err)
+		}
+		writer.Close()
+		if files := rotated(t, path); len(files) != expected {
+			t.Fatalf("%v: expected %d rotated files, got %v", name, expected, files)
+		}
+		if current := lines(t, path); expected == 1 && (len(current) != 1 || current[0] != "new") {
+			t.Fatalf("%v: expected the new message in a new file, got %v", name, current)
+		}
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	config, _ := json.Marshal(map[string]interface{}{"path": path, "max_size": 10, "max_age": "1h", "compress": true})
+	publisher, err := sink.Publishers.New("jsonl", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := publisher.(*jsonl.Writer)
+	if writer.Path != path || writer.MaxSize != 10 || writer.MaxAge != time.Hour || !writer.Compress {
+		t.Fatalf("unexpected writer %+v", writer)
+	}
+	if _, err := sink.Publishers.New("jsonl", []byte(`{"max_age":"soon"}`)); err == nil {
+		t.Fatal("expected an invalid max_age to be rejected")
+	}
+}
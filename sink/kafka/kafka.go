@@ -0,0 +1,79 @@
+// Package kafka publishes sink messages to Kafka topics
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	kafka "github.com/segmentio/kafka-go"
+
+	"github.com/mnaser/pusher-websocket-go/sink"
+)
+
+func init() {
+	sink.Publishers.Register("kafka", func(config json.RawMessage) (sink.Publisher, error) {
+		var c struct {
+			Brokers []string `json:"brokers"`
+			// RequiredAcks is "none", "one" or "all", the default
+			RequiredAcks *kafka.RequiredAcks `json:"required_acks"`
+			Topics       map[string]string   `json:"topics"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		writer := &kafka.Writer{Addr: kafka.TCP(c.Brokers...), RequiredAcks: kafka.RequireAll}
+		if c.RequiredAcks != nil {
+			writer.RequiredAcks = *c.RequiredAcks
+		}
+		publisher := NewPublisher(writer)
+		publisher.Topics = c.Topics
+		return publisher, nil
+	})
+}
+
+// Publisher writes each batch through a kafka.Writer. The writer must not
+// set Topic, as every message carries its own.
+type Publisher struct {
+	Writer *kafka.Writer
+	// Topics maps a message's topic, the channel name unless the sink's
+	// Topic says otherwise, to the Kafka topic it is written to. Topics
+	// missing from it are written to as they are.
+	Topics map[string]string
+}
+
+func NewPublisher(writer *kafka.Writer) *Publisher {
+	return &Publisher{Writer: writer}
+}
+
+func (self *Publisher) Publish(ctx context.Context, messages []sink.Message) error {
+	batch := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		topic := message.Topic
+		if mapped, ok := self.Topics[topic]; ok {
+			topic = mapped
+		}
+		batch[i] = kafka.Message{Topic: topic, Key: message.Key, Value: message.Value}
+	}
+
+	err := self.Writer.WriteMessages(ctx, batch...)
+	var writeErrs kafka.WriteErrors
+	if !errors.As(err, &writeErrs) {
+		return err
+	}
+	// The writer reports an error per message, nil for those delivered
+	var failed []sink.Message
+	var first error
+	for i, writeErr := range writeErrs {
+		if writeErr != nil {
+			if failed = append(failed, messages[i]); first == nil {
+				first = writeErr
+			}
+		}
+	}
+	return &sink.PublishError{
+		Failed: failed,
+		Err:    fmt.Errorf("kafka: %d of %d messages failed: %w", len(failed), len(messages), first),
+	}
+}
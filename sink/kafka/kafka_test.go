@@ -0,0 +1,144 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	metadata "github.com/segmentio/kafka-go/protocol/metadata"
+	produce "github.com/segmentio/kafka-go/protocol/produce"
+
+	"github.com/mnaser/pusher-websocket-go/sink"
+	kafkasink "github.com/mnaser/pusher-websocket-go/sink/kafka"
+)
+
+// unavailable is a transport which fails every request, recording the topics
+// whose metadata was requested
+type unavailable struct {
+	topics []string
+}
+
+var errUnavailable = errors.New("broker unavailable")
+
+func (self *unavailable) RoundTrip(ctx context.Context, addr net.Addr, request kafka.Request) (kafka.Response, error) {
+	if request, ok := request.(*metadata.Request); ok {
+		self.topics = append(self.topics, request.TopicNames...)
+	}
+	return nil, errUnavailable
+}
+
+func TestPublishError(t *testing.T) {
+	transport := &unavailable{}
+	publisher := kafkasink.NewPublisher(&kafka.Writer{Addr: kafka.TCP("localhost:9092"), Transport: transport, MaxAttempts: 1})
+	err := publisher.Publish(context.Background(), []sink.Message{
+		{Topic: "pusher.orders", Key: []byte("orders"), Value: []byte(`{"id":1}`)},
+	})
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the transport's error, got %v", err)
+	}
+	if len(transport.topics) != 1 || transport.topics[0] != "pusher.orders" {
+		t.Fatalf("expected the message's own topic to be looked up, got %v", transport.topics)
+	}
+}
+
+// refusing is a single broker cluster which refuses to produce to one topic
+type refusing struct {
+	refused string
+}
+
+func (self *refusing) RoundTrip(ctx context.Context, addr net.Addr, request kafka.Request) (kafka.Response, error) {
+	switch request := request.(type) {
+	case *metadata.Request:
+		response := &metadata.Response{Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "localhost", Port: 9092}}}
+		for _, topic := range request.TopicNames {
+			response.Topics = append(response.Topics, metadata.ResponseTopic{
+				Name:       topic,
+				Partitions: []metadata.ResponsePartition{{LeaderID: 1, ReplicaNodes: []int32{1}, IsrNodes: []int32{1}}},
+			})
+		}
+		return response, nil
+	case *produce.Request:
+		response := &produce.Response{}
+		for _, topic := range request.Topics {
+			if topic.Topic == self.refused {
+				return nil, errUnavailable
+			}
+			response.Topics = append(response.Topics, produce.ResponseTopic{
+				Topic:      topic.Topic,
+				Partitions: []produce.ResponsePartition{{}},
+			})
+		}
+		return response, nil
+	}
+	return nil, fmt.Errorf("unexpected %T", request)
+}
+
+func TestPartialFailure(t *testing.T) {
+	publisher := kafkasink.NewPublisher(&kafka.Writer{
+		Addr:        kafka.TCP("localhost:9092"),
+		Transport:   &refusing{refused: "refunds"},
+		MaxAttempts: 1,
+		// Flush at once rather than waiting for more messages
+		BatchTimeout: time.Millisecond,
+	})
+	messages := []sink.Message{
+		{Topic: "orders", Key: []byte("orders"), Value: []byte(`{"id":1}`)},
+		{Topic: "refunds", Key: []byte("refunds"), Value: []byte(`{"id":2}`)},
+		{Topic: "orders", Key: []byte("orders"), Value: []byte(`{"id":3}`)},
+	}
+	err := publisher.Publish(context.Background(), messages)
+	var publishErr *sink.PublishError
+	if !errors.As(err, &publishErr) {
+		t.Fatalf("expected a PublishError, got %v", err)
+	}
+	if len(publishErr.Failed) != 1 || string(publishErr.Failed[0].Value) != `{"id":2}` {
+		t.Fatalf("expected only the refused message to fail, got %+v", publishErr.Failed)
+	}
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the transport's error, got %v", err)
+	}
+
+	// Mapping the refused topic elsewhere delivers the whole batch
+	publisher.Topics = map[string]string{"refunds": "pusher.refunds"}
+	if err := publisher.Publish(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	publisher, err := sink.Publishers.New("kafka", []byte(`{"brokers": ["a:9092", "b:9092"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := publisher.(*kafkasink.Publisher).Writer
+	if addr := writer.Addr.String(); addr != "a:9092,b:9092" {
+		t.Fatalf("writer connects to %v", addr)
+	}
+	if writer.Topic != "" {
+		t.Fatalf("writer has topic %v, which messages carrying their own would conflict with", writer.Topic)
+	}
+	if writer.RequiredAcks != kafka.RequireAll {
+		t.Fatalf("writer requires %v acks, expected all", writer.RequiredAcks)
+	}
+
+	publisher, err = sink.Publishers.New("kafka", []byte(`{"brokers": ["a:9092"], "required_acks": "one", "topics": {"orders": "pusher.orders"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acks := publisher.(*kafkasink.Publisher).Writer.RequiredAcks; acks != kafka.RequireOne {
+		t.Fatalf("writer requires %v acks, expected one", acks)
+	}
+	if topic := publisher.(*kafkasink.Publisher).Topics["orders"]; topic != "pusher.orders" {
+		t.Fatalf("orders is mapped to %q", topic)
+	}
+	if _, err := sink.Publishers.New("kafka", []byte(`{"brokers": ["a:9092"], "required_acks": "some"}`)); err == nil {
+		t.Fatal("expected invalid acks to be rejected")
+	}
+	if _, err := sink.Publishers.New("kafka", []byte(`{"brokers": "a:9092"}`)); err == nil {
+		t.Fatal("expected invalid config to be rejected")
+	}
+}
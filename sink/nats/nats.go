@@ -0,0 +1,70 @@
+// Package nats publishes sink messages to NATS subjects
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+
+	"github.com/mnaser/pusher-websocket-go/sink"
+)
+
+// Default time allowed for the server to acknowledge a batch
+const DefaultFlushTimeout = 5 * time.Second
+
+func init() {
+	sink.Publishers.Register("nats", func(config json.RawMessage) (sink.Publisher, error) {
+		var c struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		if c.URL == "" {
+			c.URL = nats.DefaultURL
+		}
+		conn, err := nats.Connect(c.URL)
+		if err != nil {
+			return nil, err
+		}
+		return NewPublisher(conn), nil
+	})
+}
+
+// Publisher publishes each message on the subject given by its topic and
+// flushes once per batch. A message the connection refuses fails along with
+// the rest of the batch after it, while a failed flush fails the whole batch.
+type Publisher struct {
+	Conn         *nats.Conn
+	FlushTimeout time.Duration
+}
+
+func NewPublisher(conn *nats.Conn) *Publisher {
+	return &Publisher{Conn: conn, FlushTimeout: DefaultFlushTimeout}
+}
+
+func (self *Publisher) Publish(ctx context.Context, messages []sink.Message) error {
+	var publishErr *sink.PublishError
+	for i, message := range messages {
+		if err := self.Conn.Publish(message.Topic, message.Value); err != nil {
+			publishErr = &sink.PublishError{
+				Failed: messages[i:],
+				Err:    fmt.Errorf("nats: %d of %d messages failed: %w", len(messages)-i, len(messages), err),
+			}
+			break
+		}
+	}
+	// FlushWithContext refuses contexts without a deadline
+	ctx, cancel := context.WithTimeout(ctx, self.FlushTimeout)
+	defer cancel()
+	if err := self.Conn.FlushWithContext(ctx); err != nil {
+		return err
+	}
+	if publishErr != nil {
+		return publishErr
+	}
+	return nil
+}
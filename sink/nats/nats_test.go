@@ -0,0 +1,158 @@
+package nats_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+
+	"github.com/mnaser/pusher-websocket-go/sink"
+	natssink "github.com/mnaser/pusher-websocket-go/sink/nats"
+)
+
+// fakeServer speaks just enough of the NATS protocol to accept publishes,
+// sending "subject payload" for each PUB received
+func fakeServer(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	published := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go serve(conn, published)
+		}
+	}()
+	return "nats://" + listener.Addr().String(), published
+}
+
+func serve(conn net.Conn, published chan<- string) {
+	io.WriteString(conn, `INFO {"server_id":"fake","version":"2.10.0","max_payload":1048576}`+"\r\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			published <- fields[1] + " " + string(payload[:size])
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	url, published := fakeServer(t)
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	publisher := natssink.NewPublisher(conn)
+
+	err = publisher.Publish(context.Background(), []sink.Message{
+		{Topic: "pusher.orders", Key: []byte("orders"), Value: []byte(`{"id":1}`)},
+		{Topic: "pusher.refunds", Key: []byte("refunds"), Value: []byte(`{"id":2}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Publish returns once the batch is flushed, so it has all arrived
+	for _, expected := range []string{`pusher.orders {"id":1}`, `pusher.refunds {"id":2}`} {
+		select {
+		case message := <-published:
+			if message != expected {
+				t.Fatalf("published %v, expected %v", message, expected)
+			}
+		default:
+			t.Fatalf("%v was not published before Publish returned", expected)
+		}
+	}
+
+	conn.Close()
+	if err := publisher.Publish(context.Background(), []sink.Message{{Topic: "pusher.x"}}); !errors.Is(err, nats.ErrConnectionClosed) {
+		t.Fatalf("expected ErrConnectionClosed, got %v", err)
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	url, published := fakeServer(t)
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	publisher := natssink.NewPublisher(conn)
+
+	// The connection refuses payloads over the server's max_payload
+	err = publisher.Publish(context.Background(), []sink.Message{
+		{Topic: "pusher.a", Value: []byte("1")},
+		{Topic: "pusher.b", Value: make([]byte, conn.MaxPayload()+1)},
+		{Topic: "pusher.c", Value: []byte("3")},
+	})
+	var publishErr *sink.PublishError
+	if !errors.As(err, &publishErr) || !errors.Is(err, nats.ErrMaxPayload) {
+		t.Fatalf("expected a PublishError for ErrMaxPayload, got %v", err)
+	}
+	if len(publishErr.Failed) != 2 || publishErr.Failed[0].Topic != "pusher.b" || publishErr.Failed[1].Topic != "pusher.c" {
+		t.Fatalf("expected the unsent tail to fail, got %+v", publishErr.Failed)
+	}
+	select {
+	case message := <-published:
+		if message != "pusher.a 1" {
+			t.Fatalf("published %v", message)
+		}
+	default:
+		t.Fatal("the message before the failure was not flushed")
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	url, published := fakeServer(t)
+	config, _ := json.Marshal(map[string]string{"url": url})
+	publisher, err := sink.Publishers.New("nats", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.(*natssink.Publisher).Conn.Close()
+	if err := publisher.Publish(context.Background(), []sink.Message{{Topic: "pusher.x", Value: []byte("{}")}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-published:
+		if message != "pusher.x {}" {
+			t.Fatalf("published %v", message)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the publish")
+	}
+
+	if _, err := sink.Publishers.New("nats", []byte(`{"url": 1}`)); err == nil {
+		t.Fatal("expected invalid config to be rejected")
+	}
+}
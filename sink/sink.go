@@ -0,0 +1,202 @@
+// Package sink forwards the events received by a pusher client to an external
+// message system. Kafka and NATS adapters live in the kafka and nats
+// subpackages, jsonl archives to local files and webhook relays over HTTP;
+// other systems only need to implement Publisher.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/internal/relay"
+)
+
+const (
+	// Default maximum number of messages in a batch
+	DefaultBatchSize = 100
+	// Default maximum time a message waits before its batch is flushed
+	DefaultFlushInterval = time.Second
+	// Default number of messages queued before events are dropped
+	DefaultBuffer = 1024
+)
+
+// Message is a received event addressed to a topic or subject
+type Message struct {
+	Topic string
+	// Key is the channel name, so that partitioned systems keep each
+	// channel's events in order
+	Key   []byte
+	Value []byte
+	Event pusher.Event
+}
+
+// Publisher delivers a batch of messages to the target system. Publishers
+// which deliver some messages of a batch but not others return a
+// *PublishError, so that only the failed messages are reported.
+type Publisher interface {
+	Publish(ctx context.Context, messages []Message) error
+}
+
+// PublishError is returned by a Publisher which delivered only part of a
+// batch
+type PublishError struct {
+	// Failed holds the messages which could not be delivered
+	Failed []Message
+	Err    error
+}
+
+func (self *PublishError) Error() string {
+	return self.Err.Error()
+}
+
+func (self *PublishError) Unwrap() error {
+	return self.Err
+}
+
+// Publishers is the registry of sinks which can be configured by name. Each
+// adapter package registers itself when imported.
+var Publishers = pusher.NewRegistry[Publisher]("sink")
+
+// Sink subscribes to channels and forwards their events to a Publisher in
+// batches. Configure it before calling Start.
+type Sink struct {
+	client    *pusher.Client
+	publisher Publisher
+
+	// Topic maps an event to its topic or subject. Events for which it
+	// returns "" are skipped. Defaults to the channel name.
+	Topic func(pusher.Event) string
+	// Encode serializes an event. Defaults to its JSON encoding.
+	Encode func(pusher.Event) ([]byte, error)
+	// BatchSize is the maximum number of messages published at once
+	BatchSize int
+	// FlushInterval is the maximum time a message waits for its batch to
+	// fill before it is published
+	FlushInterval time.Duration
+	// Buffer is the number of messages queued while a batch is publishing.
+	// Events arriving while the queue is full are dropped and reported to
+	// OnError with ErrDropped.
+	Buffer int
+	// OnError is called with the messages which could not be delivered:
+	// the whole batch when publishing fails, or only the failed messages of
+	// a PublishError
+	OnError func(messages []Message, err error)
+
+	relay  *relay.Relay[Message]
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// ErrDropped is reported to OnError for events dropped because the queue was
+// full
+var ErrDropped = errors.New("sink: queue full, event dropped")
+
+func New(client *pusher.Client, publisher Publisher) *Sink {
+	return &Sink{
+		client:        client,
+		publisher:     publisher,
+		BatchSize:     DefaultBatchSize,
+		FlushInterval: DefaultFlushInterval,
+		Buffer:        DefaultBuffer,
+	}
+}
+
+// Start subscribes to the given channels and begins forwarding their events
+func (self *Sink) Start(channels ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	self.cancel = cancel
+	self.relay = relay.Start(self.client, channels, self.Buffer, self.message, func(message Message) {
+		self.fail([]Message{message}, ErrDropped)
+	})
+
+	self.done.Add(1)
+	go self.run(ctx)
+}
+
+// Close unsubscribes, publishes any queued messages and waits for delivery.
+// It does nothing if the sink was never started, or is already closed.
+func (self *Sink) Close() {
+	if self.relay == nil {
+		return
+	}
+	self.relay.Close()
+	self.done.Wait()
+	self.cancel()
+}
+
+func (self *Sink) message(event pusher.Event) (Message, bool) {
+	topic := event.Channel
+	if self.Topic != nil {
+		topic = self.Topic(event)
+	}
+	if topic == "" {
+		return Message{}, false
+	}
+
+	var value []byte
+	var err error
+	if self.Encode != nil {
+		value, err = self.Encode(event)
+	} else {
+		value, err = json.Marshal(event)
+	}
+	message := Message{Topic: topic, Key: []byte(event.Channel), Value: value, Event: event}
+	if err != nil {
+		self.fail([]Message{message}, err)
+		return Message{}, false
+	}
+	return message, true
+}
+
+func (self *Sink) run(ctx context.Context) {
+	defer self.done.Done()
+
+	batch := make([]Message, 0, self.BatchSize)
+	timer := time.NewTimer(self.FlushInterval)
+	timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		if len(batch) == 0 {
+			return
+		}
+		if err := self.publisher.Publish(ctx, batch); err != nil {
+			failed := batch
+			var publishErr *PublishError
+			if errors.As(err, &publishErr) {
+				failed = publishErr.Failed
+			}
+			self.fail(failed, err)
+		}
+		batch = make([]Message, 0, self.BatchSize)
+	}
+
+	for {
+		select {
+		case message, ok := <-self.relay.Queue():
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(self.FlushInterval)
+			}
+			batch = append(batch, message)
+			if len(batch) >= self.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (self *Sink) fail(messages []Message, err error) {
+	if self.OnError != nil {
+		self.OnError(messages, err)
+	}
+}
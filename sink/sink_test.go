@@ -0,0 +1,198 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+	"github.com/mnaser/pusher-websocket-go/sink"
+)
+
+// publisher passes each batch it is given to a channel, failing with err
+type publisher struct {
+	batches chan []sink.Message
+	err     func([]sink.Message) error
+}
+
+func (self *publisher) Publish(ctx context.Context, messages []sink.Message) error {
+	self.batches <- messages
+	if self.err != nil {
+		return self.err(messages)
+	}
+	return nil
+}
+
+// newSink returns a sink whose client is already subscribed to orders, so
+// that the sink is subscribed to it as soon as it starts, and the client's
+// channel
+func newSink(t *testing.T, p *publisher) (*pushertest.Server, *sink.Sink, *pusher.Channel) {
+	srv := pushertest.NewServer("key", "secret")
+	t.Cleanup(srv.Close)
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	t.Cleanup(client.Disconnect)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	orders, err := client.SubscribeWithResult(ctx, "orders")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	return srv, sink.New(client, p), orders
+}
+
+// sync waits until the events triggered on orders so far have been handed to
+// the sink, which receives them before the client's own binding receives the
+// sync event following them
+func sync(t *testing.T, srv *pushertest.Server, orders *pusher.Channel) {
+	t.Helper()
+	synced := make(chan struct{})
+	orders.BindOnce("sync", func(interface{}) { close(synced) })
+	srv.Trigger("orders", "sync", "{}")
+	select {
+	case <-synced:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the sync event")
+	}
+}
+
+// skipSync is a Topic which skips the sync event
+func skipSync(event pusher.Event) string {
+	if event.Name == "sync" {
+		return ""
+	}
+	return event.Channel
+}
+
+func nextBatch(t *testing.T, batches chan []sink.Message) []sink.Message {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a batch")
+		return nil
+	}
+}
+
+// TestBatches publishes a full batch at once, and the remainder on Close
+func TestBatches(t *testing.T) {
+	p := &publisher{batches: make(chan []sink.Message, 4)}
+	srv, s, orders := newSink(t, p)
+	s.BatchSize = 2
+	s.FlushInterval = time.Hour
+	s.Topic = skipSync
+	s.Start("orders")
+	for _, id := range []string{"1", "2", "3"} {
+		srv.Trigger("orders", "created", id)
+	}
+
+	batch := nextBatch(t, p.batches)
+	if len(batch) != 2 || batch[0].Event.Data != "1" || batch[1].Event.Data != "2" {
+		t.Fatalf("unexpected first batch %+v", batch)
+	}
+	message := batch[0]
+	var event pusher.Event
+	if message.Topic != "orders" || string(message.Key) != "orders" || json.Unmarshal(message.Value, &event) != nil || event.Name != "created" {
+		t.Fatalf("unexpected message %+v", message)
+	}
+
+	// Close waits for the remainder to be published
+	sync(t, srv, orders)
+	s.Close()
+	select {
+	case batch := <-p.batches:
+		if len(batch) != 1 || batch[0].Event.Data != "3" {
+			t.Fatalf("unexpected last batch %+v", batch)
+		}
+	default:
+		t.Fatal("Close did not publish the remaining message")
+	}
+	s.Close()
+}
+
+// TestFlushInterval publishes a partial batch once FlushInterval has passed
+func TestFlushInterval(t *testing.T) {
+	p := &publisher{batches: make(chan []sink.Message, 1)}
+	srv, s, _ := newSink(t, p)
+	s.FlushInterval = 20 * time.Millisecond
+	s.Topic = func(event pusher.Event) string {
+		if event.Name == "skipped" {
+			return ""
+		}
+		return "topic-" + event.Name
+	}
+	s.Start("orders")
+	defer s.Close()
+	srv.Trigger("orders", "skipped", "{}")
+	srv.Trigger("orders", "created", "{}")
+
+	batch := nextBatch(t, p.batches)
+	if len(batch) != 1 || batch[0].Topic != "topic-created" {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+}
+
+// TestErrors reports the failed messages of a batch, those which could not be
+// encoded, and those dropped because the queue was full
+func TestErrors(t *testing.T) {
+	failure := errors.New("unavailable")
+	release := make(chan struct{})
+	p := &publisher{batches: make(chan []sink.Message, 4), err: func(messages []sink.Message) error {
+		<-release
+		return &sink.PublishError{Failed: messages[1:], Err: failure}
+	}}
+	srv, s, _ := newSink(t, p)
+	s.BatchSize = 2
+	s.Buffer = 1
+	s.FlushInterval = time.Hour
+	s.Encode = func(event pusher.Event) ([]byte, error) {
+		if event.Name == "bad" {
+			return nil, errors.New("unencodable")
+		}
+		return []byte(event.Data), nil
+	}
+	reported := make(chan []sink.Message, 8)
+	errs := make(chan error, 8)
+	s.OnError = func(messages []sink.Message, err error) {
+		reported <- messages
+		errs <- err
+	}
+	s.Start("orders")
+
+	srv.Trigger("orders", "bad", "0")
+	if err := <-errs; err == nil || err.Error() != "unencodable" {
+		t.Fatalf("expected the encoding error, got %v", err)
+	}
+	<-reported
+
+	// The first batch blocks publishing, the next message is queued, and
+	// the rest are dropped
+	srv.Trigger("orders", "created", "1")
+	srv.Trigger("orders", "created", "2")
+	nextBatch(t, p.batches)
+	srv.Trigger("orders", "created", "3")
+	srv.Trigger("orders", "created", "4")
+	if err := <-errs; !errors.Is(err, sink.ErrDropped) {
+		t.Fatalf("expected ErrDropped, got %v", err)
+	}
+	if dropped := <-reported; len(dropped) != 1 || dropped[0].Event.Data != "4" {
+		t.Fatalf("unexpected dropped messages %+v", dropped)
+	}
+
+	close(release)
+	if err := <-errs; !errors.Is(err, failure) {
+		t.Fatalf("expected the publish error, got %v", err)
+	}
+	if failed := <-reported; len(failed) != 1 || failed[0].Event.Data != "2" {
+		t.Fatalf("expected only the failed message, got %+v", failed)
+	}
+	s.Close()
+}
+
+func TestCloseUnstarted(t *testing.T) {
+	s := sink.New(pusher.NewWithConfig(pusher.ClientConfig{DisableAutoConnect: true}), &publisher{})
+	s.Close()
+}
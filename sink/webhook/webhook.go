@@ -0,0 +1,188 @@
+// Package webhook relays received events to an HTTP endpoint, for systems
+// which can only accept webhooks.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/sink"
+)
+
+const (
+	// Default number of requests in flight at once
+	DefaultConcurrency = 4
+	// Default number of retries after a failed request
+	DefaultMaxRetries = 3
+	// Default delay before the first retry, doubled for each further one
+	DefaultRetryDelay = time.Second
+)
+
+func init() {
+	sink.Publishers.Register("webhook", func(config json.RawMessage) (sink.Publisher, error) {
+		var c struct {
+			URL         string `json:"url"`
+			Key         string `json:"key"`
+			Secret      string `json:"secret"`
+			Concurrency int    `json:"concurrency"`
+			MaxRetries  *int   `json:"max_retries"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		forwarder := NewForwarder(c.URL, c.Secret)
+		forwarder.Key = c.Key
+		if c.Concurrency > 0 {
+			forwarder.Concurrency = c.Concurrency
+		}
+		if c.MaxRetries != nil {
+			forwarder.MaxRetries = *c.MaxRetries
+		}
+		return forwarder, nil
+	})
+}
+
+// New returns a sink forwarding events to url
+func New(client *pusher.Client, url, secret string) (*sink.Sink, *Forwarder) {
+	forwarder := NewForwarder(url, secret)
+	return sink.New(client, forwarder), forwarder
+}
+
+// Forwarder is a sink.Publisher POSTing each message to URL as its JSON
+// body. Requests are signed like Pusher's own webhooks: X-Pusher-Signature
+// carries the hex HMAC-SHA256 of the body keyed with Secret, so receivers can
+// reuse their existing verification.
+//
+// Requests failing with a network error, 429 or a 5xx status are retried.
+type Forwarder struct {
+	URL    string
+	Key    string
+	Secret string
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+	// Concurrency is the maximum number of requests in flight
+	Concurrency int
+	MaxRetries  int
+	RetryDelay  time.Duration
+	// OnError is called for each message which could not be delivered, with
+	// its own error, e.g. for logging. The failed messages are then returned
+	// to the sink in a *sink.PublishError, so the sink's OnError receives
+	// them, and only them, again: retry from one of the two, not both.
+	OnError func(sink.Message, error)
+}
+
+func NewForwarder(url, secret string) *Forwarder {
+	return &Forwarder{
+		URL:         url,
+		Secret:      secret,
+		Client:      http.DefaultClient,
+		Concurrency: DefaultConcurrency,
+		MaxRetries:  DefaultMaxRetries,
+		RetryDelay:  DefaultRetryDelay,
+	}
+}
+
+func (self *Forwarder) Publish(ctx context.Context, messages []sink.Message) error {
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		failed []sink.Message
+		first  error
+	)
+	concurrency := self.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limit := make(chan struct{}, concurrency)
+
+	for _, message := range messages {
+		limit <- struct{}{}
+		wg.Add(1)
+		go func(message sink.Message) {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			if err := self.forward(ctx, message); err != nil {
+				if self.OnError != nil {
+					self.OnError(message, err)
+				}
+				mutex.Lock()
+				if failed = append(failed, message); first == nil {
+					first = err
+				}
+				mutex.Unlock()
+			}
+		}(message)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return &sink.PublishError{
+			Failed: failed,
+			Err:    fmt.Errorf("webhook: %d of %d events failed: %w", len(failed), len(messages), first),
+		}
+	}
+	return nil
+}
+
+func (self *Forwarder) forward(ctx context.Context, message sink.Message) error {
+	delay := self.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := self.post(ctx, message.Value)
+		if err == nil || !retry || attempt >= self.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// post sends one request, reporting whether a failure is worth retrying
+func (self *Forwarder) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, self.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if self.Key != "" {
+		req.Header.Set("X-Pusher-Key", self.Key)
+	}
+	if self.Secret != "" {
+		req.Header.Set("X-Pusher-Signature", sign(self.Secret, body))
+	}
+
+	client := self.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook: %s responded %s", self.URL, resp.Status)
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,145 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go/sink"
+	"github.com/mnaser/pusher-websocket-go/sink/webhook"
+)
+
+// endpoint responds to each body with the next of its statuses, recording
+// the requests it receives
+type endpoint struct {
+	mutex    sync.Mutex
+	statuses map[string][]int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (self *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.requests = append(self.requests, r)
+	self.bodies = append(self.bodies, string(body))
+	status := http.StatusOK
+	if statuses := self.statuses[string(body)]; len(statuses) > 0 {
+		status, self.statuses[string(body)] = statuses[0], statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newForwarder(t *testing.T, statuses map[string][]int) (*webhook.Forwarder, *endpoint) {
+	e := &endpoint{statuses: statuses}
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	forwarder := webhook.NewForwarder(srv.URL, "secret")
+	forwarder.Key = "key"
+	forwarder.RetryDelay = 0
+	return forwarder, e
+}
+
+func messages(values ...string) []sink.Message {
+	var messages []sink.Message
+	for _, value := range values {
+		messages = append(messages, sink.Message{Topic: "orders", Value: []byte(value)})
+	}
+	return messages
+}
+
+// TestSigned checks that requests are signed like Pusher's webhooks
+func TestSigned(t *testing.T) {
+	forwarder, e := newForwarder(t, nil)
+	if err := forwarder.Publish(context.Background(), messages(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`{"a":1}`))
+	request := e.requests[0]
+	if request.Method != http.MethodPost || request.Header.Get("Content-Type") != "application/json" || request.Header.Get("X-Pusher-Key") != "key" {
+		t.Fatalf("unexpected request %v %v", request.Method, request.Header)
+	}
+	if signature := request.Header.Get("X-Pusher-Signature"); signature != hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("unexpected signature %v", signature)
+	}
+	if e.bodies[0] != `{"a":1}` {
+		t.Fatalf("unexpected body %v", e.bodies[0])
+	}
+}
+
+// TestNilClient falls back to http.DefaultClient
+func TestNilClient(t *testing.T) {
+	forwarder, e := newForwarder(t, nil)
+	forwarder.Client = nil
+	if err := forwarder.Publish(context.Background(), messages(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.bodies) != 1 || e.bodies[0] != `{"a":1}` {
+		t.Fatalf("unexpected bodies %v", e.bodies)
+	}
+}
+
+// TestRetries retries 429 and 5xx responses up to MaxRetries times, but not
+// other failures, and reports only the messages which failed
+func TestRetries(t *testing.T) {
+	forwarder, e := newForwarder(t, map[string][]int{
+		"recovers":  {http.StatusServiceUnavailable, http.StatusTooManyRequests},
+		"rejected":  {http.StatusBadRequest},
+		"exhausted": {500, 500, 500},
+	})
+	forwarder.MaxRetries = 2
+	var mutex sync.Mutex
+	reported := map[string]bool{}
+	forwarder.OnError = func(message sink.Message, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		reported[string(message.Value)] = true
+	}
+
+	err := forwarder.Publish(context.Background(), messages("ok", "recovers", "rejected", "exhausted"))
+	var publishErr *sink.PublishError
+	if !errors.As(err, &publishErr) {
+		t.Fatalf("expected a PublishError, got %v", err)
+	}
+	failed := map[string]bool{}
+	for _, message := range publishErr.Failed {
+		failed[string(message.Value)] = true
+	}
+	expected := map[string]bool{"rejected": true, "exhausted": true}
+	if len(failed) != 2 || !failed["rejected"] || !failed["exhausted"] {
+		t.Fatalf("expected %v to fail, got %v", expected, failed)
+	}
+	if len(reported) != 2 || !reported["rejected"] || !reported["exhausted"] {
+		t.Fatalf("expected OnError for %v, got %v", expected, reported)
+	}
+
+	attempts := map[string]int{}
+	for _, body := range e.bodies {
+		attempts[body]++
+	}
+	for body, count := range map[string]int{"ok": 1, "recovers": 3, "rejected": 1, "exhausted": 3} {
+		if attempts[body] != count {
+			t.Errorf("expected %v attempts for %v, got %v", count, body, attempts[body])
+		}
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	publisher, err := sink.Publishers.New("webhook", []byte(`{"url":"http://example.com","key":"key","max_retries":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarder := publisher.(*webhook.Forwarder)
+	if forwarder.URL != "http://example.com" || forwarder.Key != "key" || forwarder.MaxRetries != 0 || forwarder.Concurrency != webhook.DefaultConcurrency {
+		t.Fatalf("unexpected forwarder %+v", forwarder)
+	}
+}
@@ -0,0 +1,129 @@
+// Package ssebridge re-serves the events received by a pusher client as
+// Server-Sent Events, so that browsers and dashboards can follow channels
+// without Pusher credentials of their own.
+package ssebridge
+
+import (
+	"fmt"
+	"net/http"
+	s "strings"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/internal/relay"
+)
+
+const (
+	// Default number of events buffered for each request
+	DefaultBuffer = 256
+	// Default interval between keep-alive comments
+	DefaultKeepAlive = 15 * time.Second
+)
+
+// Handler is an http.Handler streaming one channel's events as SSE. The
+// channel is taken from the "channel" query parameter, and the optional
+// "event" parameter restricts the stream to a single event name.
+type Handler struct {
+	client *pusher.Client
+
+	// Buffer is the number of events buffered for each request. Requests
+	// which fall further behind are ended.
+	Buffer int
+	// KeepAlive is the interval between comment lines sent to keep idle
+	// streams open through proxies. Zero disables them.
+	KeepAlive time.Duration
+	// Authorize is called before streaming. Returning false responds with
+	// 403 Forbidden. Without it, only public channels may be streamed, as
+	// private and presence channels are authorized with the client's own
+	// credentials.
+	Authorize func(r *http.Request, channel string) bool
+}
+
+func NewHandler(client *pusher.Client) *Handler {
+	return &Handler{client: client, Buffer: DefaultBuffer, KeepAlive: DefaultKeepAlive}
+}
+
+func (self *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	name := r.URL.Query().Get("event")
+	if channel == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+	if !self.authorized(r, channel) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	overflow := make(chan struct{})
+	overflowed := false
+	events := relay.Start(self.client, []string{channel}, self.Buffer, func(event pusher.Event) (pusher.Event, bool) {
+		return event, name == "" || event.Name == name
+	}, func(pusher.Event) {
+		if !overflowed {
+			overflowed = true
+			close(overflow)
+		}
+	})
+	// Releases the request's channel, and its bindings, when it ends
+	defer events.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// Stops nginx from buffering the response
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var keepAlive <-chan time.Time
+	if self.KeepAlive > 0 {
+		ticker := time.NewTicker(self.KeepAlive)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+
+	for {
+		select {
+		case event := <-events.Queue():
+			if _, err := w.Write(format(event)); err != nil {
+				return
+			}
+		case <-keepAlive:
+			if _, err := w.Write([]byte(":\n\n")); err != nil {
+				return
+			}
+		case <-overflow:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// authorized decides whether a request may stream a channel
+func (self *Handler) authorized(r *http.Request, channel string) bool {
+	if self.Authorize != nil {
+		return self.Authorize(r, channel)
+	}
+	return !s.HasPrefix(channel, "private-") && !s.HasPrefix(channel, "presence-")
+}
+
+// format encodes an event as an SSE message, splitting multi-line data
+// across several data fields as the spec requires
+func format(event pusher.Event) []byte {
+	var b s.Builder
+	fmt.Fprintf(&b, "event: %s\n", event.Name)
+	for _, line := range s.Split(event.Data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", s.TrimSuffix(line, "\r"))
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
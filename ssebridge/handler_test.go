@@ -0,0 +1,140 @@
+package ssebridge_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+	"github.com/mnaser/pusher-websocket-go/ssebridge"
+)
+
+// newHandler returns a handler whose client is already subscribed to orders,
+// so that requests for it are subscribed as soon as they start streaming
+func newHandler(t *testing.T) (*pushertest.Server, *ssebridge.Handler, *pusher.Channel) {
+	srv := pushertest.NewServer("key", "secret")
+	t.Cleanup(srv.Close)
+	client := pusher.NewWithConfig(srv.ClientConfig())
+	t.Cleanup(client.Disconnect)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	orders, err := client.SubscribeWithResult(ctx, "orders")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	return srv, ssebridge.NewHandler(client), orders
+}
+
+func TestRejected(t *testing.T) {
+	_, handler, _ := newHandler(t)
+	for query, status := range map[string]int{
+		"":                   http.StatusBadRequest,
+		"channel=private-a":  http.StatusForbidden,
+		"channel=presence-a": http.StatusForbidden,
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/?"+query, nil))
+		if recorder.Code != status {
+			t.Errorf("expected %v for %q, got %v", status, query, recorder.Code)
+		}
+	}
+
+	handler.Authorize = func(r *http.Request, channel string) bool { return false }
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/?channel=public", nil))
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected Authorize to forbid the channel, got %v", recorder.Code)
+	}
+}
+
+// TestStream streams a channel restricted to one event, whose multi-line
+// data must be split across data fields
+func TestStream(t *testing.T) {
+	srv, handler, _ := newHandler(t)
+	handler.KeepAlive = 0
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "?channel=orders&event=created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %v %v", response.StatusCode, response.Header)
+	}
+
+	srv.Trigger("orders", "updated", "skipped")
+	srv.Trigger("orders", "created", "first\r\nsecond")
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	for _, expected := range []string{"event: created", "data: first", "data: second", ""} {
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Fatalf("expected %q, got %q", expected, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+// TestOverflow ends a stream which falls behind by more than its buffer
+func TestOverflow(t *testing.T) {
+	srv, handler, orders := newHandler(t)
+	handler.Buffer = 1
+	handler.KeepAlive = 0
+	writer := &blockedWriter{ResponseRecorder: httptest.NewRecorder(), started: make(chan struct{}), release: make(chan struct{})}
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(writer, httptest.NewRequest("GET", "/?channel=orders", nil))
+		close(done)
+	}()
+	<-writer.started
+	// The client's own binding receives flush only once the events before
+	// it have been handed to the stream
+	flushed := make(chan struct{})
+	orders.Bind("flush", func(interface{}) { close(flushed) })
+	for i := 0; i < 4; i++ {
+		srv.Trigger("orders", "created", strings.Repeat("x", i))
+	}
+	srv.Trigger("orders", "flush", "{}")
+	<-flushed
+	close(writer.release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stream was not ended")
+	}
+}
+
+// blockedWriter blocks writes until released, as a slow client would,
+// closing started once the response starts
+type blockedWriter struct {
+	*httptest.ResponseRecorder
+	started chan struct{}
+	release chan struct{}
+}
+
+func (self *blockedWriter) WriteHeader(code int) {
+	self.ResponseRecorder.WriteHeader(code)
+	close(self.started)
+}
+
+func (self *blockedWriter) Write(data []byte) (int, error) {
+	<-self.release
+	return self.ResponseRecorder.Write(data)
+}
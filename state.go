@@ -0,0 +1,66 @@
+package pusher
+
+// ConnectionState is the stage of its lifecycle a client's connection is in,
+// modeled on the states of pusher-js
+type ConnectionState string
+
+const (
+	// StateInitialized is the state of a client which has not yet started
+	// connecting
+	StateInitialized ConnectionState = "initialized"
+	// StateConnecting is the state while a connection is being established,
+	// including after an established connection was lost
+	StateConnecting ConnectionState = "connecting"
+	// StateConnected is the state while the connection is established
+	StateConnected ConnectionState = "connected"
+	// StateUnavailable is the state once connecting has failed, while the
+	// client keeps retrying, e.g. during a network outage
+	StateUnavailable ConnectionState = "unavailable"
+	// StateFailed is the terminal state of a client which has given up
+	// connecting
+	StateFailed ConnectionState = "failed"
+	// StateDisconnected is the terminal state of a client closed with
+	// Disconnect
+	StateDisconnected ConnectionState = "disconnected"
+)
+
+// State returns the current state of the client's connection. Facades report
+// the state of the connection they share.
+func (self *Client) State() ConnectionState {
+	self.loop.stateMutex.Lock()
+	defer self.loop.stateMutex.Unlock()
+	return self.loop.state
+}
+
+// IsConnected reports whether the client's connection is established. It is
+// safe to call from any goroutine.
+func (self *Client) IsConnected() bool {
+	return self.State() == StateConnected
+}
+
+// BindConnectionStateChange binds a callback run whenever the connection
+// changes state. Callbacks run on the client's run loop, so must not block.
+// Callbacks bound through a facade share its connection, and outlive it.
+func (self *Client) BindConnectionStateChange(callback func(previous, current ConnectionState)) {
+	self.loop.stateMutex.Lock()
+	defer self.loop.stateMutex.Unlock()
+	self.loop.stateBindings = append(self.loop.stateBindings, callback)
+}
+
+// setState moves the connection to a new state, notifying state change
+// bindings, and returns the previous state
+func (self *Client) setState(state ConnectionState) ConnectionState {
+	self.loop.stateMutex.Lock()
+	previous := self.loop.state
+	self.loop.state = state
+	bindings := append([]func(previous, current ConnectionState){}, self.loop.stateBindings...)
+	self.loop.stateMutex.Unlock()
+
+	if previous != state {
+		self.logger().Debugf("Connection state changed from %v to %v", previous, state)
+		for _, callback := range bindings {
+			callback(previous, state)
+		}
+	}
+	return previous
+}
@@ -0,0 +1,119 @@
+package pusher_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// waitState waits for the client to reach a state
+func waitState(t *testing.T, client *pusher.Client, state pusher.ConnectionState) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for client.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("client is %v rather than %v", client.State(), state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStates(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	if state := client.State(); state != pusher.StateInitialized || client.IsConnected() {
+		t.Fatalf("client is %v before connecting", state)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	waitState(t, client, pusher.StateConnected)
+	if !client.IsConnected() {
+		t.Fatal("IsConnected is false once connected")
+	}
+	client.Disconnect()
+	waitState(t, client, pusher.StateDisconnected)
+}
+
+// TestStateUnavailable fails to connect, which leaves the client unavailable
+// while it keeps retrying
+func TestStateUnavailable(t *testing.T) {
+	config := pusher.ClientConfig{MaxReconnectDelay: time.Millisecond}
+	client, _, _, _ := fakeClient(t, config, func() error { return errors.New("connection refused") })
+	waitState(t, client, pusher.StateUnavailable)
+}
+
+// TestBindConnectionStateChange connects, loses the connection and
+// disconnects, reporting each transition
+func TestBindConnectionStateChange(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	config := srv.ClientConfig()
+	config.DisableAutoConnect = true
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	changes := make(chan [2]pusher.ConnectionState, 10)
+	client.BindConnectionStateChange(func(previous, current pusher.ConnectionState) {
+		changes <- [2]pusher.ConnectionState{previous, current}
+	})
+	expect := func(previous, current pusher.ConnectionState) {
+		t.Helper()
+		select {
+		case change := <-changes:
+			if change != [2]pusher.ConnectionState{previous, current} {
+				t.Fatalf("changed from %v to %v, expected %v to %v", change[0], change[1], previous, current)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %v", current)
+		}
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	expect(pusher.StateInitialized, pusher.StateConnecting)
+	expect(pusher.StateConnecting, pusher.StateConnected)
+	srv.DisconnectAll(4200, "reconnect")
+	expect(pusher.StateConnected, pusher.StateConnecting)
+	expect(pusher.StateConnecting, pusher.StateConnected)
+	client.Disconnect()
+	expect(pusher.StateConnected, pusher.StateDisconnected)
+}
+
+// TestConnect dials nothing until Connect, which returns the error of the
+// first attempt and may only be called once
+func TestConnect(t *testing.T) {
+	var dials atomic.Int32
+	refused := errors.New("connection refused")
+	config := pusher.ClientConfig{DisableAutoConnect: true, MaxReconnectDelay: time.Millisecond}
+	client, _, _, _ := fakeClient(t, config, func() error {
+		dials.Add(1)
+		return refused
+	})
+
+	time.Sleep(10 * time.Millisecond)
+	if count := dials.Load(); count != 0 {
+		t.Fatalf("dialled %v times before Connect", count)
+	}
+	if err := client.Connect(); !errors.Is(err, refused) {
+		t.Fatalf("expected the dial error, got %v", err)
+	}
+	if err := client.Connect(); err != pusher.ErrAlreadyConnected {
+		t.Fatalf("expected ErrAlreadyConnected connecting again, got %v", err)
+	}
+	client.Disconnect()
+	waitState(t, client, pusher.StateDisconnected)
+	if err := client.Connect(); err != pusher.ErrDisconnected {
+		t.Fatalf("expected ErrDisconnected after Disconnect, got %v", err)
+	}
+}
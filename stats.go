@@ -0,0 +1,74 @@
+package pusher
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// ConnectionStats counts the bytes of the messages on a connection, and of the
+// network traffic carrying them, e.g. to measure the savings of compression.
+// Wire bytes include framing, and TLS when it is used.
+type ConnectionStats struct {
+	MessageBytesReceived int64
+	MessageBytesSent     int64
+	WireBytesReceived    int64
+	WireBytesSent        int64
+}
+
+// ConnectionStats returns the statistics of the current connection, or of the
+// last one if the client is not connected. It is safe to call from any
+// goroutine.
+func (self *Client) ConnectionStats() ConnectionStats {
+	counters := self.loop.counters.Load()
+	if counters == nil {
+		return ConnectionStats{}
+	}
+	return ConnectionStats{
+		MessageBytesReceived: counters.messageIn.Load(),
+		MessageBytesSent:     counters.messageOut.Load(),
+		WireBytesReceived:    counters.wireIn.Load(),
+		WireBytesSent:        counters.wireOut.Load(),
+	}
+}
+
+// InvalidMessages returns the number of messages from the server which were
+// not valid events, over every connection. It is safe to call from any
+// goroutine.
+func (self *Client) InvalidMessages() int64 {
+	return self.loop.invalidMessages.Load()
+}
+
+// byteCounters accumulates the statistics of one connection
+type byteCounters struct {
+	messageIn, messageOut atomic.Int64
+	wireIn, wireOut       atomic.Int64
+}
+
+// dialer wraps dialContext to count the bytes of the connections it makes
+func (self *byteCounters) dialer(dialContext DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, counters: self}, nil
+	}
+}
+
+type countingConn struct {
+	net.Conn
+	counters *byteCounters
+}
+
+func (self *countingConn) Read(b []byte) (int, error) {
+	n, err := self.Conn.Read(b)
+	self.counters.wireIn.Add(int64(n))
+	return n, err
+}
+
+func (self *countingConn) Write(b []byte) (int, error) {
+	n, err := self.Conn.Write(b)
+	self.counters.wireOut.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,157 @@
+// Package statsd exports a pusher client's metrics to StatsD or DogStatsD
+// over UDP. Importing the package registers the "statsd" metrics collector.
+package statsd
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	s "strings"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// Default prefix of metric names
+const DefaultPrefix = "pusher."
+
+func init() {
+	pusher.MetricsCollectors.Register("statsd", func(config json.RawMessage) (pusher.MetricsCollector, error) {
+		var c struct {
+			Address    string   `json:"address"`
+			Prefix     *string  `json:"prefix"`
+			DogStatsD  bool     `json:"dogstatsd"`
+			Tags       []string `json:"tags"`
+			ChannelTag bool     `json:"channel_tag"`
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		exporter, err := New(c.Address)
+		if err != nil {
+			return nil, err
+		}
+		if c.Prefix != nil {
+			exporter.Prefix = *c.Prefix
+		}
+		exporter.DogStatsD = c.DogStatsD
+		exporter.Tags = c.Tags
+		exporter.ChannelTag = c.ChannelTag
+		return exporter, nil
+	})
+}
+
+// Exporter is a pusher.MetricsCollector sending each metric as a UDP packet.
+// It emits the counters connections, disconnections, reconnects,
+// messages.received, messages.sent, bytes.received, bytes.sent,
+// subscriptions.succeeded and subscriptions.failed, the gauge connected, and
+// the timings handler.latency and ping.rtt.
+type Exporter struct {
+	conn net.Conn
+
+	Prefix string
+	// DogStatsD appends tags in the DogStatsD format, replacing any ",", "|",
+	// "#" or ":" in tag values with "_". Plain StatsD has no tags, so Tags
+	// and ChannelTag are ignored without it.
+	DogStatsD bool
+	// Tags are added to every metric, e.g. "env:prod"
+	Tags []string
+	// ChannelTag tags channel metrics with the channel name. Beware of
+	// cardinality with many distinct channels.
+	ChannelTag bool
+}
+
+// New returns an exporter sending to a StatsD server at addr, e.g.
+// "127.0.0.1:8125"
+func New(addr string) (*Exporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{conn: conn, Prefix: DefaultPrefix}, nil
+}
+
+func (self *Exporter) Close() error {
+	return self.conn.Close()
+}
+
+func (self *Exporter) Connected() {
+	self.send("connections", 1, "c", "")
+	self.send("connected", 1, "g", "")
+}
+
+func (self *Exporter) Disconnected() {
+	self.send("disconnections", 1, "c", "")
+	self.send("connected", 0, "g", "")
+}
+
+func (self *Exporter) MessageReceived(channel, event string, size int) {
+	self.send("messages.received", 1, "c", channel)
+	self.send("bytes.received", size, "c", channel)
+}
+
+func (self *Exporter) MessageSent(channel, event string, size int) {
+	self.send("messages.sent", 1, "c", channel)
+	self.send("bytes.sent", size, "c", channel)
+}
+
+func (self *Exporter) SubscriptionSucceeded(channel string) {
+	self.send("subscriptions.succeeded", 1, "c", channel)
+}
+
+func (self *Exporter) SubscriptionFailed(channel string, err error) {
+	self.send("subscriptions.failed", 1, "c", channel)
+}
+
+func (self *Exporter) Reconnecting(attempt int, delay time.Duration) {
+	self.send("reconnects", 1, "c", "")
+}
+
+func (self *Exporter) HandlerLatency(channel, event string, duration time.Duration) {
+	self.send("handler.latency", int(duration.Milliseconds()), "ms", channel)
+}
+
+func (self *Exporter) PingRTT(rtt time.Duration) {
+	self.send("ping.rtt", int(rtt.Milliseconds()), "ms", "")
+}
+
+// send writes one metric. Errors are ignored, as UDP delivery is best effort
+// anyway and metrics must never disrupt the client.
+func (self *Exporter) send(name string, value int, kind string, channel string) {
+	var b s.Builder
+	b.WriteString(self.Prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(strconv.Itoa(value))
+	b.WriteByte('|')
+	b.WriteString(kind)
+
+	if self.DogStatsD {
+		tags := make([]string, 0, len(self.Tags)+1)
+		for _, t := range self.Tags {
+			tags = append(tags, tag(t))
+		}
+		if self.ChannelTag && channel != "" {
+			tags = append(tags, "channel:"+tagValue.Replace(channel))
+		}
+		if len(tags) > 0 {
+			b.WriteString("|#")
+			b.WriteString(s.Join(tags, ","))
+		}
+	}
+
+	self.conn.Write([]byte(b.String()))
+}
+
+// tagValue replaces the characters which would end a DogStatsD tag, or split
+// it into another key and value
+var tagValue = s.NewReplacer(",", "_", "|", "_", "#", "_", ":", "_")
+
+// tag escapes a "key:value" tag, keeping the colon which separates the two
+func tag(t string) string {
+	key, value, found := s.Cut(t, ":")
+	if !found {
+		return tagValue.Replace(t)
+	}
+	return tagValue.Replace(key) + ":" + tagValue.Replace(value)
+}
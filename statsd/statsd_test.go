@@ -0,0 +1,127 @@
+package statsd_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/statsd"
+)
+
+// listen returns a UDP server and a function reading its next packet
+func listen(t *testing.T) (string, func() string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String(), func() string {
+		t.Helper()
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("reading a packet: %v", err)
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestStatsD(t *testing.T) {
+	addr, next := listen(t)
+	exporter, err := statsd.New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+	// Tags are ignored without DogStatsD
+	exporter.Tags = []string{"env:test"}
+	exporter.ChannelTag = true
+
+	exporter.Connected()
+	exporter.MessageReceived("orders", "created", 42)
+	exporter.SubscriptionFailed("private-orders", errors.New("denied"))
+	exporter.HandlerLatency("orders", "created", 1500*time.Millisecond)
+	exporter.PingRTT(30 * time.Millisecond)
+	exporter.Disconnected()
+	for _, expected := range []string{
+		"pusher.connections:1|c",
+		"pusher.connected:1|g",
+		"pusher.messages.received:1|c",
+		"pusher.bytes.received:42|c",
+		"pusher.subscriptions.failed:1|c",
+		"pusher.handler.latency:1500|ms",
+		"pusher.ping.rtt:30|ms",
+		"pusher.disconnections:1|c",
+		"pusher.connected:0|g",
+	} {
+		if packet := next(); packet != expected {
+			t.Fatalf("expected %q, got %q", expected, packet)
+		}
+	}
+}
+
+func TestDogStatsD(t *testing.T) {
+	addr, next := listen(t)
+	exporter, err := statsd.New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+	exporter.Prefix = "app."
+	exporter.DogStatsD = true
+	exporter.Tags = []string{"env:test"}
+
+	exporter.MessageSent("orders", "client-typing", 7)
+	exporter.ChannelTag = true
+	exporter.SubscriptionSucceeded("orders")
+	exporter.Reconnecting(1, time.Second)
+	for _, expected := range []string{
+		"app.messages.sent:1|c|#env:test",
+		"app.bytes.sent:7|c|#env:test",
+		"app.subscriptions.succeeded:1|c|#env:test,channel:orders",
+		"app.reconnects:1|c|#env:test",
+	} {
+		if packet := next(); packet != expected {
+			t.Fatalf("expected %q, got %q", expected, packet)
+		}
+	}
+	if len(exporter.Tags) != 1 {
+		t.Fatalf("the channel tag was added to Tags: %v", exporter.Tags)
+	}
+}
+
+// TestDogStatsDTags escapes the characters which would break the packet
+// apart in tag values
+func TestDogStatsDTags(t *testing.T) {
+	addr, next := listen(t)
+	exporter, err := statsd.New(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+	exporter.Prefix = "app."
+	exporter.DogStatsD = true
+	exporter.ChannelTag = true
+	exporter.Tags = []string{"region:eu|west", "team#1", "url:http://x"}
+
+	exporter.SubscriptionSucceeded("presence-a,b:c#d|e")
+	if packet, expected := next(), "app.subscriptions.succeeded:1|c|#region:eu_west,team_1,url:http_//x,channel:presence-a_b_c_d_e"; packet != expected {
+		t.Fatalf("expected %q, got %q", expected, packet)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	addr, next := listen(t)
+	collector, err := pusher.MetricsCollectors.New("statsd", []byte(`{"address":"`+addr+`","prefix":"","dogstatsd":true,"tags":["a:b"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.(*statsd.Exporter).Close()
+	collector.Reconnecting(1, time.Second)
+	if packet := next(); packet != "reconnects:1|c|#a:b" {
+		t.Fatalf("unexpected packet %q", packet)
+	}
+}
@@ -0,0 +1,65 @@
+package pusher
+
+// Tracer starts spans around a client's connections, subscriptions and event
+// dispatch, so that realtime flows can be correlated with backend traces. The
+// otel package provides an OpenTelemetry implementation. Spans are started on
+// the run loop, and each start returns the function ending its span.
+type Tracer interface {
+	// StartConnect starts a span covering a connection attempt, up to the
+	// completion of its handshake
+	StartConnect(transport string) (end func(err error))
+	// StartSubscribe starts a span covering a subscription, including its
+	// authorization, up to its acknowledgement by the server
+	StartSubscribe(channel string) (end func(err error))
+	// StartDispatch starts a span covering the dispatch of a received event
+	// to bindings
+	StartDispatch(event Event) (end func())
+}
+
+type noopTracer struct{}
+
+func (noopTracer) StartConnect(transport string) func(err error) { return func(error) {} }
+func (noopTracer) StartSubscribe(channel string) func(err error) { return func(error) {} }
+func (noopTracer) StartDispatch(event Event) func()              { return func() {} }
+
+// tracer returns the configured tracer, or one which discards spans
+func (c ClientConfig) tracer() Tracer {
+	if c.Tracer != nil {
+		return c.Tracer
+	}
+	return noopTracer{}
+}
+
+// endConnectSpan ends the span of the pending connection attempt, if any
+func (self *Client) endConnectSpan(err error) {
+	if self.loop.connectSpan != nil {
+		self.loop.connectSpan(err)
+		self.loop.connectSpan = nil
+	}
+}
+
+// startSubscribeSpan starts the span of a subscription, ending any previous
+// attempt which is still pending
+func (self *Client) startSubscribeSpan(channel string) {
+	self.endSubscribeSpan(channel, ErrSubscriptionRetried)
+	if self.loop.subscribeSpans == nil {
+		self.loop.subscribeSpans = map[string]func(error){}
+	}
+	self.loop.subscribeSpans[channel] = self.tracer().StartSubscribe(channel)
+}
+
+// endSubscribeSpan ends the span of a pending subscription, if any
+func (self *Client) endSubscribeSpan(channel string, err error) {
+	if end := self.loop.subscribeSpans[channel]; end != nil {
+		end(err)
+		delete(self.loop.subscribeSpans, channel)
+	}
+}
+
+// endSpans ends every pending span, when the connection is lost
+func (self *Client) endSpans(err error) {
+	self.endConnectSpan(err)
+	for channel := range self.loop.subscribeSpans {
+		self.endSubscribeSpan(channel, err)
+	}
+}
@@ -0,0 +1,72 @@
+package pusher_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+	"github.com/mnaser/pusher-websocket-go/pushertest"
+)
+
+// spans reports each span as it ends
+type spans chan string
+
+func (self spans) StartConnect(transport string) func(err error) {
+	return func(err error) { self <- "connect " + transport + " " + errString(err) }
+}
+
+func (self spans) StartSubscribe(channel string) func(err error) {
+	return func(err error) { self <- "subscribe " + channel + " " + errString(err) }
+}
+
+func (self spans) StartDispatch(event pusher.Event) func() {
+	return func() { self <- "dispatch " + event.Channel + " " + event.Name }
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return "failed"
+}
+
+// expectSpan waits for a span to end
+func expectSpan(t *testing.T, ended spans, expected string) {
+	t.Helper()
+	select {
+	case span := <-ended:
+		if span != expected {
+			t.Fatalf("expected %q, got %q", expected, span)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %q", expected)
+	}
+}
+
+// TestTracer connects, subscribes and receives an event, each in a span
+func TestTracer(t *testing.T) {
+	srv := pushertest.NewServer("key", "secret")
+	defer srv.Close()
+	ended := make(spans, 10)
+	config := srv.ClientConfig()
+	config.Tracer = ended
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	expectSpan(t, ended, "connect ws ok")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	channel, err := client.SubscribeWithResult(ctx, "orders")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	expectSpan(t, ended, "subscribe orders ok")
+
+	channel.Bind("created", func(interface{}) {})
+	srv.Trigger("orders", "created", "{}")
+	expectSpan(t, ended, "dispatch orders created")
+
+	client.Subscribe("private-orders")
+	expectSpan(t, ended, "subscribe private-orders failed")
+}
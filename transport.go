@@ -0,0 +1,90 @@
+package pusher
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Transport names, for use in ClientConfig.Transports
+const (
+	TransportWebSocket    = "ws"
+	TransportXHRStreaming = "xhr_streaming"
+	TransportXHRPolling   = "xhr_polling"
+)
+
+// Number of consecutive failures before moving to the next transport
+const fallbackAfterFailures = 3
+
+// transport is the framing layer underneath a connection. Implementations
+// report any sign of life from the server (pings, pongs, heartbeats) through
+// the activity callback they were dialled with.
+type transport interface {
+	// ReadMessage blocks until the next message arrives
+	ReadMessage() ([]byte, error)
+	WriteMessage(msg []byte) error
+	Close() error
+}
+
+// checkTransports returns ErrUnknownTransport for the first name in
+// Transports which is not a transport
+func (c ClientConfig) checkTransports() error {
+	for _, name := range c.Transports {
+		switch name {
+		case TransportWebSocket, TransportXHRStreaming, TransportXHRPolling:
+		default:
+			return fmt.Errorf("%w %q", ErrUnknownTransport, name)
+		}
+	}
+	return nil
+}
+
+// transports returns the transports to attempt, in order of preference
+func (c ClientConfig) transports() []string {
+	if len(c.Transports) > 0 {
+		return c.Transports
+	}
+	if c.EnableFallback {
+		return []string{TransportWebSocket, TransportXHRStreaming, TransportXHRPolling}
+	}
+	return []string{TransportWebSocket}
+}
+
+// rotateHost returns the config for connecting to a host in the rotation of
+// Host followed by AlternateHosts
+func (c ClientConfig) rotateHost(index int) ClientConfig {
+	index %= len(c.AlternateHosts) + 1
+	if index == 0 {
+		return c
+	}
+	host := c.AlternateHosts[index-1]
+	if name, port, err := net.SplitHostPort(host); err == nil {
+		c.Host, c.Port = name, port
+	} else {
+		c.Host = host
+	}
+	return c
+}
+
+// fallbackSession returns the path of new SockJS server and session IDs,
+// e.g. 042/3fzqkv2a
+func (self *Client) fallbackSession() string {
+	server := fmt.Sprintf("%03d", self.loop.rand.Intn(1000))
+	return server + "/" + strconv.FormatInt(self.loop.rand.Int63(), 36)
+}
+
+func dialTransport(c ClientConfig, name string, onActivity func()) (transport, error) {
+	switch name {
+	case TransportWebSocket:
+		url, err := BuildURL(c)
+		if err != nil {
+			return nil, err
+		}
+		return dialWebSocket(c, url, onActivity)
+	case TransportXHRStreaming:
+		return dialXHRStreaming(c, onActivity)
+	case TransportXHRPolling:
+		return dialXHRPolling(c, onActivity)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownTransport, name)
+}
@@ -0,0 +1,115 @@
+//go:build js && wasm
+
+package pusher
+
+import (
+	"errors"
+	"sync"
+	"syscall/js"
+)
+
+// jsTransport uses the browser's native WebSocket, as raw sockets are not
+// available to WebAssembly modules
+type jsTransport struct {
+	ws    js.Value
+	funcs []js.Func
+
+	mutex   sync.Mutex
+	pending [][]byte
+	notify  chan struct{}
+	closed  chan struct{}
+	err     error
+}
+
+func dialWebSocket(c ClientConfig, url string, onActivity func()) (transport, error) {
+	constructor := js.Global().Get("WebSocket")
+	if constructor.IsUndefined() {
+		return nil, errors.New("pusher: WebSocket is not available in this environment")
+	}
+
+	t := &jsTransport{
+		ws:     constructor.New(url),
+		notify: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	opened := make(chan struct{})
+
+	// Callbacks run on the browser event loop, so must never block
+	t.on("open", func(event js.Value) {
+		close(opened)
+	})
+	t.on("message", func(event js.Value) {
+		t.mutex.Lock()
+		t.pending = append(t.pending, []byte(event.Get("data").String()))
+		t.mutex.Unlock()
+		select {
+		case t.notify <- struct{}{}:
+		default:
+		}
+	})
+	t.on("close", func(event js.Value) {
+		t.mutex.Lock()
+		t.err = closeError(event.Get("code").Int(), event.Get("reason").String())
+		t.mutex.Unlock()
+		close(t.closed)
+	})
+
+	select {
+	case <-opened:
+		return t, nil
+	case <-t.closed:
+		t.release()
+		return nil, t.err
+	}
+}
+
+func (self *jsTransport) on(event string, callback func(event js.Value)) {
+	fn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		callback(args[0])
+		return nil
+	})
+	self.funcs = append(self.funcs, fn)
+	self.ws.Call("addEventListener", event, fn)
+}
+
+func (self *jsTransport) release() {
+	for _, fn := range self.funcs {
+		fn.Release()
+	}
+	self.funcs = nil
+}
+
+func (self *jsTransport) ReadMessage() ([]byte, error) {
+	for {
+		self.mutex.Lock()
+		if len(self.pending) > 0 {
+			msg := self.pending[0]
+			self.pending = self.pending[1:]
+			self.mutex.Unlock()
+			return msg, nil
+		}
+		self.mutex.Unlock()
+
+		select {
+		case <-self.notify:
+		case <-self.closed:
+			self.release()
+			return nil, self.err
+		}
+	}
+}
+
+func (self *jsTransport) WriteMessage(msg []byte) error {
+	select {
+	case <-self.closed:
+		return self.err
+	default:
+	}
+	self.ws.Call("send", string(msg))
+	return nil
+}
+
+func (self *jsTransport) Close() error {
+	self.ws.Call("close")
+	return nil
+}
@@ -0,0 +1,100 @@
+//go:build js && wasm
+
+package pusher
+
+import (
+	"syscall/js"
+	"testing"
+)
+
+// fakeWebSocket installs a global WebSocket constructor whose instances
+// record sent frames, returning the listeners of the last one created
+func fakeWebSocket(t *testing.T, open bool) (listeners map[string]js.Value, sent *[]string) {
+	listeners = map[string]js.Value{}
+	sent = new([]string)
+	var funcs []js.Func
+	fn := func(f func(args []js.Value) interface{}) js.Func {
+		funcs = append(funcs, js.FuncOf(func(this js.Value, args []js.Value) interface{} { return f(args) }))
+		return funcs[len(funcs)-1]
+	}
+
+	constructor := fn(func(args []js.Value) interface{} {
+		ws := js.Global().Get("Object").New()
+		ws.Set("addEventListener", fn(func(args []js.Value) interface{} {
+			listeners[args[0].String()] = args[1]
+			return nil
+		}))
+		ws.Set("send", fn(func(args []js.Value) interface{} {
+			*sent = append(*sent, args[0].String())
+			return nil
+		}))
+		ws.Set("close", fn(func(args []js.Value) interface{} {
+			listeners["close"].Invoke(map[string]interface{}{"code": 1000, "reason": ""})
+			return nil
+		}))
+		// The browser fires events asynchronously once the constructor returns
+		js.Global().Call("setTimeout", fn(func([]js.Value) interface{} {
+			if open {
+				listeners["open"].Invoke(map[string]interface{}{})
+			} else {
+				listeners["close"].Invoke(map[string]interface{}{"code": 4001, "reason": "App key not in this cluster"})
+			}
+			return nil
+		}), 0)
+		return ws
+	})
+	previous := js.Global().Get("WebSocket")
+	js.Global().Set("WebSocket", constructor)
+	t.Cleanup(func() {
+		js.Global().Set("WebSocket", previous)
+		for _, f := range funcs {
+			f.Release()
+		}
+	})
+	return listeners, sent
+}
+
+func TestJSTransport(t *testing.T) {
+	listeners, sent := fakeWebSocket(t, true)
+	transport, err := dialWebSocket(ClientConfig{}, "ws://example.com/app/key", func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listeners["message"].Invoke(map[string]interface{}{"data": "one"})
+	listeners["message"].Invoke(map[string]interface{}{"data": "two"})
+	for _, expected := range []string{"one", "two"} {
+		if msg, err := transport.ReadMessage(); err != nil || string(msg) != expected {
+			t.Fatalf("expected %q, got %q, %v", expected, msg, err)
+		}
+	}
+
+	if err := transport.WriteMessage([]byte("ping")); err != nil || len(*sent) != 1 || (*sent)[0] != "ping" {
+		t.Fatalf("unexpected send %v, %v", *sent, err)
+	}
+
+	transport.Close()
+	if _, err := transport.ReadMessage(); err == nil {
+		t.Fatal("expected an error reading after close")
+	}
+	if err := transport.WriteMessage([]byte("ping")); err == nil {
+		t.Fatal("expected an error writing after close")
+	}
+}
+
+func TestJSTransportRefused(t *testing.T) {
+	fakeWebSocket(t, false)
+	_, err := dialWebSocket(ClientConfig{}, "ws://example.com/app/key", func() {})
+	if err == nil || err.Error() != closeError(4001, "App key not in this cluster").Error() {
+		t.Fatalf("expected the close error, got %v", err)
+	}
+}
+
+func TestJSTransportUnavailable(t *testing.T) {
+	previous := js.Global().Get("WebSocket")
+	js.Global().Set("WebSocket", js.Undefined())
+	defer js.Global().Set("WebSocket", previous)
+	if _, err := dialWebSocket(ClientConfig{}, "ws://example.com/app/key", func() {}); err == nil {
+		t.Fatal("expected an error without WebSocket")
+	}
+}
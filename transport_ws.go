@@ -0,0 +1,80 @@
+//go:build !js
+
+package pusher
+
+import (
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type wsTransport struct {
+	ws *websocket.Conn
+}
+
+func dialWebSocket(c ClientConfig, wsURL string, onActivity func()) (transport, error) {
+	dialContext, err := c.dialContext()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := c.proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.NetDialContext = dialContext
+	if c.TLSConfig != nil {
+		dialer.TLSClientConfig = c.TLSConfig.Clone()
+	}
+	dialer.EnableCompression = c.EnableCompression
+	dialer.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if u != nil && u.Scheme == "socks5h" {
+			// The websocket package resolves names on the proxy anyway, and
+			// only knows this scheme as socks5
+			resolved := *u
+			resolved.Scheme = "socks5"
+			u = &resolved
+		}
+		return u, err
+	}
+
+	ws, _, err := dialer.Dial(wsURL, c.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	ws.SetPingHandler(func(msg string) error {
+		// TODO: Check that this is safe
+		ws.WriteControl(websocket.PongMessage, []byte(msg), time.Now().Add(writeWait))
+		onActivity()
+		return nil
+	})
+
+	ws.SetPongHandler(func(msg string) error {
+		onActivity()
+		return nil
+	})
+
+	return &wsTransport{ws: ws}, nil
+}
+
+func (self *wsTransport) ReadMessage() ([]byte, error) {
+	_, msg, err := self.ws.ReadMessage()
+	if closed, ok := err.(*websocket.CloseError); ok {
+		err = closeError(closed.Code, closed.Text)
+	}
+	return msg, err
+}
+
+func (self *wsTransport) WriteMessage(msg []byte) error {
+	return self.ws.WriteMessage(websocket.TextMessage, msg)
+}
+
+func (self *wsTransport) Close() error {
+	self.ws.WriteControl(websocket.CloseMessage, nil, time.Now().Add(writeWait))
+	return self.ws.Close()
+}
@@ -0,0 +1,134 @@
+//go:build !pusher_minimal
+
+package pusher
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Names of webhook events
+const (
+	WebhookChannelOccupied = "channel_occupied"
+	WebhookChannelVacated  = "channel_vacated"
+	WebhookMemberAdded     = "member_added"
+	WebhookMemberRemoved   = "member_removed"
+	WebhookClientEvent     = "client_event"
+)
+
+// Webhook is a batch of events sent by Pusher to a webhook endpoint
+type Webhook struct {
+	TimeMs int64          `json:"time_ms"`
+	Events []WebhookEvent `json:"events"`
+}
+
+// WebhookEvent is an event in a Webhook. Event, Data and SocketID are set
+// for client events, and UserID for member events and client events on
+// presence channels.
+type WebhookEvent struct {
+	Name     string `json:"name"`
+	Channel  string `json:"channel"`
+	Event    string `json:"event,omitempty"`
+	Data     string `json:"data,omitempty"`
+	SocketID string `json:"socket_id,omitempty"`
+	UserID   string `json:"user_id,omitempty"`
+}
+
+// WebhookHandler is an http.Handler receiving Pusher webhooks. It rejects
+// requests whose X-Pusher-Key and X-Pusher-Signature do not match the app,
+// and calls the callback of each event received:
+//
+//	handler := pusher.NewWebhookHandler(key, secret)
+//	handler.OnMemberAdded = func(channel, userID string) { ... }
+//	http.Handle("/pusher/webhooks", handler)
+type WebhookHandler struct {
+	Key    string
+	Secret string
+	// Crypto replaces the default in-process use of Secret for verifying
+	// signatures
+	Crypto CryptoProvider
+
+	OnChannelOccupied func(channel string)
+	OnChannelVacated  func(channel string)
+	OnMemberAdded     func(channel, userID string)
+	OnMemberRemoved   func(channel, userID string)
+	OnClientEvent     func(event WebhookEvent)
+}
+
+func NewWebhookHandler(key, secret string) *WebhookHandler {
+	return &WebhookHandler{Key: key, Secret: secret}
+}
+
+// Parse validates the signature of a webhook request and returns its
+// payload, or ErrWebhookSignature
+func (self *WebhookHandler) Parse(r *http.Request) (*Webhook, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(r.Header.Get("X-Pusher-Key")), []byte(self.Key)) {
+		return nil, ErrWebhookSignature
+	}
+	crypto := self.Crypto
+	if crypto == nil {
+		crypto = secretCrypto{secret: self.Secret}
+	}
+	expected, err := crypto.Sign(string(body))
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(r.Header.Get("X-Pusher-Signature")), []byte(expected)) {
+		return nil, ErrWebhookSignature
+	}
+
+	webhook := &Webhook{}
+	if err := json.Unmarshal(body, webhook); err != nil {
+		return nil, fmt.Errorf("pusher: invalid webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (self *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	webhook, err := self.Parse(r)
+	if err == ErrWebhookSignature {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range webhook.Events {
+		switch event.Name {
+		case WebhookChannelOccupied:
+			if self.OnChannelOccupied != nil {
+				self.OnChannelOccupied(event.Channel)
+			}
+		case WebhookChannelVacated:
+			if self.OnChannelVacated != nil {
+				self.OnChannelVacated(event.Channel)
+			}
+		case WebhookMemberAdded:
+			if self.OnMemberAdded != nil {
+				self.OnMemberAdded(event.Channel, event.UserID)
+			}
+		case WebhookMemberRemoved:
+			if self.OnMemberRemoved != nil {
+				self.OnMemberRemoved(event.Channel, event.UserID)
+			}
+		case WebhookClientEvent:
+			if self.OnClientEvent != nil {
+				self.OnClientEvent(event)
+			}
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,66 @@
+//go:build !pusher_minimal
+
+package pusher_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// webhookRequest returns a webhook request of body, signed with key and
+// secret
+func webhookRequest(method, body, key, secret string) *http.Request {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	r := httptest.NewRequest(method, "/pusher/webhooks", strings.NewReader(body))
+	r.Header.Set("X-Pusher-Key", key)
+	r.Header.Set("X-Pusher-Signature", hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestWebhookHandler(t *testing.T) {
+	const body = `{"time_ms":1,"events":[
+		{"name":"channel_occupied","channel":"orders"},
+		{"name":"member_added","channel":"presence-x","user_id":"1"},
+		{"name":"client_event","channel":"private-x","event":"client-typing","data":"{}","socket_id":"1.1"}
+	]}`
+	for _, test := range []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"valid signature", webhookRequest("POST", body, "key", "secret"), http.StatusOK},
+		{"wrong key", webhookRequest("POST", body, "other", "secret"), http.StatusUnauthorized},
+		{"wrong signature", webhookRequest("POST", body, "key", "other"), http.StatusUnauthorized},
+		{"not POST", webhookRequest("GET", body, "key", "secret"), http.StatusMethodNotAllowed},
+		{"invalid JSON", webhookRequest("POST", "{", "key", "secret"), http.StatusBadRequest},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var received []string
+			handler := pusher.NewWebhookHandler("key", "secret")
+			handler.OnChannelOccupied = func(channel string) { received = append(received, "occupied "+channel) }
+			handler.OnMemberAdded = func(channel, userID string) { received = append(received, "added "+userID) }
+			handler.OnClientEvent = func(event pusher.WebhookEvent) { received = append(received, "client "+event.Event) }
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, test.request)
+			if w.Code != test.status {
+				t.Fatalf("responded %v, expected %v", w.Code, test.status)
+			}
+			expected := ""
+			if test.status == http.StatusOK {
+				expected = "occupied orders, added 1, client client-typing"
+			}
+			if events := strings.Join(received, ", "); events != expected {
+				t.Fatalf("received %q, expected %q", events, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+//go:build !pusher_minimal
+
+package pusher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// xhrTransport speaks the SockJS xhr-streaming and xhr-polling protocols:
+// frames arrive on a long-lived streaming POST, or for polling, one per POST,
+// and each outgoing message is a separate POST.
+type xhrTransport struct {
+	// Endpoint receiving frames, xhr_streaming or xhr for polling
+	receive    string
+	sessionURL string
+	query      string
+	client     *http.Client
+	headers    http.Header
+	onActivity func()
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex   sync.Mutex
+	body    io.ReadCloser
+	reader  *bufio.Reader
+	pending [][]byte
+}
+
+// httpClient returns the client used by the HTTP fallback transports
+func (c ClientConfig) httpClient() (*http.Client, error) {
+	dialContext, err := c.dialContext()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := c.proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialContext
+	transport.Proxy = proxy
+	if c.TLSConfig != nil {
+		transport.TLSClientConfig = c.TLSConfig.Clone()
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// buildFallbackURL returns the SockJS session URL for the fallback session
+// drawn by the client, e.g.
+// https://sockjs.pusher.com:443/pusher/app/{key}/{server}/{session}
+func buildFallbackURL(c ClientConfig) (string, error) {
+	wsURL, err := BuildURL(c)
+	if err != nil {
+		return "", err
+	}
+	u, _ := url.Parse(wsURL)
+
+	if u.Scheme == "wss" {
+		u.Scheme = "https"
+	} else {
+		u.Scheme = "http"
+	}
+	// A fallback host without a port is served on the same port as Host
+	if _, _, err := net.SplitHostPort(c.FallbackHost); err == nil {
+		u.Host = c.FallbackHost
+	} else if c.FallbackHost != "" {
+		u.Host = net.JoinHostPort(strings.Trim(c.FallbackHost, "[]"), u.Port())
+	}
+
+	u.Path = strings.Replace(u.Path, "/app/", "/pusher/app/", 1) + "/" + c.fallbackSession
+
+	return u.String(), nil
+}
+
+func dialXHRStreaming(c ClientConfig, onActivity func()) (transport, error) {
+	return dialXHR(c, "xhr_streaming", onActivity)
+}
+
+// dialXHRPolling dials the long-polling transport, for networks whose
+// proxies buffer streaming responses
+func dialXHRPolling(c ClientConfig, onActivity func()) (transport, error) {
+	return dialXHR(c, "xhr", onActivity)
+}
+
+func dialXHR(c ClientConfig, receive string, onActivity func()) (transport, error) {
+	sessionURL, err := buildFallbackURL(c)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(sessionURL)
+	query := u.RawQuery
+	u.RawQuery = ""
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &xhrTransport{
+		receive:    receive,
+		sessionURL: u.String(),
+		query:      query,
+		client:     client,
+		headers:    c.Headers,
+		onActivity: onActivity,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	if err := t.openStream(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The session starts with an open frame
+	frame, err := t.readFrame()
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	if frame != "o" {
+		t.Close()
+		return nil, fmt.Errorf("pusher: unexpected fallback frame %q", frame)
+	}
+
+	return t, nil
+}
+
+func (self *xhrTransport) url(endpoint string) string {
+	return self.sessionURL + "/" + endpoint + "?" + self.query
+}
+
+// request returns a request to a session endpoint, with the configured headers
+func (self *xhrTransport) request(endpoint string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(self.ctx, "POST", self.url(endpoint), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range self.headers {
+		req.Header[name] = values
+	}
+	return req, nil
+}
+
+// openStream starts a new receiving request. Servers end each streaming
+// response after a while, and each polling response after one frame, and
+// expect the client to open another one for the same session.
+func (self *xhrTransport) openStream() error {
+	req, err := self.request(self.receive, nil)
+	if err != nil {
+		return err
+	}
+	res, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return fmt.Errorf("pusher: fallback stream returned %v", res.Status)
+	}
+
+	self.mutex.Lock()
+	if self.body != nil {
+		self.body.Close()
+	}
+	self.body = res.Body
+	self.reader = bufio.NewReader(res.Body)
+	self.mutex.Unlock()
+
+	return nil
+}
+
+// readFrame returns the next non-prelude frame from the stream, reopening the
+// stream when the server ends it
+func (self *xhrTransport) readFrame() (string, error) {
+	for {
+		line, err := self.reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			if err = self.openStream(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+
+		line = strings.TrimRight(line, "\n")
+		// Streams start with a 2KiB prelude of 'h' to defeat proxy buffering
+		if len(line) > 1 && strings.Trim(line, "h") == "" {
+			continue
+		}
+		if line != "" {
+			return line, nil
+		}
+	}
+}
+
+func (self *xhrTransport) ReadMessage() ([]byte, error) {
+	for len(self.pending) == 0 {
+		frame, err := self.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch frame[0] {
+		case 'h':
+			self.onActivity()
+		case 'a':
+			var messages []string
+			if err := json.Unmarshal([]byte(frame[1:]), &messages); err != nil {
+				return nil, err
+			}
+			for _, msg := range messages {
+				self.pending = append(self.pending, []byte(msg))
+			}
+		case 'c':
+			var closed []interface{}
+			if json.Unmarshal([]byte(frame[1:]), &closed) == nil && len(closed) == 2 {
+				if code, ok := closed[0].(float64); ok {
+					return nil, closeError(int(code), fmt.Sprint(closed[1]))
+				}
+			}
+			return nil, fmt.Errorf("pusher: fallback session closed %v", frame[1:])
+		default:
+			return nil, fmt.Errorf("pusher: unexpected fallback frame %q", frame)
+		}
+	}
+
+	msg := self.pending[0]
+	self.pending = self.pending[1:]
+	return msg, nil
+}
+
+func (self *xhrTransport) WriteMessage(msg []byte) error {
+	body, err := json.Marshal([]string{string(msg)})
+	if err != nil {
+		return err
+	}
+	req, err := self.request("xhr_send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("pusher: fallback send returned %v", res.Status)
+	}
+	return nil
+}
+
+func (self *xhrTransport) Close() error {
+	self.cancel()
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.body == nil {
+		return errors.New("pusher: fallback stream not open")
+	}
+	return self.body.Close()
+}
@@ -0,0 +1,15 @@
+//go:build pusher_minimal
+
+package pusher
+
+import (
+	"errors"
+)
+
+func dialXHRStreaming(c ClientConfig, onActivity func()) (transport, error) {
+	return nil, errors.New("pusher: HTTP fallback is not available in pusher_minimal builds")
+}
+
+func dialXHRPolling(c ClientConfig, onActivity func()) (transport, error) {
+	return nil, errors.New("pusher: HTTP fallback is not available in pusher_minimal builds")
+}
@@ -0,0 +1,234 @@
+//go:build !pusher_minimal
+
+package pusher_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mnaser/pusher-websocket-go"
+)
+
+// sockJS is a SockJS server stub. Every receiving request, streaming or
+// polling, delivers one frame before ending, so that the client must reopen
+// it, and the first of each session delivers the open frame.
+type sockJS struct {
+	srv *httptest.Server
+	// frames are delivered to the client in order
+	frames chan string
+	// sent receives the messages the client sends
+	sent chan string
+
+	mutex sync.Mutex
+	// Paths of the receiving requests, and the number of WebSocket
+	// handshakes refused
+	requests   []string
+	handshakes int
+	sessions   map[string]bool
+}
+
+func newSockJS(t *testing.T) *sockJS {
+	stub := &sockJS{frames: make(chan string, 16), sent: make(chan string, 16), sessions: map[string]bool{}}
+	stub.srv = httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(stub.srv.Close)
+	return stub
+}
+
+func (self *sockJS) serve(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/app/") {
+		self.mutex.Lock()
+		self.handshakes++
+		self.mutex.Unlock()
+		http.Error(w, "WebSocket blocked", http.StatusForbidden)
+		return
+	}
+	session, endpoint := r.URL.Path[:strings.LastIndex(r.URL.Path, "/")], r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if endpoint == "xhr_send" {
+		var messages []string
+		json.NewDecoder(r.Body).Decode(&messages)
+		for _, message := range messages {
+			self.sent <- message
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	self.mutex.Lock()
+	self.requests = append(self.requests, r.URL.Path)
+	opened := self.sessions[session]
+	self.sessions[session] = true
+	self.mutex.Unlock()
+
+	if endpoint == "xhr_streaming" {
+		w.Write([]byte(strings.Repeat("h", 2048) + "\n"))
+	}
+	if !opened {
+		w.Write([]byte("o\n"))
+		return
+	}
+	select {
+	case frame := <-self.frames:
+		w.Write([]byte(frame + "\n"))
+	case <-r.Context().Done():
+	}
+}
+
+// config returns the config for a client using transports against the stub
+func (self *sockJS) config(transports ...string) pusher.ClientConfig {
+	u, _ := url.Parse(self.srv.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	return pusher.ClientConfig{Scheme: "ws", Host: host, Port: port, Key: "key", Transports: transports}
+}
+
+// send delivers messages to the client in an array frame
+func (self *sockJS) send(messages ...string) {
+	frame, _ := json.Marshal(messages)
+	self.frames <- "a" + string(frame)
+}
+
+// receive waits for the next message sent by the client
+func (self *sockJS) receive(t *testing.T) string {
+	t.Helper()
+	select {
+	case message := <-self.sent:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message from the client")
+		return ""
+	}
+}
+
+func TestXHRTransports(t *testing.T) {
+	for _, transport := range []string{pusher.TransportXHRStreaming, pusher.TransportXHRPolling} {
+		t.Run(transport, func(t *testing.T) {
+			stub := newSockJS(t)
+			config := stub.config(transport)
+			failed := make(chan error, 1)
+			config.OnConnectionFailed = func(err error) { failed <- err }
+			client := pusher.NewWithConfig(config)
+			defer client.Disconnect()
+			channel := client.Subscribe("orders")
+			events := make(chan interface{}, 1)
+			channel.Bind("created", func(data interface{}) { events <- data })
+
+			stub.frames <- "h"
+			stub.send(established)
+			if message := stub.receive(t); !strings.Contains(message, `"pusher:subscribe"`) || !strings.Contains(message, `"orders"`) {
+				t.Fatalf("expected a subscription, got %v", message)
+			}
+			stub.send(`{"event":"pusher_internal:subscription_succeeded","channel":"orders","data":"{}"}`,
+				`{"event":"created","channel":"orders","data":"{\"id\":1}"}`)
+			if data := receive(t, events); data != `{"id":1}` {
+				t.Fatalf("received %v", data)
+			}
+
+			stub.frames <- `c[4001,"app disabled"]`
+			select {
+			case err := <-failed:
+				var connErr *pusher.ConnectionError
+				if !errors.As(err, &connErr) || connErr.Code != 4001 {
+					t.Fatalf("gave up with %v, expected code 4001", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("close frame did not reach the client")
+			}
+
+			stub.mutex.Lock()
+			defer stub.mutex.Unlock()
+			if len(stub.requests) != 5 {
+				t.Fatalf("expected the stream to be reopened after each frame, got requests %v", stub.requests)
+			}
+			for _, path := range stub.requests {
+				if !strings.HasPrefix(path, "/pusher/app/key/") || !strings.HasSuffix(path, "/"+strings.TrimSuffix(transport, "_polling")) {
+					t.Fatalf("request to %v", path)
+				}
+			}
+		})
+	}
+}
+
+// TestFallback refuses every WebSocket handshake, after enough of which the
+// client must fall back to HTTP streaming
+func TestFallback(t *testing.T) {
+	stub := newSockJS(t)
+	config := stub.config()
+	config.EnableFallback = true
+	config.MaxReconnectDelay = time.Millisecond
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	stub.send(established)
+	deadline := time.Now().Add(2 * time.Second)
+	for client.State() != pusher.StateConnected {
+		if time.Now().After(deadline) {
+			t.Fatal("did not connect over the fallback transport")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	if stub.handshakes != 3 {
+		t.Fatalf("fell back after %v WebSocket handshakes, expected 3", stub.handshakes)
+	}
+	if !strings.HasSuffix(stub.requests[0], "/xhr_streaming") {
+		t.Fatalf("fell back to %v, expected xhr_streaming", stub.requests[0])
+	}
+}
+
+// TestFallbackSelfHosted falls back on a self-hosted server configured from
+// the environment, which must serve the fallback on its own host and port
+func TestFallbackSelfHosted(t *testing.T) {
+	stub := newSockJS(t)
+	server := stub.config()
+	setenv(t, map[string]string{"KEY": "key", "CLUSTER": "eu", "HOST": server.Host, "PORT": server.Port, "SCHEME": "ws"})
+	config, err := pusher.ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Transports = []string{pusher.TransportXHRStreaming}
+	client := pusher.NewWithConfig(config)
+	defer client.Disconnect()
+
+	stub.frames <- "h"
+	stub.send(established)
+	waitState(t, client, pusher.StateConnected)
+}
+
+// TestFallbackSessionSeeded checks that the SockJS session of a fallback
+// connection is drawn from RandSource
+func TestFallbackSessionSeeded(t *testing.T) {
+	session := func(seed int64) string {
+		stub := newSockJS(t)
+		config := stub.config(pusher.TransportXHRStreaming)
+		config.RandSource = rand.NewSource(seed)
+		client := pusher.NewWithConfig(config)
+		defer client.Disconnect()
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			stub.mutex.Lock()
+			requests := stub.requests
+			stub.mutex.Unlock()
+			if len(requests) > 0 {
+				return requests[0]
+			}
+		}
+		t.Fatal("timed out waiting for the fallback session")
+		return ""
+	}
+
+	first, again, other := session(1), session(1), session(2)
+	if first != again {
+		t.Fatalf("sessions %v and %v with the same seed", first, again)
+	}
+	if first == other {
+		t.Fatalf("session %v with different seeds", first)
+	}
+}